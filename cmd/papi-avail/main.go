@@ -0,0 +1,347 @@
+// papi-avail lists PAPI's preset events, much as PAPI's own
+// papi_avail utility does, but can also produce machine-readable
+// output suitable for inventorying many nodes.  For each preset event
+// it reports the event's code, whether the event is available on this
+// node, whether it is derived from more than one native event, and
+// its description.
+//
+// Usage:
+//
+//	papi-avail [-a] [-check] [-json | -csv] [-e event]
+//
+// The -a option omits unavailable events.  The -check option verifies
+// each available event by adding it to an event set, which catches
+// events that PAPI lists but the hardware or kernel cannot count.  The
+// -e option describes a single preset or native event in detail,
+// including the native events from which it is derived.  The -json
+// option writes a JSON object describing the node and its events; the
+// -csv option writes one row per event.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/cmdutil"
+)
+
+// A nativeTerm is one of the native events from which a preset is
+// derived.
+type nativeTerm struct {
+	Name string `json:"name"` // Name of the native event
+	Code string `json:"code"` // Native event code in hexadecimal
+}
+
+// An eventReport describes a single event.
+type eventReport struct {
+	Name        string       `json:"name"`                    // Name of the event
+	Code        string       `json:"code"`                    // Event code in hexadecimal
+	Available   bool         `json:"available"`               // true if PAPI can count the event on this node
+	Addable     *bool        `json:"addable,omitempty"`       // true if the event could be added to an event set (-check only)
+	Derived     bool         `json:"derived"`                 // true if the event is derived from more than one native event
+	Derivation  string       `json:"derivation,omitempty"`    // Derived type (e.g., DERIVED_ADD)
+	Postfix     string       `json:"postfix,omitempty"`       // Postfix formula for DERIVED_POSTFIX events
+	ShortDescr  string       `json:"short_description"`       // Short description
+	Description string       `json:"description"`             // Long description
+	Note        string       `json:"note,omitempty"`          // Developer note
+	Natives     []nativeTerm `json:"native_events,omitempty"` // Native events from which the event is derived
+}
+
+// A hostReport describes the node on which the events were listed.
+type hostReport struct {
+	Hostname          string `json:"hostname"`           // Name of the node
+	PAPIVersion       string `json:"papi_version"`       // Version of papi.h against which go-papi was built
+	Vendor            string `json:"vendor"`             // CPU vendor
+	Model             string `json:"model"`              // CPU model
+	CPUIDFamily       int32  `json:"cpuid_family"`       // CPUID family
+	CPUIDModel        int32  `json:"cpuid_model"`        // CPUID model
+	CPUIDStepping     int32  `json:"cpuid_stepping"`     // CPUID stepping
+	TotalCPUs         int32  `json:"total_cpus"`         // Number of CPUs in the node
+	Counters          int    `json:"counters"`           // Number of hardware counters in the CPU component
+	MultiplexCounters int    `json:"multiplex_counters"` // Number of counters available with multiplexing
+}
+
+// A report is the complete output of papi-avail -json.
+type report struct {
+	Host   hostReport    `json:"host"`
+	Events []eventReport `json:"events"`
+}
+
+// Describe the node.
+func describeHost() hostReport {
+	hw := papi.GetHardwareInfo()
+	h := hostReport{
+		PAPIVersion:   papi.HeaderVersion,
+		Vendor:        hw.VendorName,
+		Model:         hw.ModelName,
+		CPUIDFamily:   hw.CPUIDFamily,
+		CPUIDModel:    hw.CPUIDModel,
+		CPUIDStepping: hw.CPUIDStepping,
+		TotalCPUs:     hw.TotalCPUs,
+		Counters:      papi.GetNumCounters(0),
+	}
+	h.Hostname, _ = os.Hostname()
+	if info, err := papi.GetComponentInfo(0); err == nil {
+		h.MultiplexCounters = info.NumMpxCntrs
+	}
+	return h
+}
+
+// Describe an event.
+func describeEvent(ev papi.Event, available bool) (eventReport, error) {
+	info, err := papi.GetEventInfo(ev)
+	if err != nil {
+		return eventReport{}, fmt.Errorf("%s: %w", ev, err)
+	}
+	r := eventReport{
+		Name:        info.Symbol,
+		Code:        cmdutil.HexCode(ev),
+		Available:   available,
+		Derivation:  info.Derived,
+		Postfix:     info.Postfix,
+		ShortDescr:  info.ShortDescr,
+		Description: info.LongDescr,
+		Note:        info.Note,
+	}
+	if r.Name == "" {
+		r.Name = ev.String()
+	}
+	if r.Derivation == "NOT_DERIVED" {
+		r.Derivation = ""
+	}
+	r.Derived = r.Derivation != "" && len(info.Name) > 1
+	if !ev.IsPreset() {
+		return r, nil // Only a preset's terms are native events.
+	}
+	for i, name := range info.Name {
+		if i < len(info.Code) {
+			r.Natives = append(r.Natives, nativeTerm{Name: name, Code: fmt.Sprintf("0x%08x", info.Code[i])})
+		}
+	}
+	return r, nil
+}
+
+// Try adding each available event to an event set and record whether
+// that succeeded.
+func checkEvents(events []eventReport, codes []papi.Event) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return err
+	}
+	defer es.DestroyEventSet()
+	for i := range events {
+		ok := false
+		if events[i].Available {
+			ok = es.AddEvent(codes[i]) == nil
+			if err = es.CleanupEventSet(); err != nil {
+				return err
+			}
+		}
+		events[i].Addable = &ok
+	}
+	return nil
+}
+
+// Return "Yes" or "No".
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// Write a human-readable description of the node and a table of
+// events.
+func writeTable(w io.Writer, host hostReport, events []eventReport) error {
+	fmt.Fprintf(w, "Host:                %s\n", host.Hostname)
+	fmt.Fprintf(w, "PAPI version:        %s\n", host.PAPIVersion)
+	fmt.Fprintf(w, "Vendor:              %s\n", host.Vendor)
+	fmt.Fprintf(w, "Model:               %s\n", host.Model)
+	fmt.Fprintf(w, "CPUID:               family %d, model %d, stepping %d\n",
+		host.CPUIDFamily, host.CPUIDModel, host.CPUIDStepping)
+	fmt.Fprintf(w, "CPUs:                %d\n", host.TotalCPUs)
+	fmt.Fprintf(w, "Hardware counters:   %d\n", host.Counters)
+	fmt.Fprintf(w, "Multiplex counters:  %d\n\n", host.MultiplexCounters)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	checked := len(events) > 0 && events[0].Addable != nil
+	fmt.Fprint(tw, "Name\tCode\tAvail\tDeriv\t")
+	if checked {
+		fmt.Fprint(tw, "Check\t")
+	}
+	fmt.Fprintln(tw, "Description (Note)")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t", e.Name, e.Code, yesNo(e.Available), yesNo(e.Derived))
+		if checked {
+			fmt.Fprintf(tw, "%s\t", yesNo(*e.Addable))
+		}
+		desc := e.Description
+		if e.Note != "" {
+			desc += " (" + e.Note + ")"
+		}
+		fmt.Fprintln(tw, desc)
+	}
+	return tw.Flush()
+}
+
+// Write one row per event in CSV format.
+func writeCSV(w io.Writer, events []eventReport) error {
+	cw := csv.NewWriter(w)
+	checked := len(events) > 0 && events[0].Addable != nil
+	header := []string{"name", "code", "available", "derived"}
+	if checked {
+		header = append(header, "addable")
+	}
+	cw.Write(append(header, "short_description", "description", "note"))
+	for _, e := range events {
+		row := []string{e.Name, e.Code, strconv.FormatBool(e.Available), strconv.FormatBool(e.Derived)}
+		if checked {
+			row = append(row, strconv.FormatBool(*e.Addable))
+		}
+		cw.Write(append(row, e.ShortDescr, e.Description, e.Note))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write a detailed description of a single event.
+func writeDetail(w io.Writer, e eventReport) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Event name:\t%s\n", e.Name)
+	fmt.Fprintf(tw, "Event code:\t%s\n", e.Code)
+	fmt.Fprintf(tw, "Available:\t%s\n", yesNo(e.Available))
+	if e.Addable != nil {
+		fmt.Fprintf(tw, "Addable:\t%s\n", yesNo(*e.Addable))
+	}
+	fmt.Fprintf(tw, "Short description:\t%s\n", e.ShortDescr)
+	fmt.Fprintf(tw, "Long description:\t%s\n", e.Description)
+	if e.Note != "" {
+		fmt.Fprintf(tw, "Developer's note:\t%s\n", e.Note)
+	}
+	if e.Derivation != "" {
+		fmt.Fprintf(tw, "Derived type:\t%s\n", e.Derivation)
+	}
+	if e.Postfix != "" {
+		fmt.Fprintf(tw, "Postfix:\t%s\n", e.Postfix)
+	}
+	if len(e.Natives) > 0 {
+		fmt.Fprintf(tw, "Native events:\t%d\n", len(e.Natives))
+		for i, n := range e.Natives {
+			fmt.Fprintf(tw, "  [%d]\t%s\t%s\n", i, n.Code, n.Name)
+		}
+	}
+	return tw.Flush()
+}
+
+// Write a value as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-avail: ")
+	availOnly := flag.Bool("a", false, "list only available events")
+	check := flag.Bool("check", false, "verify each available event by adding it to an event set")
+	asJSON := flag.Bool("json", false, "write JSON instead of a table")
+	asCSV := flag.Bool("csv", false, "write CSV instead of a table")
+	detail := flag.String("e", "", "describe a single `event` in detail")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || (*asJSON && *asCSV) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Determine which presets are available.
+	availList, err := papi.EnumEvents(papi.PRESET_MASK, papi.PRESET_ENUM_AVAIL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	available := make(map[papi.Event]bool, len(availList))
+	for _, ev := range availList {
+		available[ev] = true
+	}
+
+	// Describe a single event if requested.
+	if *detail != "" {
+		ev, err := papi.ParseEvent(*detail)
+		if err != nil {
+			log.Fatalf("%s: %v", *detail, err)
+		}
+		if !ev.IsPreset() {
+			available[ev] = true // Native and user-defined events that parse are available.
+		}
+		e, err := describeEvent(ev, available[ev])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *check {
+			events := []eventReport{e}
+			if err = checkEvents(events, []papi.Event{ev}); err != nil {
+				log.Fatal(err)
+			}
+			e = events[0]
+		}
+		if *asJSON {
+			err = writeJSON(os.Stdout, e)
+		} else {
+			err = writeDetail(os.Stdout, e)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Describe every preset event.
+	all, err := papi.EnumEvents(papi.PRESET_MASK, papi.ENUM_EVENTS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var events []eventReport
+	var codes []papi.Event
+	for _, ev := range all {
+		if *availOnly && !available[ev] {
+			continue
+		}
+		e, err := describeEvent(ev, available[ev])
+		if err != nil {
+			log.Fatal(err)
+		}
+		events = append(events, e)
+		codes = append(codes, ev)
+	}
+	if *check {
+		if err = checkEvents(events, codes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	switch {
+	case *asJSON:
+		err = writeJSON(os.Stdout, report{Host: describeHost(), Events: events})
+	case *asCSV:
+		err = writeCSV(os.Stdout, events)
+	default:
+		err = writeTable(os.Stdout, describeHost(), events)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,339 @@
+// papi-component-avail lists the components compiled into the PAPI
+// library, much as PAPI's own papi_component_avail utility does, and
+// shows which capabilities each component claims as a matrix.  It can
+// also probe several of those capabilities by exercising them.
+//
+// Usage:
+//
+//	papi-component-avail [-probe] [-json]
+//
+// For each component papi-component-avail reports its name, version,
+// number of counters with and without multiplexing, number of native
+// events, and, if the component is disabled, the reason.  The matrix
+// that follows has a row for each capability flag in
+// papi.ComponentInfo and a column for each component.
+//
+// The -probe option tests each enabled component's first native event
+// by counting it plainly (count), in a multiplexed event set
+// (multiplex), in this process via attach (attach), on CPU 0 (cpu),
+// and with inheritance (inherit), and reports each probe's outcome in
+// additional rows.  Probes that require elevated privileges may fail
+// even when the component supports the capability.  The -json option
+// writes a JSON array of components instead of tables.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+)
+
+// A capability is a boolean flag in papi.ComponentInfo.
+type capability struct {
+	name string                        // Name of the papi.ComponentInfo field
+	key  string                        // JSON key
+	has  func(papi.ComponentInfo) bool // Field accessor
+}
+
+// capabilities lists every capability flag in papi.ComponentInfo.
+var capabilities = []capability{
+	{"HardwareIntr", "hardware_intr", func(c papi.ComponentInfo) bool { return c.HardwareIntr }},
+	{"PreciseIntr", "precise_intr", func(c papi.ComponentInfo) bool { return c.PreciseIntr }},
+	{"POSIX1bTimers", "posix1b_timers", func(c papi.ComponentInfo) bool { return c.POSIX1bTimers }},
+	{"KernelProfile", "kernel_profile", func(c papi.ComponentInfo) bool { return c.KernelProfile }},
+	{"KernelMultiplex", "kernel_multiplex", func(c papi.ComponentInfo) bool { return c.KernelMultiplex }},
+	{"DataAddressRange", "data_address_range", func(c papi.ComponentInfo) bool { return c.DataAddressRange }},
+	{"InstrAddressRange", "instr_address_range", func(c papi.ComponentInfo) bool { return c.InstrAddressRange }},
+	{"FastCounterRead", "fast_counter_read", func(c papi.ComponentInfo) bool { return c.FastCounterRead }},
+	{"FastRealTimer", "fast_real_timer", func(c papi.ComponentInfo) bool { return c.FastRealTimer }},
+	{"FastVirtualTimer", "fast_virtual_timer", func(c papi.ComponentInfo) bool { return c.FastVirtualTimer }},
+	{"Attach", "attach", func(c papi.ComponentInfo) bool { return c.Attach }},
+	{"AttachMustPtrace", "attach_must_ptrace", func(c papi.ComponentInfo) bool { return c.AttachMustPtrace }},
+	{"CPU", "cpu", func(c papi.ComponentInfo) bool { return c.CPU }},
+	{"Inherit", "inherit", func(c papi.ComponentInfo) bool { return c.Inherit }},
+	{"EdgeDetect", "edge_detect", func(c papi.ComponentInfo) bool { return c.EdgeDetect }},
+	{"Invert", "invert", func(c papi.ComponentInfo) bool { return c.Invert }},
+	{"ProfileEAR", "profile_ear", func(c papi.ComponentInfo) bool { return c.ProfileEAR }},
+	{"CntrGroups", "cntr_groups", func(c papi.ComponentInfo) bool { return c.CntrGroups }},
+	{"CntrUmasks", "cntr_umasks", func(c papi.ComponentInfo) bool { return c.CntrUmasks }},
+	{"CntrIEAREvents", "cntr_iear_events", func(c papi.ComponentInfo) bool { return c.CntrIEAREvents }},
+	{"CntrDEAREvents", "cntr_dear_events", func(c papi.ComponentInfo) bool { return c.CntrDEAREvents }},
+	{"CntrOPCMEvents", "cntr_opcm_events", func(c papi.ComponentInfo) bool { return c.CntrOPCMEvents }},
+}
+
+// A probe exercises one capability of a component by preparing an
+// event set before an event is added to it.
+type probe struct {
+	name    string                                 // Name of the probe
+	prepare func(es papi.EventSet, cidx int) error // Function to configure the event set
+}
+
+// probes lists every capability that can be tested live.
+var probes = []probe{
+	{"count", func(es papi.EventSet, cidx int) error {
+		return es.AssignComponent(cidx)
+	}},
+	{"multiplex", func(es papi.EventSet, cidx int) error {
+		if err := es.AssignComponent(cidx); err != nil {
+			return err
+		}
+		return es.SetMultiplex()
+	}},
+	{"attach", func(es papi.EventSet, cidx int) error {
+		if err := es.AssignComponent(cidx); err != nil {
+			return err
+		}
+		return es.Attach(os.Getpid())
+	}},
+	{"cpu", func(es papi.EventSet, cidx int) error {
+		if err := es.AssignComponent(cidx); err != nil {
+			return err
+		}
+		return es.AttachCPU(0)
+	}},
+	{"inherit", func(es papi.EventSet, cidx int) error {
+		if err := es.AssignComponent(cidx); err != nil {
+			return err
+		}
+		return es.SetInherit()
+	}},
+}
+
+// A componentReport describes a single component.
+type componentReport struct {
+	Index             int               `json:"index"`                     // Component index
+	Name              string            `json:"name"`                      // Component name
+	ShortName         string            `json:"short_name"`                // Short name of the component
+	Description       string            `json:"description"`               // Description of the component
+	Version           string            `json:"version"`                   // Component version
+	SupportVersion    string            `json:"support_version"`           // Version of the support library
+	KernelVersion     string            `json:"kernel_version"`            // Version of the kernel driver
+	Counters          int               `json:"counters"`                  // Number of hardware counters
+	MultiplexCounters int               `json:"multiplex_counters"`        // Number of counters available with multiplexing
+	PresetEvents      int               `json:"preset_events"`             // Number of preset events
+	NativeEvents      int               `json:"native_events"`             // Number of native events
+	Disabled          bool              `json:"disabled"`                  // Whether the component is disabled
+	DisabledReason    string            `json:"disabled_reason,omitempty"` // Reason the component is disabled
+	Capabilities      map[string]bool   `json:"capabilities"`              // Capability flags by key
+	Probes            map[string]string `json:"probes,omitempty"`          // Outcome of each probe ("ok" or an error)
+}
+
+// Describe a component.
+func describeComponent(cidx int, info papi.ComponentInfo) componentReport {
+	c := componentReport{
+		Index:             cidx,
+		Name:              info.Name,
+		ShortName:         info.ShortName,
+		Description:       info.Description,
+		Version:           info.Version,
+		SupportVersion:    info.SupportVersion,
+		KernelVersion:     info.KernelVersion,
+		Counters:          info.NumCntrs,
+		MultiplexCounters: info.NumMpxCntrs,
+		PresetEvents:      info.NumPresetEvents,
+		NativeEvents:      info.NumNativeEvents,
+		Disabled:          info.Disabled,
+		DisabledReason:    info.DisabledReason,
+		Capabilities:      make(map[string]bool, len(capabilities)),
+	}
+	for _, cp := range capabilities {
+		c.Capabilities[cp.key] = cp.has(info)
+	}
+	return c
+}
+
+// Run a single probe with a given event and return its outcome.
+func runProbe(p probe, cidx int, ev papi.Event) (err error) {
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return err
+	}
+	running := false
+	defer func() {
+		// PAPI refuses to clean up a running event set, so try once
+		// more to stop one whose first Stop() failed.
+		if running {
+			es.Stop(make([]int64, 1))
+		}
+		es.CleanupEventSet()
+		es.DestroyEventSet()
+	}()
+	if err = p.prepare(es, cidx); err != nil {
+		return err
+	}
+	if err = es.AddEvent(ev); err != nil {
+		return err
+	}
+	if err = es.Start(); err != nil {
+		return err
+	}
+	running = true
+	if err = es.Stop(make([]int64, 1)); err != nil {
+		return err
+	}
+	running = false
+	return nil
+}
+
+// Run every probe against a component.  The caller must be locked to
+// its OS thread.
+func probeComponent(c *componentReport) {
+	c.Probes = make(map[string]string, len(probes))
+	skip := func(reason string) {
+		for _, p := range probes {
+			c.Probes[p.name] = reason
+		}
+	}
+	if c.Disabled {
+		skip("skipped: component is disabled")
+		return
+	}
+	events, err := papi.EnumEvents(papi.NATIVE_MASK|papi.ComponentMask(c.Index), papi.ENUM_EVENTS)
+	if err == nil && len(events) == 0 {
+		err = papi.ENOEVNT
+	}
+	if err != nil {
+		skip("skipped: " + err.Error())
+		return
+	}
+	for _, p := range probes {
+		if err := runProbe(p, c.Index, events[0]); err != nil {
+			c.Probes[p.name] = err.Error()
+		} else {
+			c.Probes[p.name] = "ok"
+		}
+	}
+}
+
+// ----------------------------------------------------------------------
+
+// Write a summary of each component followed by a capability matrix.
+func writeTables(w io.Writer, comps []componentReport) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Index\tName\tVersion\tCounters\tMultiplex\tNative events\tStatus")
+	for _, c := range comps {
+		status := "enabled"
+		if c.Disabled {
+			status = "disabled"
+			if c.DisabledReason != "" {
+				status += ": " + c.DisabledReason
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			c.Index, c.Name, c.Version, c.Counters, c.MultiplexCounters, c.NativeEvents, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Write the matrix, with a column per component.
+	fmt.Fprintln(w)
+	fmt.Fprint(tw, "Capability")
+	for _, c := range comps {
+		fmt.Fprintf(tw, "\t%d", c.Index)
+	}
+	fmt.Fprintln(tw)
+	for _, cp := range capabilities {
+		fmt.Fprint(tw, cp.name)
+		for _, c := range comps {
+			mark := "-"
+			if c.Capabilities[cp.key] {
+				mark = "yes"
+			}
+			fmt.Fprintf(tw, "\t%s", mark)
+		}
+		fmt.Fprintln(tw)
+	}
+	if comps[0].Probes != nil {
+		for _, p := range probes {
+			fmt.Fprintf(tw, "probe: %s", p.name)
+			for _, c := range comps {
+				mark := "FAIL"
+				switch outcome := c.Probes[p.name]; {
+				case outcome == "ok":
+					mark = "ok"
+				case strings.HasPrefix(outcome, "skipped"):
+					mark = "n/a"
+				}
+				fmt.Fprintf(tw, "\t%s", mark)
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Explain each failed probe.
+	for _, c := range comps {
+		for _, p := range probes {
+			if outcome, ok := c.Probes[p.name]; ok && outcome != "ok" && !strings.HasPrefix(outcome, "skipped") {
+				fmt.Fprintf(w, "\n%s probe of %s failed: %s", p.name, c.Name, outcome)
+			}
+		}
+	}
+	if comps[0].Probes != nil {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-component-avail: ")
+	doProbe := flag.Bool("probe", false, "test capabilities by exercising them")
+	asJSON := flag.Bool("json", false, "write JSON instead of tables")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Describe and optionally probe each component.
+	if *doProbe {
+		runtime.LockOSThread()
+		if err := papi.InitMultiplex(); err != nil {
+			log.Printf("multiplexing is unavailable: %v", err)
+		}
+	}
+	var comps []componentReport
+	for cidx := 0; cidx < papi.GetNumComponents(); cidx++ {
+		info, err := papi.GetComponentInfo(cidx)
+		if err != nil {
+			log.Fatalf("component %d: %v", cidx, err)
+		}
+		c := describeComponent(cidx, info)
+		if *doProbe {
+			probeComponent(&c)
+		}
+		comps = append(comps, c)
+	}
+	if len(comps) == 0 {
+		log.Fatal("no components are available")
+	}
+
+	// Output the results.
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(comps)
+	} else {
+		err = writeTables(os.Stdout, comps)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,314 @@
+// papi-cost measures the cost of the PAPI operations that bracket or
+// interrupt a measurement—starting, stopping, reading, and resetting
+// an event set and reading the clocks—and the bias that this overhead
+// introduces into the counts of events such as PAPI_TOT_INS.
+//
+// Usage:
+//
+//	papi-cost [-n iterations] [-e event,...] [-json] [-o calibration.json]
+//
+// Each operation is timed individually with PAPI_get_real_cyc() the
+// given number of times.  papi-cost reports the minimum, median, 99th
+// percentile, and mean cost of each operation in cycles, after
+// subtracting the median cost of reading the cycle counter itself.
+// The cheapest operations can therefore show slightly negative costs.
+//
+// For each event named by -e (by default PAPI_TOT_INS and
+// PAPI_TOT_CYC), papi-cost also reports the median count attributed
+// to an empty region bracketed by Start() and Stop(), as in
+// harness.Run(), and to an empty interval between two consecutive
+// calls to Read().  These are the amounts by which every measurement
+// of those events is inflated.  The -o option writes the Start/Stop
+// overhead as a results.Calibration, which harness.Run() subtracts
+// from every run when given it in its Config.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/harness"
+	"github.com/lanl/go-papi/harness/results"
+	"github.com/lanl/go-papi/internal/cmdutil"
+)
+
+// An opCost summarizes the cost of a single operation.
+type opCost struct {
+	Op     string  `json:"op"`     // Name of the operation
+	Runs   int     `json:"runs"`   // Number of times the operation was timed
+	Min    int64   `json:"min"`    // Minimum cost in cycles
+	Median int64   `json:"median"` // Median cost in cycles
+	P99    int64   `json:"p99"`    // 99th-percentile cost in cycles
+	Mean   float64 `json:"mean"`   // Mean cost in cycles
+}
+
+// A bias reports how much measurement overhead inflates an event's
+// counts.
+type bias struct {
+	Event     string `json:"event"`      // Name of the event
+	StartStop int64  `json:"start_stop"` // Median count of an empty Start/Stop region
+	ReadRead  int64  `json:"read_read"`  // Median count between two consecutive reads
+}
+
+// A report is everything that papi-cost measures.
+type report struct {
+	TimerCycles int64                `json:"timer_cycles"` // Median cost of reading the cycle counter, subtracted from every cost
+	Ops         []opCost             `json:"ops"`          // Cost of each operation
+	Biases      []bias               `json:"biases"`       // Bias of each event
+	Calibration *results.Calibration `json:"calibration"`  // Start/Stop overhead in a form usable by the harness
+}
+
+// Summarize a list of costs in cycles, which is sorted in place.
+func summarize(op string, cycles []int64) opCost {
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i] < cycles[j] })
+	n := len(cycles)
+	sum := 0.0
+	for _, c := range cycles {
+		sum += float64(c)
+	}
+	return opCost{
+		Op:     op,
+		Runs:   n,
+		Min:    cycles[0],
+		Median: cycles[n/2],
+		P99:    cycles[int(math.Ceil(0.99*float64(n)))-1],
+		Mean:   sum / float64(n),
+	}
+}
+
+// Time an operation n times after n/10 untimed warm-up calls and return
+// the cost of each timed call in cycles, less a given timer overhead.
+// The setup function, if non-nil, is called untimed before each call.
+// Timing stops at the first error returned by setup or the operation.
+func timeOp(n int, timer int64, setup, op func() error) ([]int64, error) {
+	for i := 0; i < n/10; i++ {
+		if setup != nil {
+			if err := setup(); err != nil {
+				return nil, err
+			}
+		}
+		if err := op(); err != nil {
+			return nil, err
+		}
+	}
+	cycles := make([]int64, n)
+	for i := range cycles {
+		if setup != nil {
+			if err := setup(); err != nil {
+				return nil, err
+			}
+		}
+		t0 := papi.GetRealCyc()
+		err := op()
+		t1 := papi.GetRealCyc()
+		if err != nil {
+			return nil, err
+		}
+		cycles[i] = t1 - t0 - timer
+	}
+	return cycles, nil
+}
+
+// Measure the cost of every operation using an event set that counts
+// the given events.
+func measureOps(n int, events []papi.Event) (int64, []opCost, error) {
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer es.DestroyEventSet()
+	defer es.CleanupEventSet()
+	if err = es.AddEvents(events); err != nil {
+		return 0, nil, err
+	}
+	values := make([]int64, len(events))
+
+	// Determine the cost of the timer itself.
+	noop := func() error { return nil }
+	cycles, err := timeOp(n, 0, nil, noop)
+	if err != nil {
+		return 0, nil, err
+	}
+	timer := summarize("", cycles).Median
+
+	// Time each operation, starting and stopping the event set as each
+	// requires.  Once anything fails, the rest is skipped and the error
+	// is returned.
+	var ops []opCost
+	var opErr error
+	add := func(name string, setup, op func() error) {
+		if opErr != nil {
+			return
+		}
+		cycles, err := timeOp(n, timer, setup, op)
+		if err != nil {
+			opErr = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		ops = append(ops, summarize(name, cycles))
+	}
+	do := func(f func() error) {
+		if opErr == nil {
+			opErr = f()
+		}
+	}
+	start := func() error { return es.Start() }
+	stop := func() error { return es.Stop(values) }
+	add("GetRealCyc", nil, func() error { papi.GetRealCyc(); return nil })
+	add("GetRealUsec", nil, func() error { papi.GetRealUsec(); return nil })
+	add("GetVirtCyc", nil, func() error { papi.GetVirtCyc(); return nil })
+	add("GetVirtUsec", nil, func() error { papi.GetVirtUsec(); return nil })
+	do(start)
+	add("Start", stop, start)
+	do(stop)
+	add("Stop", start, stop)
+	do(start)
+	add("Read", nil, func() error { return es.Read(values) })
+	add("Reset", nil, func() error { return es.Reset() })
+	do(stop)
+	add("Event.String", nil, func() error { _ = events[0].String(); return nil })
+	name := events[0].String()
+	add("ParseEvent", nil, func() error { _, err := papi.ParseEvent(name); return err })
+	if opErr != nil {
+		return 0, nil, opErr
+	}
+	return timer, ops, nil
+}
+
+// Return the median count of each event between n pairs of consecutive
+// reads of a running event set.
+func readBias(n int, events []papi.Event) ([]int64, error) {
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return nil, err
+	}
+	defer es.DestroyEventSet()
+	defer es.CleanupEventSet()
+	if err = es.AddEvents(events); err != nil {
+		return nil, err
+	}
+	if err = es.Start(); err != nil {
+		return nil, err
+	}
+	defer es.Stop(make([]int64, len(events)))
+	before := make([]int64, len(events))
+	after := make([]int64, len(events))
+	deltas := make([][]int64, len(events))
+	for i := 0; i < n; i++ {
+		if err = es.Read(before); err != nil {
+			return nil, err
+		}
+		if err = es.Read(after); err != nil {
+			return nil, err
+		}
+		for e := range events {
+			deltas[e] = append(deltas[e], after[e]-before[e])
+		}
+	}
+	medians := make([]int64, len(events))
+	for e, ds := range deltas {
+		medians[e] = summarize("", ds).Median
+	}
+	return medians, nil
+}
+
+// Write a report as a set of readable tables.
+func writeReport(w io.Writer, rep *report) error {
+	fmt.Fprintf(w, "Cycle-counter overhead of %d cycles subtracted from every cost\n\n", rep.TimerCycles)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Operation\tRuns\tMin\tMedian\tP99\tMean\t")
+	for _, c := range rep.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.1f\t\n", c.Op, c.Runs, c.Min, c.Median, c.P99, c.Mean)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Event\tStart/Stop bias\tRead/Read bias\t")
+	for _, b := range rep.Biases {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", b.Event, b.StartStop, b.ReadRead)
+	}
+	return tw.Flush()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-cost: ")
+	names := cmdutil.List{"PAPI_TOT_INS", "PAPI_TOT_CYC"}
+	flag.Var(&names, "e", "comma-separated `events` whose bias to measure")
+	n := flag.Int("n", 10000, "number of `iterations` per operation")
+	asJSON := flag.Bool("json", false, "write JSON instead of tables")
+	calFile := flag.String("o", "", "write the Start/Stop overhead to `file` as a harness calibration")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-n iterations] [-e events] [-json] [-o calibration.json]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || *n < 1 || len(names) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var events []papi.Event
+	for _, name := range names {
+		ev, err := papi.ParseEvent(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		events = append(events, ev)
+	}
+
+	// Measure on a single OS thread, as PAPI counts per thread.
+	runtime.LockOSThread()
+	rep := &report{}
+	var err error
+	rep.TimerCycles, rep.Ops, err = measureOps(*n, events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rep.Calibration, err = harness.Calibrate(events, &harness.Config{Runs: *n})
+	if err != nil {
+		log.Fatal(err)
+	}
+	reads, err := readBias(*n, events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for e, name := range rep.Calibration.Events {
+		rep.Biases = append(rep.Biases, bias{Event: name, StartStop: rep.Calibration.Counts[e], ReadRead: reads[e]})
+	}
+
+	// Report what was measured.
+	if *calFile != "" {
+		f, err := os.Create(*calFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = rep.Calibration.Write(f); err != nil {
+			log.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rep)
+	} else {
+		err = writeReport(os.Stdout, rep)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
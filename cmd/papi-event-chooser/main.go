@@ -0,0 +1,470 @@
+// papi-event-chooser finds events that can be counted together, much as
+// PAPI's own papi_event_chooser utility does, by probing which events
+// can be added to an event set that already contains a chosen set.
+//
+// Usage:
+//
+//	papi-event-chooser [-native] [-component name|index] [-umasks]
+//	                   [-start] [-json] [event ...]
+//	papi-event-chooser -maximize event,... [-start] [-json] [event ...]
+//
+// In its first form, papi-event-chooser adds the events named on the
+// command line to an event set and lists every other event that can
+// still be added alongside them.  By default the candidates are the
+// available preset events.  The -native option instead considers the
+// native events of the component that provides the chosen events or,
+// if none are chosen, of the component named by -component (by
+// default, component 0).  The -umasks option also considers each
+// native event's unit masks and other qualifiers.
+//
+// In its second form, papi-event-chooser searches for the largest
+// subset of a comma-separated list of desired events that can be
+// counted together with the events named on the command line.  Events
+// earlier in the list are preferred to later ones.  The search adds
+// events greedily and then repeatedly tries to make room for two
+// rejected events by dropping one accepted event, so it is fast but
+// not guaranteed to find the largest subset.
+//
+// Some conflicts are detected only when counting starts.  The -start
+// option starts and stops the event set after every successful
+// addition and treats a failure to start as a conflict.  The -json
+// option writes the results as JSON.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/cmdutil"
+)
+
+// A candidate describes an event that can be added to the chosen set.
+type candidate struct {
+	Name        string `json:"name"`        // Name of the event
+	Code        string `json:"code"`        // Event code in hexadecimal
+	Description string `json:"description"` // Description of the event
+}
+
+// A listing reports every candidate that is compatible with the
+// chosen events.
+type listing struct {
+	Chosen     []string    `json:"chosen"`     // Names of the chosen events
+	Tried      int         `json:"tried"`      // Number of candidates probed
+	Compatible []candidate `json:"compatible"` // Candidates that can be added to the chosen events
+}
+
+// A rejection reports why a desired event could not be counted.
+type rejection struct {
+	Name   string `json:"name"`   // Name of the event
+	Reason string `json:"reason"` // Error encountered adding the event
+}
+
+// A selection reports the largest compatible subset of the desired
+// events that was found.
+type selection struct {
+	Chosen   []string    `json:"chosen"`   // Names of the chosen events, which are always counted
+	Selected []string    `json:"selected"` // Names of the desired events that can be counted with them
+	Rejected []rejection `json:"rejected"` // Desired events that cannot, and why
+}
+
+// A prober tests whether events can be counted together.
+type prober struct {
+	es     papi.EventSet // Event set used for probing
+	base   []papi.Event  // Events currently in the event set
+	start  bool          // Whether to start the event set to check for conflicts
+	values []int64       // Buffer for stopping the event set
+}
+
+// Create a prober whose event set contains the given events.
+func newProber(base []papi.Event, start bool) (*prober, error) {
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return nil, err
+	}
+	p := &prober{es: es, start: start}
+	if err = p.reset(base); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Replace the events in the prober's event set.
+func (p *prober) reset(base []papi.Event) error {
+	if err := p.es.CleanupEventSet(); err != nil {
+		return err
+	}
+	p.base = nil
+	for _, ev := range base {
+		if err := p.add(ev); err != nil {
+			return fmt.Errorf("%s: %w", ev, err)
+		}
+	}
+	return nil
+}
+
+// Add an event to the prober's event set, returning an error and
+// leaving the event set unchanged if the event conflicts.
+func (p *prober) add(ev papi.Event) error {
+	if err := p.es.AddEvent(ev); err != nil {
+		return err
+	}
+	if p.start {
+		if len(p.values) < len(p.base)+1 {
+			p.values = make([]int64, 2*(len(p.base)+1))
+		}
+		err := p.es.Start()
+		if err == nil {
+			err = p.es.Stop(p.values[:len(p.base)+1])
+		}
+		if err != nil {
+			p.es.RemoveEvent(ev)
+			return err
+		}
+	}
+	p.base = append(p.base, ev)
+	return nil
+}
+
+// Report why an event cannot be added to the prober's event set, or
+// nil if it can, without adding it.  A non-nil second error indicates
+// that the event set could not be restored.
+func (p *prober) fits(ev papi.Event) (conflict, err error) {
+	if conflict = p.add(ev); conflict != nil {
+		return conflict, nil
+	}
+	p.base = p.base[:len(p.base)-1]
+	return nil, p.es.RemoveEvent(ev)
+}
+
+// Release the prober's event set.
+func (p *prober) close() {
+	p.es.CleanupEventSet()
+	p.es.DestroyEventSet()
+}
+
+// Return the names of a list of events.
+func names(events []papi.Event) []string {
+	ns := make([]string, len(events))
+	for i, ev := range events {
+		ns[i], _ = cmdutil.Describe(ev)
+	}
+	return ns
+}
+
+// Resolve a component name or index to an index.
+func findComponent(name string) (int, error) {
+	for cidx := 0; cidx < papi.GetNumComponents(); cidx++ {
+		info, err := papi.GetComponentInfo(cidx)
+		if err != nil {
+			return 0, fmt.Errorf("component %d: %w", cidx, err)
+		}
+		if name == info.Name || name == strconv.Itoa(cidx) {
+			return cidx, nil
+		}
+	}
+	return 0, fmt.Errorf("no component is named %q", name)
+}
+
+// Return the native events of a component, optionally including every
+// unit mask and other qualifier.
+func nativeEvents(cidx int, umasks bool) ([]papi.Event, error) {
+	codes, err := papi.EnumEvents(papi.NATIVE_MASK|papi.ComponentMask(cidx), papi.ENUM_EVENTS)
+	if err != nil || !umasks {
+		return codes, err
+	}
+	var events []papi.Event
+	for _, ev := range codes {
+		events = append(events, ev)
+		// Components that cannot enumerate qualifiers report an error,
+		// which means only that the event has none.
+		ums, _ := papi.EnumUmasks(ev)
+		events = append(events, ums...)
+	}
+	return events, nil
+}
+
+// List every candidate that can be added to the chosen events.
+func list(chosen, candidates []papi.Event, start bool) (*listing, error) {
+	p, err := newProber(chosen, start)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+	lst := &listing{Chosen: names(chosen), Compatible: []candidate{}}
+	skip := make(map[papi.Event]bool)
+	for _, ev := range chosen {
+		skip[ev] = true
+	}
+	for _, ev := range candidates {
+		if skip[ev] {
+			continue
+		}
+		skip[ev] = true
+		lst.Tried++
+		conflict, err := p.fits(ev)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ev, err)
+		}
+		if conflict == nil {
+			name, desc := cmdutil.Describe(ev)
+			lst.Compatible = append(lst.Compatible, candidate{Name: name, Code: cmdutil.HexCode(ev), Description: desc})
+		}
+	}
+	return lst, nil
+}
+
+// Add as many of the desired events as possible, in order, to the
+// chosen events and return those added.
+func greedy(p *prober, chosen, desired []papi.Event) ([]papi.Event, error) {
+	if err := p.reset(chosen); err != nil {
+		return nil, err
+	}
+	var added []papi.Event
+	for _, ev := range desired {
+		if p.add(ev) == nil {
+			added = append(added, ev)
+		}
+	}
+	return added, nil
+}
+
+// Search for the largest subset of the desired events that can be
+// counted with the chosen events.
+func maximize(chosen, desired []papi.Event, start bool) (*selection, error) {
+	p, err := newProber(chosen, start)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	// Discard duplicates and events that are already chosen.
+	seen := make(map[papi.Event]bool)
+	for _, ev := range chosen {
+		seen[ev] = true
+	}
+	var want []papi.Event
+	for _, ev := range desired {
+		if !seen[ev] {
+			seen[ev] = true
+			want = append(want, ev)
+		}
+	}
+
+	// Add events greedily, then try to improve the result by dropping
+	// each accepted event in turn and refilling greedily.
+	best, err := greedy(p, chosen, want)
+	if err != nil {
+		return nil, err
+	}
+	for improved := true; improved; {
+		improved = false
+		for _, drop := range best {
+			var rest []papi.Event
+			for _, ev := range want {
+				if ev != drop {
+					rest = append(rest, ev)
+				}
+			}
+			added, err := greedy(p, chosen, rest)
+			if err != nil {
+				return nil, err
+			}
+			if len(added) > len(best) {
+				best = added
+				improved = true
+				break
+			}
+		}
+	}
+
+	// Report the result in the order in which events were desired,
+	// explaining each rejection against the final event set.
+	inBest := make(map[papi.Event]bool)
+	for _, ev := range best {
+		inBest[ev] = true
+	}
+	var final []papi.Event
+	for _, ev := range want {
+		if inBest[ev] {
+			final = append(final, ev)
+		}
+	}
+	if err = p.reset(append(append([]papi.Event(nil), chosen...), final...)); err != nil {
+		return nil, err
+	}
+	sel := &selection{Chosen: names(chosen), Selected: names(final), Rejected: []rejection{}}
+	for _, ev := range want {
+		if inBest[ev] {
+			continue
+		}
+		name, _ := cmdutil.Describe(ev)
+		conflict, err := p.fits(ev)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ev, err)
+		}
+		reason := "not selected"
+		if conflict != nil {
+			reason = conflict.Error()
+		}
+		sel.Rejected = append(sel.Rejected, rejection{Name: name, Reason: reason})
+	}
+	return sel, nil
+}
+
+// Write a listing as a table.
+func writeListing(w io.Writer, lst *listing) error {
+	if len(lst.Chosen) == 0 {
+		fmt.Fprintln(w, "No events chosen")
+	} else {
+		fmt.Fprintf(w, "Chosen events: %s\n", strings.Join(lst.Chosen, ", "))
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Event\tCode\tDescription")
+	for _, c := range lst.Compatible {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, c.Code, c.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d of %d events can be added\n", len(lst.Compatible), lst.Tried)
+	return nil
+}
+
+// Write a selection as a list of events.
+func writeSelection(w io.Writer, sel *selection) error {
+	if len(sel.Chosen) > 0 {
+		fmt.Fprintf(w, "Chosen events: %s\n", strings.Join(sel.Chosen, ", "))
+	}
+	fmt.Fprintf(w, "Selected %d of %d desired events:\n", len(sel.Selected), len(sel.Selected)+len(sel.Rejected))
+	for _, name := range sel.Selected {
+		fmt.Fprintf(w, "    %s\n", name)
+	}
+	if len(sel.Rejected) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "Rejected:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, r := range sel.Rejected {
+		fmt.Fprintf(tw, "    %s\t%s\n", r.Name, r.Reason)
+	}
+	return tw.Flush()
+}
+
+// Convert a list of event names to events.
+func parseEvents(ns []string) ([]papi.Event, error) {
+	var events []papi.Event
+	for _, name := range ns {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ev, err := papi.ParseEvent(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-event-chooser: ")
+	native := flag.Bool("native", false, "consider native events instead of preset events")
+	compName := flag.String("component", "", "consider the native events of the component with the given `name or index`")
+	umasks := flag.Bool("umasks", false, "also consider the unit masks of native events")
+	var desired cmdutil.List
+	flag.Var(&desired, "maximize", "find the largest subset of the comma-separated `events` that can be counted together")
+	start := flag.Bool("start", false, "start the event set to detect conflicts that adding events does not")
+	asJSON := flag.Bool("json", false, "write JSON instead of a table")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-native] [-component name|index] [-umasks] [-start] [-json] [event ...]\n       %s -maximize events [-start] [-json] [event ...]\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if desired != nil && (*native || *compName != "" || *umasks) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	chosen, err := parseEvents(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Probe on a single OS thread, as event sets belong to threads.
+	runtime.LockOSThread()
+	var result interface{}
+	if desired != nil {
+		want, err := parseEvents(desired)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if result, err = maximize(chosen, want, *start); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		var candidates []papi.Event
+		if *native || *compName != "" || *umasks {
+			cidx := -1
+			if *compName != "" {
+				if cidx, err = findComponent(*compName); err != nil {
+					log.Fatal(err)
+				}
+			}
+			for _, ev := range chosen {
+				c, err := papi.GetEventComponent(ev)
+				switch {
+				case err != nil:
+					log.Fatalf("%s: %v", ev, err)
+				case cidx < 0:
+					cidx = c
+				case c != cidx:
+					log.Fatalf("%s: not provided by component %d, so it cannot share an event set", ev, cidx)
+				}
+			}
+			if cidx < 0 {
+				cidx = 0
+			}
+			candidates, err = nativeEvents(cidx, *umasks)
+		} else {
+			candidates, err = papi.EnumEvents(papi.PRESET_MASK, papi.PRESET_ENUM_AVAIL)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if result, err = list(chosen, candidates, *start); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Output the results.
+	switch r := result.(type) {
+	case *listing:
+		if !*asJSON {
+			err = writeListing(os.Stdout, r)
+		}
+	case *selection:
+		if !*asJSON {
+			err = writeSelection(os.Stdout, r)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,366 @@
+// papi-hwinfo reports a node's hardware as PAPI sees it: its CPU, its
+// topology of sockets, cores, hardware threads, and NUMA nodes, and
+// each level of its memory hierarchy of caches and TLBs.  It can also
+// compare nodes, which helps verify that the nodes of a heterogeneous
+// cluster partition match the partition's specification.
+//
+// Usage:
+//
+//	papi-hwinfo [-json]
+//	papi-hwinfo -diff [-ignore field,...] spec.json [other.json]
+//
+// By default, papi-hwinfo writes a readable summary of the node.  The
+// -json option instead writes the same information as JSON, which
+// can be saved as a specification.  The -diff option compares two
+// such JSON files, or a single JSON file with the current node, field
+// by field and reports every difference.  Fields that legitimately
+// vary from node to node are ignored; by default these are hostname
+// and mhz.  The -ignore option replaces that list with a
+// comma-separated list of field names (e.g., "hostname,mhz,revision")
+// or paths (e.g., "memory_hierarchy[0].associativity").
+//
+// With -diff, papi-hwinfo exits with status 1 if the nodes differ and
+// with status 2 on error.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+)
+
+// A memReport describes a single cache or TLB.
+type memReport struct {
+	Level         int    `json:"level"`                 // Level of the memory hierarchy, starting from 1
+	Kind          string `json:"kind"`                  // "cache" or "tlb"
+	Type          string `json:"type"`                  // Attributes as formatted by MHAttrs.String()
+	Size          int32  `json:"size,omitempty"`        // Cache size in bytes
+	LineSize      int32  `json:"line_size,omitempty"`   // Cache line size in bytes
+	NumLines      int32  `json:"num_lines,omitempty"`   // Number of cache lines
+	NumEntries    int32  `json:"num_entries,omitempty"` // Number of TLB entries
+	PageSize      int32  `json:"page_size,omitempty"`   // TLB page size in bytes
+	Associativity string `json:"associativity"`         // "N-way", "full", or "unknown"
+}
+
+// A hostReport describes a node's hardware.
+type hostReport struct {
+	Hostname        string      `json:"hostname"`         // Name of the node
+	Vendor          string      `json:"vendor"`           // CPU vendor
+	VendorID        int32       `json:"vendor_id"`        // CPU vendor number
+	Model           string      `json:"model"`            // CPU model
+	ModelID         int32       `json:"model_id"`         // CPU model number
+	Revision        float32     `json:"revision"`         // CPU revision
+	CPUIDFamily     int32       `json:"cpuid_family"`     // CPUID family
+	CPUIDModel      int32       `json:"cpuid_model"`      // CPUID model
+	CPUIDStepping   int32       `json:"cpuid_stepping"`   // CPUID stepping
+	Sockets         int32       `json:"sockets"`          // Number of sockets
+	CoresPerSocket  int32       `json:"cores_per_socket"` // Number of cores per socket
+	ThreadsPerCore  int32       `json:"threads_per_core"` // Number of hardware threads per core
+	NUMANodes       int32       `json:"numa_nodes"`       // Number of NUMA nodes
+	CPUsPerNode     int32       `json:"cpus_per_node"`    // Number of CPUs per NUMA node
+	TotalCPUs       int32       `json:"total_cpus"`       // Number of CPUs in the node
+	MHz             float32     `json:"mhz"`              // Current clock rate in megahertz
+	ClockMHz        int32       `json:"clock_mhz"`        // Cycle counter's clock rate in megahertz
+	MinMHz          int32       `json:"min_mhz"`          // Minimum supported clock rate in megahertz
+	MaxMHz          int32       `json:"max_mhz"`          // Maximum supported clock rate in megahertz
+	MemoryHierarchy []memReport `json:"memory_hierarchy"` // Every cache and TLB, level by level
+}
+
+// Format an associativity.
+func associativity(a int32) string {
+	switch a {
+	case 0:
+		return "unknown"
+	case papi.FullyAssociative:
+		return "full"
+	default:
+		return fmt.Sprintf("%d-way", a)
+	}
+}
+
+// Describe the current node.
+func describeHost() hostReport {
+	hw := papi.GetHardwareInfo()
+	h := hostReport{
+		Vendor:          hw.VendorName,
+		VendorID:        hw.Vendor,
+		Model:           hw.ModelName,
+		ModelID:         hw.Model,
+		Revision:        hw.Revision,
+		CPUIDFamily:     hw.CPUIDFamily,
+		CPUIDModel:      hw.CPUIDModel,
+		CPUIDStepping:   hw.CPUIDStepping,
+		Sockets:         hw.Sockets,
+		CoresPerSocket:  hw.Cores,
+		ThreadsPerCore:  hw.Threads,
+		NUMANodes:       hw.NUMANodes,
+		CPUsPerNode:     hw.CPUs,
+		TotalCPUs:       hw.TotalCPUs,
+		MHz:             hw.MHz,
+		ClockMHz:        hw.ClockMHz,
+		MinMHz:          hw.MinMHz,
+		MaxMHz:          hw.MaxMHz,
+		MemoryHierarchy: []memReport{},
+	}
+	h.Hostname, _ = os.Hostname()
+	for i, lvl := range hw.MemHierarchy {
+		for _, c := range lvl.Cache {
+			h.MemoryHierarchy = append(h.MemoryHierarchy, memReport{
+				Level:         i + 1,
+				Kind:          "cache",
+				Type:          c.Type.String(),
+				Size:          c.Size,
+				LineSize:      c.LineSize,
+				NumLines:      c.NumLines,
+				Associativity: associativity(c.Associativity),
+			})
+		}
+		for _, t := range lvl.TLB {
+			h.MemoryHierarchy = append(h.MemoryHierarchy, memReport{
+				Level:         i + 1,
+				Kind:          "tlb",
+				Type:          t.Type.String(),
+				NumEntries:    t.NumEntries,
+				PageSize:      t.PageSize,
+				Associativity: associativity(t.Associativity),
+			})
+		}
+	}
+	return h
+}
+
+// Format a size in bytes using binary prefixes when it divides evenly.
+func formatBytes(n int32) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%d GiB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// Write a readable summary of a node.
+func writeSummary(w io.Writer, h hostReport) error {
+	fmt.Fprintf(w, "Host:              %s\n", h.Hostname)
+	fmt.Fprintf(w, "Vendor:            %s (%d)\n", h.Vendor, h.VendorID)
+	fmt.Fprintf(w, "Model:             %s (%d), revision %g\n", h.Model, h.ModelID, h.Revision)
+	fmt.Fprintf(w, "CPUID:             family %d, model %d, stepping %d\n", h.CPUIDFamily, h.CPUIDModel, h.CPUIDStepping)
+	fmt.Fprintf(w, "Sockets:           %d\n", h.Sockets)
+	fmt.Fprintf(w, "Cores per socket:  %d\n", h.CoresPerSocket)
+	fmt.Fprintf(w, "Threads per core:  %d\n", h.ThreadsPerCore)
+	fmt.Fprintf(w, "NUMA nodes:        %d\n", h.NUMANodes)
+	fmt.Fprintf(w, "CPUs per node:     %d\n", h.CPUsPerNode)
+	fmt.Fprintf(w, "Total CPUs:        %d\n", h.TotalCPUs)
+	fmt.Fprintf(w, "Clock rate:        %g MHz (minimum %d MHz, maximum %d MHz)\n", h.MHz, h.MinMHz, h.MaxMHz)
+	fmt.Fprintf(w, "Cycle counter:     %d MHz\n", h.ClockMHz)
+
+	// Write a table of caches followed by a table of TLBs.
+	fmt.Fprintln(w, "\nCaches:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  Level\tType\tSize\tLine size\tLines\tAssociativity")
+	for _, m := range h.MemoryHierarchy {
+		if m.Kind == "cache" {
+			fmt.Fprintf(tw, "  L%d\t%s\t%s\t%d B\t%d\t%s\n",
+				m.Level, m.Type, formatBytes(m.Size), m.LineSize, m.NumLines, m.Associativity)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nTLBs:")
+	fmt.Fprintln(tw, "  Level\tType\tEntries\tPage size\tAssociativity")
+	for _, m := range h.MemoryHierarchy {
+		if m.Kind == "tlb" {
+			fmt.Fprintf(tw, "  L%d\t%s\t%d\t%s\t%s\n",
+				m.Level, m.Type, m.NumEntries, formatBytes(m.PageSize), m.Associativity)
+		}
+	}
+	return tw.Flush()
+}
+
+// ----------------------------------------------------------------------
+
+// Flatten a decoded JSON value into a map from each leaf's path (e.g.,
+// "memory_hierarchy[1].size") to its value.
+func flatten(prefix string, v interface{}, leaves map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flatten(path, sub, leaves)
+		}
+	case []interface{}:
+		for i, sub := range v {
+			flatten(fmt.Sprintf("%s[%d]", prefix, i), sub, leaves)
+		}
+	default:
+		leaves[prefix] = v
+	}
+}
+
+// Return the name of the field at the end of a path.
+func fieldName(path string) string {
+	if i := strings.LastIndexAny(path, ".]"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// Return a key that sorts paths with list indexes in numerical order.
+func sortKey(path string) string {
+	return indexRE.ReplaceAllStringFunc(path, func(idx string) string {
+		n, _ := strconv.Atoi(idx[1 : len(idx)-1])
+		return fmt.Sprintf("[%08d]", n)
+	})
+}
+
+// indexRE matches a list index within a path.
+var indexRE = regexp.MustCompile(`\[\d+\]`)
+
+// Load a node description from a JSON file or, if filename is empty,
+// from the current node, and flatten it.
+func loadLeaves(filename string) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if filename == "" {
+		data, err = json.Marshal(describeHost())
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err = json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	leaves := make(map[string]interface{})
+	flatten("", v, leaves)
+	return leaves, nil
+}
+
+// Compare two node descriptions and write each difference.  Return
+// the number of differences.
+func diff(w io.Writer, aName, bName string, ignore []string) (int, error) {
+	a, err := loadLeaves(aName)
+	if err != nil {
+		return 0, err
+	}
+	b, err := loadLeaves(bName)
+	if err != nil {
+		return 0, err
+	}
+	if bName == "" {
+		bName = "this node"
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, f := range ignore {
+		ignored[strings.TrimSpace(f)] = true
+	}
+	var paths []string
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return sortKey(paths[i]) < sortKey(paths[j]) })
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Field\t%s\t%s\n", aName, bName)
+	n := 0
+	for _, p := range paths {
+		if ignored[p] || ignored[fieldName(p)] {
+			continue
+		}
+		av, aok := a[p]
+		bv, bok := b[p]
+		if aok && bok && av == bv {
+			continue
+		}
+		format := func(v interface{}, ok bool) string {
+			if !ok {
+				return "(missing)"
+			}
+			return fmt.Sprint(v)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p, format(av, aok), format(bv, bok))
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, tw.Flush()
+}
+
+// ----------------------------------------------------------------------
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-hwinfo: ")
+	asJSON := flag.Bool("json", false, "write JSON instead of a summary")
+	doDiff := flag.Bool("diff", false, "compare two JSON files, or one JSON file with this node")
+	ignore := flag.String("ignore", "hostname,mhz", "comma-separated `fields` to ignore when comparing")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json]\n       %s -diff [-ignore fields] spec.json [other.json]\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Compare nodes if requested.
+	if *doDiff {
+		if flag.NArg() < 1 || flag.NArg() > 2 || *asJSON {
+			flag.Usage()
+			os.Exit(2)
+		}
+		var ignored []string
+		if *ignore != "" {
+			ignored = strings.Split(*ignore, ",")
+		}
+		n, err := diff(os.Stdout, flag.Arg(0), flag.Arg(1), ignored)
+		if err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+		if n > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Otherwise, describe the current node.
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	host := describeHost()
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(host)
+	} else {
+		err = writeSummary(os.Stdout, host)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
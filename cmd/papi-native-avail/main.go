@@ -0,0 +1,265 @@
+// papi-native-avail lists the native events of each PAPI component,
+// together with each event's unit masks and other qualifiers, much as
+// PAPI's own papi_native_avail utility does.  Events are shown as a
+// tree in which qualifiers appear beneath the event they qualify.
+// Disabled components are skipped.
+//
+// Usage:
+//
+//	papi-native-avail [-component name|index] [-grep regexp] [-check]
+//	                  [-json | -go]
+//
+// The -component option restricts the listing to a single component,
+// named either by its name (e.g., "perf_event") or by its index.  The
+// -grep option lists only events and qualifiers whose name or
+// description matches a regular expression; an event whose name
+// matches is listed with all of its qualifiers.  The -check option
+// lists only events and qualifiers that can actually be added to an
+// event set.  The -json option writes the listing as JSON.  The -go
+// option writes a Go snippet that builds an EventSet from the listed
+// events, using each listed qualifier in place of the event it
+// qualifies.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/cmdutil"
+)
+
+// A qualifier is a unit mask or other qualifier of a native event.
+type qualifier struct {
+	Name        string `json:"name"`        // Fully qualified event name (e.g., INST_RETIRED:ANY_P)
+	Code        string `json:"code"`        // Event code in hexadecimal
+	Description string `json:"description"` // Description of the qualifier
+}
+
+// A nativeEvent describes a native event and its qualifiers.
+type nativeEvent struct {
+	Name        string      `json:"name"`                 // Name of the event
+	Code        string      `json:"code"`                 // Event code in hexadecimal
+	Description string      `json:"description"`          // Description of the event
+	Qualifiers  []qualifier `json:"qualifiers,omitempty"` // Unit masks and other qualifiers
+}
+
+// A component describes a component and its native events.
+type component struct {
+	Index  int           `json:"index"`  // Component index
+	Name   string        `json:"name"`   // Component name
+	Events []nativeEvent `json:"events"` // Native events provided by the component
+}
+
+// A filter decides which events and qualifiers to list.
+type filter struct {
+	re    *regexp.Regexp // Pattern that names or descriptions must match, or nil
+	check bool           // List only what can be added to an event set
+	es    papi.EventSet  // Event set used for checking
+}
+
+// Return true if a name or description matches the filter's pattern.
+func (f *filter) matches(name, desc string) bool {
+	return f.re == nil || f.re.MatchString(name) || f.re.MatchString(desc)
+}
+
+// Return true if an event passes the filter's check, if any.
+func (f *filter) addable(ev papi.Event) bool {
+	if !f.check {
+		return true
+	}
+	ok := f.es.AddEvent(ev) == nil
+	if err := f.es.CleanupEventSet(); err != nil {
+		log.Fatal(err)
+	}
+	return ok
+}
+
+// Return the events of a component that pass a filter.
+func listComponent(cidx int, f *filter) ([]nativeEvent, error) {
+	codes, err := papi.EnumEvents(papi.NATIVE_MASK|papi.ComponentMask(cidx), papi.ENUM_EVENTS)
+	if err != nil {
+		return nil, err
+	}
+	var events []nativeEvent
+	for _, ev := range codes {
+		name, desc := cmdutil.Describe(ev)
+		e := nativeEvent{Name: name, Code: cmdutil.HexCode(ev), Description: desc}
+		eventMatches := f.matches(name, desc)
+		// Many components reject requests to enumerate qualifiers.  As
+		// in PAPI's papi_native_avail, treat that as an event without
+		// qualifiers rather than as an error.
+		umasks, _ := papi.EnumUmasks(ev)
+		for _, um := range umasks {
+			uname, udesc := cmdutil.Describe(um)
+			if !eventMatches && !f.matches(uname, udesc) {
+				continue
+			}
+			if f.addable(um) {
+				e.Qualifiers = append(e.Qualifiers, qualifier{Name: uname, Code: cmdutil.HexCode(um), Description: udesc})
+			}
+		}
+		switch {
+		case len(e.Qualifiers) > 0:
+			events = append(events, e)
+		case eventMatches && f.addable(ev):
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Write the listing as a tree.
+func writeTree(w io.Writer, comps []component) {
+	for _, c := range comps {
+		title := fmt.Sprintf("Component %d: %s", c.Index, c.Name)
+		fmt.Fprintf(w, "%s\n%s\n", title, strings.Repeat("=", len(title)))
+		for _, e := range c.Events {
+			fmt.Fprintf(w, "%s  (%s)\n", e.Name, e.Code)
+			if e.Description != "" {
+				fmt.Fprintf(w, "        %s\n", e.Description)
+			}
+			for _, q := range e.Qualifiers {
+				fmt.Fprintf(w, "    %s  (%s)\n", strings.TrimPrefix(q.Name, e.Name), q.Code)
+				if q.Description != "" {
+					fmt.Fprintf(w, "            %s\n", q.Description)
+				}
+			}
+		}
+		total := 0
+		for _, e := range c.Events {
+			total += 1 + len(e.Qualifiers)
+		}
+		fmt.Fprintf(w, "\n%d events and qualifiers listed\n\n", total)
+	}
+}
+
+// Write a Go snippet that builds an event set from the listed events.
+func writeGo(w io.Writer, comps []component) {
+	args := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+	fmt.Fprintf(w, "// Generated by %s\n", strings.Join(args, " "))
+	fmt.Fprintln(w, "var ecodes []papi.Event")
+	fmt.Fprintln(w, "for _, ename := range []string{")
+	seen := make(map[string]bool)
+	emit := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			fmt.Fprintf(w, "\t%s,\n", strconv.Quote(name))
+		}
+	}
+	for _, c := range comps {
+		for _, e := range c.Events {
+			if len(e.Qualifiers) == 0 {
+				emit(e.Name)
+			}
+			for _, q := range e.Qualifiers {
+				emit(q.Name)
+			}
+		}
+	}
+	fmt.Fprint(w, `} {
+	ecode, err := papi.ParseEvent(ename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ecodes = append(ecodes, ecode)
+}
+es, err := papi.CreateEventSet()
+if err != nil {
+	log.Fatal(err)
+}
+if err = es.AddEvents(ecodes); err != nil {
+	log.Fatal(err)
+}
+`)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-native-avail: ")
+	compName := flag.String("component", "", "list only the component with the given `name or index`")
+	pattern := flag.String("grep", "", "list only events whose name or description matches `regexp`")
+	check := flag.Bool("check", false, "list only events that can be added to an event set")
+	asJSON := flag.Bool("json", false, "write JSON instead of a tree")
+	asGo := flag.Bool("go", false, "write a Go snippet that builds an event set from the listed events")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || (*asJSON && *asGo) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Prepare the filter.
+	f := &filter{check: *check}
+	if *pattern != "" {
+		re, err := regexp.Compile("(?i)" + *pattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.re = re
+	}
+	if f.check {
+		runtime.LockOSThread()
+		es, err := papi.CreateEventSet()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer es.DestroyEventSet()
+		f.es = es
+	}
+
+	// List each component's events.
+	var comps []component
+	found := false
+	for cidx := 0; cidx < papi.GetNumComponents(); cidx++ {
+		info, err := papi.GetComponentInfo(cidx)
+		if err != nil {
+			log.Fatalf("component %d: %v", cidx, err)
+		}
+		if *compName != "" && *compName != info.Name && *compName != strconv.Itoa(cidx) {
+			continue
+		}
+		found = true
+		if info.Disabled {
+			// Disabled components provide no events.
+			if *compName != "" {
+				log.Printf("%s is disabled: %s", info.Name, info.DisabledReason)
+			}
+			continue
+		}
+		events, err := listComponent(cidx, f)
+		if err != nil {
+			log.Fatalf("%s: %v", info.Name, err)
+		}
+		comps = append(comps, component{Index: cidx, Name: info.Name, Events: events})
+	}
+	if !found {
+		log.Fatalf("no component is named %q", *compName)
+	}
+
+	// Output the listing.
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(comps); err != nil {
+			log.Fatal(err)
+		}
+	case *asGo:
+		writeGo(os.Stdout, comps)
+	default:
+		writeTree(os.Stdout, comps)
+	}
+}
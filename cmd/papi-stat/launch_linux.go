@@ -0,0 +1,34 @@
+// This file starts commands under ptrace on Linux so that counting can
+// begin before they execute their first instruction.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// Start a command, stopped as soon as it executes, and return a
+// function that lets it run.  The calling goroutine must be locked to
+// its OS thread until the returned function is called.
+func startStopped(cmd *exec.Cmd) (resume func() error, err error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	var ws syscall.WaitStatus
+	if _, err = syscall.Wait4(pid, &ws, 0, nil); err == nil && !ws.Stopped() {
+		err = fmt.Errorf("%s exited before it could be counted", cmd.Path)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return func() error { return syscall.PtraceDetach(pid) }, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+// This file stands in for starting commands under ptrace on systems
+// other than Linux.
+
+package main
+
+import "os/exec"
+
+// Start a command.  The command runs immediately, so its first
+// instructions may go uncounted.
+func startStopped(cmd *exec.Cmd) (resume func() error, err error) {
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() error { return nil }, nil
+}
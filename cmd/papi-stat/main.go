@@ -0,0 +1,572 @@
+// papi-stat runs a command and reports the events it incurred, much as
+// perf stat does, but through PAPI, so that it behaves the same on
+// every node regardless of the installed version of perf.
+//
+// Usage:
+//
+//	papi-stat [-e event,...] [-M metric,...] [-r runs] [-a] [-I msecs]
+//	          [-json | -csv] [-o file] [--] command [arg...]
+//
+// The -e option lists the events to count (default:
+// PAPI_TOT_INS,PAPI_TOT_CYC).  The -M option lists derived metrics to
+// report, each either a builtin metric from the metrics package (e.g.,
+// "IPC") or a new metric defined as name=expr (e.g.,
+// "MPKI=1000*PAPI_L2_TCM/PAPI_TOT_INS"); the events they require are
+// counted as well.  Without -M, papi-stat reports every builtin metric
+// whose events are all counted.
+//
+// Counting begins before the command executes its first instruction
+// and includes every thread and child process the command creates.
+// The -a option instead counts on every CPU, including every other
+// process that runs there, for as long as the command runs, and
+// reports each CPU as well as the total; this typically requires
+// elevated privileges.  The -r option runs the command repeatedly and
+// reports the mean of each quantity and its relative standard
+// deviation.  The -I option additionally reports the counts
+// accumulated during each interval of the given number of
+// milliseconds, summed over every CPU.
+//
+// The report is written to standard error, so as not to mix with the
+// command's own output, or to the file named by -o.  The -json option
+// writes the report as JSON and the -csv option as CSV.  papi-stat
+// exits with the command's exit status or, if a signal killed the
+// command, with 128 plus the signal number, as the shell does.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/cmdutil"
+	"github.com/lanl/go-papi/internal/counting"
+	"github.com/lanl/go-papi/metrics"
+)
+
+// A quantity summarizes an event count, metric, or time over every run.
+type quantity struct {
+	Name   string    `json:"name"`   // Name of the event, metric, or time
+	Mean   float64   `json:"mean"`   // Mean over every run
+	StdDev float64   `json:"stddev"` // Sample standard deviation over every run
+	Runs   []float64 `json:"runs"`   // Value in each run
+}
+
+// A scope holds the quantities measured in total or on a single CPU.
+type scope struct {
+	CPU     int        `json:"cpu"`               // CPU counted, or -1 for the total
+	Events  []quantity `json:"events"`            // Event counts
+	Metrics []quantity `json:"metrics,omitempty"` // Derived metrics
+}
+
+// An interval holds the counts accumulated during one interval of
+// one run.
+type interval struct {
+	Run    int     `json:"run"`    // Run number, starting from 1
+	Time   float64 `json:"time"`   // Seconds from the start of the run to the end of the interval
+	Counts []int64 `json:"counts"` // Count of each event during the interval
+}
+
+// A report describes every run of a command.
+type report struct {
+	Command   []string   `json:"command"`             // Command and its arguments
+	Events    []string   `json:"events"`              // Events counted, in the order of interval counts
+	Runs      int        `json:"runs"`                // Number of runs
+	Elapsed   quantity   `json:"elapsed"`             // Wall-clock time in seconds
+	Total     scope      `json:"total"`               // Quantities summed over every CPU
+	CPUs      []scope    `json:"cpus,omitempty"`      // Quantities on each CPU (-a only)
+	Intervals []interval `json:"intervals,omitempty"` // Counts in each interval (-I only)
+}
+
+// A run holds the results of a single run of the command.
+type run struct {
+	elapsed  time.Duration     // Wall-clock time
+	samples  []counting.Sample // Final counts of each CPU or of the process
+	exitCode int               // Command's exit status
+}
+
+// ----------------------------------------------------------------------
+
+// Parse a -M option into a metric.
+func parseMetric(spec string) (*metrics.Metric, error) {
+	name, expr, ok := strings.Cut(spec, "=")
+	if !ok {
+		if m := metrics.Builtin(spec); m != nil {
+			return m, nil
+		}
+		return nil, fmt.Errorf("%s is not a builtin metric", spec)
+	}
+	return metrics.New(strings.TrimSpace(name), "", expr)
+}
+
+// Return every builtin metric whose events are all in a list.
+func applicableBuiltins(events []papi.Event) []*metrics.Metric {
+	have := make(map[papi.Event]bool, len(events))
+	for _, ev := range events {
+		have[ev] = true
+	}
+	var ms []*metrics.Metric
+	for _, m := range metrics.Builtins() {
+		ok := true
+		for _, ev := range m.Events() {
+			ok = ok && have[ev]
+		}
+		if ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// Sum a set of samples event by event.
+func sum(samples []counting.Sample, n int) ([]int64, error) {
+	total := make([]int64, n)
+	for _, smp := range samples {
+		if smp.Err != nil {
+			return nil, smp.Err
+		}
+		for i, v := range smp.Values {
+			total[i] += v
+		}
+	}
+	return total, nil
+}
+
+// ----------------------------------------------------------------------
+
+// A stat runs a command and counts events in it.
+type stat struct {
+	counters *counting.Counters // Counters for the command or for every CPU
+	cpus     []int              // CPUs to count, or nil to count the command
+	interval time.Duration      // Interval between reports, or 0 for none
+	out      *output            // Destination of interval reports
+}
+
+// Run the command once and return its counts.
+func (s *stat) runOnce(n int, args []string) (*run, error) {
+	// Start the command and begin counting.
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	resume := func() error { return nil }
+	var err error
+	if s.cpus != nil {
+		if err = s.counters.AttachCPUs(s.cpus...); err == nil {
+			err = cmd.Start()
+		}
+	} else {
+		if resume, err = startStopped(cmd); err == nil {
+			if err = s.counters.AttachProcess(cmd.Process.Pid); err != nil {
+				cmd.Process.Kill()
+				resume()
+				cmd.Wait()
+			}
+		}
+	}
+	if err != nil {
+		s.counters.Close()
+		return nil, err
+	}
+	start := time.Now()
+	if err = resume(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		s.counters.Close()
+		return nil, err
+	}
+
+	// Report each interval until the command exits.
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	nEvents := len(s.counters.Events)
+	prev := make([]int64, nEvents)
+	var waitErr error
+wait:
+	for {
+		select {
+		case waitErr = <-done:
+			break wait
+		case now := <-tick:
+			total, err := sum(s.counters.Read(), nEvents)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			iv := interval{Run: n, Time: now.Sub(start).Seconds(), Counts: make([]int64, nEvents)}
+			for i, v := range total {
+				iv.Counts[i] = v - prev[i]
+			}
+			prev = total
+			if err = s.out.interval(iv); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+
+	// Stop counting and gather the final counts.
+	r := &run{elapsed: time.Since(start)}
+	if r.samples, err = s.counters.Close(); err != nil {
+		return nil, err
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case waitErr == nil:
+	case errors.As(waitErr, &exitErr):
+		r.exitCode = exitErr.ExitCode()
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			r.exitCode = 128 + int(ws.Signal()) // Killed by a signal, as in the shell
+		} else if r.exitCode < 0 {
+			r.exitCode = 1
+		}
+	default:
+		return nil, waitErr
+	}
+	return r, nil
+}
+
+// Summarize a list of values as a quantity.
+func newQuantity(name string, values []float64) quantity {
+	q := quantity{Name: name, Runs: values}
+	for _, v := range values {
+		q.Mean += v
+	}
+	q.Mean /= float64(len(values))
+	if len(values) > 1 {
+		var ss float64
+		for _, v := range values {
+			ss += (v - q.Mean) * (v - q.Mean)
+		}
+		q.StdDev = math.Sqrt(ss / float64(len(values)-1))
+	}
+	return q
+}
+
+// Summarize each run's counts, given as one list of values per run,
+// as a scope.  Metrics that cannot be computed for every run are
+// omitted.
+func newScope(cpu int, events []papi.Event, ms []*metrics.Metric, counts [][]int64) scope {
+	sc := scope{CPU: cpu}
+	for i, ev := range events {
+		values := make([]float64, len(counts))
+		for j, c := range counts {
+			values[j] = float64(c[i])
+		}
+		sc.Events = append(sc.Events, newQuantity(ev.String(), values))
+	}
+	for _, m := range ms {
+		values := make([]float64, len(counts))
+		var err error
+		for j, c := range counts {
+			if values[j], err = m.Eval(metrics.Counts(events, c)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			sc.Metrics = append(sc.Metrics, newQuantity(m.Name, values))
+		}
+	}
+	return sc
+}
+
+// Summarize every run of the command.
+func (s *stat) summarize(args []string, ms []*metrics.Metric, runs []*run) (*report, error) {
+	events := s.counters.Events
+	rep := &report{Command: args, Runs: len(runs)}
+	for _, ev := range events {
+		rep.Events = append(rep.Events, ev.String())
+	}
+	elapsed := make([]float64, len(runs))
+	totals := make([][]int64, len(runs))
+	for j, r := range runs {
+		elapsed[j] = r.elapsed.Seconds()
+		var err error
+		if totals[j], err = sum(r.samples, len(events)); err != nil {
+			return nil, err
+		}
+	}
+	rep.Elapsed = newQuantity("elapsed_seconds", elapsed)
+	rep.Total = newScope(-1, events, ms, totals)
+	for i, cpu := range s.cpus {
+		counts := make([][]int64, len(runs))
+		for j, r := range runs {
+			counts[j] = r.samples[i].Values
+		}
+		rep.CPUs = append(rep.CPUs, newScope(cpu, events, ms, counts))
+	}
+	return rep, nil
+}
+
+// ----------------------------------------------------------------------
+
+// An output writes a report in a given format.  Intervals are written
+// as they occur, except in JSON, where they are gathered into the
+// report.
+type output struct {
+	w         io.Writer   // Destination of the report
+	format    string      // "table", "json", or "csv"
+	csv       *csv.Writer // CSV writer, in CSV format
+	events    []string    // Names of the events counted
+	runs      int         // Number of runs
+	intervals []interval  // Intervals gathered for a JSON report
+}
+
+// Prepare to write a report.
+func newOutput(w io.Writer, format string, events []string, runs int) *output {
+	o := &output{w: w, format: format, events: events, runs: runs}
+	if format == "csv" {
+		o.csv = csv.NewWriter(w)
+		o.csv.Write([]string{"run", "time", "cpu", "name", "value", "stddev"})
+	}
+	return o
+}
+
+// Announce the start of a run.
+func (o *output) startRun(n int, intervals bool) {
+	if o.format == "table" && intervals && o.runs > 1 {
+		fmt.Fprintf(o.w, "\n Run %d of %d:\n", n, o.runs)
+	}
+}
+
+// Write the counts accumulated during an interval.
+func (o *output) interval(iv interval) error {
+	switch o.format {
+	case "json":
+		o.intervals = append(o.intervals, iv)
+	case "csv":
+		for i, c := range iv.Counts {
+			o.csv.Write([]string{strconv.Itoa(iv.Run), strconv.FormatFloat(iv.Time, 'f', 6, 64),
+				"", o.events[i], strconv.FormatInt(c, 10), ""})
+		}
+		o.csv.Flush()
+		return o.csv.Error()
+	default:
+		for i, c := range iv.Counts {
+			if _, err := fmt.Fprintf(o.w, "%14.6f %18s  %s\n", iv.Time, commas(float64(c)), o.events[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Write the final report.
+func (o *output) finish(rep *report) error {
+	switch o.format {
+	case "json":
+		rep.Intervals = o.intervals
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "csv":
+		for _, sc := range append(rep.CPUs, rep.Total) {
+			cpu := ""
+			if sc.CPU >= 0 {
+				cpu = strconv.Itoa(sc.CPU)
+			}
+			for _, q := range append(sc.Events, sc.Metrics...) {
+				o.csv.Write([]string{"", "", cpu, q.Name, formatFloat(q.Mean), formatFloat(q.StdDev)})
+			}
+		}
+		q := rep.Elapsed
+		o.csv.Write([]string{"", "", "", q.Name, formatFloat(q.Mean), formatFloat(q.StdDev)})
+		o.csv.Flush()
+		return o.csv.Error()
+	default:
+		return writeTable(o.w, rep)
+	}
+}
+
+// Format a floating-point number as compactly as possible.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Format a count, rounded to an integer, with commas between groups
+// of three digits.
+func commas(v float64) string {
+	s := strconv.FormatInt(int64(math.Round(v)), 10)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// Format a quantity's relative standard deviation, preceded by a
+// separator, or return the empty string if there was only one run.
+func variation(q quantity, runs int, sep string) string {
+	if runs < 2 || q.Mean == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s( +- %.2f%% )", sep, 100*q.StdDev/math.Abs(q.Mean))
+}
+
+// Write a report as a table.
+func writeTable(w io.Writer, rep *report) error {
+	fmt.Fprintf(w, "\n Performance counter stats for '%s'", strings.Join(rep.Command, " "))
+	if rep.Runs > 1 {
+		fmt.Fprintf(w, " (%d runs)", rep.Runs)
+	}
+	fmt.Fprint(w, ":\n\n")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, sc := range append(rep.CPUs, rep.Total) {
+		where := ""
+		switch {
+		case sc.CPU >= 0:
+			where = fmt.Sprintf("CPU%d", sc.CPU)
+		case len(rep.CPUs) > 0:
+			where = "total"
+		}
+		for _, q := range sc.Events {
+			fmt.Fprintf(tw, "%s\t%18s  %s%s\n", where, commas(q.Mean), q.Name, variation(q, rep.Runs, "\t"))
+		}
+		for _, q := range sc.Metrics {
+			fmt.Fprintf(tw, "%s\t%18.3f  %s%s\n", where, q.Mean, q.Name, variation(q, rep.Runs, "\t"))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n %.9f seconds time elapsed%s\n\n", rep.Elapsed.Mean, variation(rep.Elapsed, rep.Runs, " "))
+	return err
+}
+
+// ----------------------------------------------------------------------
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-stat: ")
+	var eventNames, metricSpecs cmdutil.List
+	for _, ev := range papi.DefaultRegionEvents {
+		eventNames = append(eventNames, ev.String())
+	}
+	flag.Var(&eventNames, "e", "comma-separated list of `events` to count")
+	flag.Var(&metricSpecs, "M", "comma-separated list of `metrics` to report, each builtin or name=expr")
+	runs := flag.Int("r", 1, "run the command `n` times")
+	allCPUs := flag.Bool("a", false, "count on every CPU and report each CPU")
+	msecs := flag.Int("I", 0, "report the counts every `msecs` milliseconds")
+	asJSON := flag.Bool("json", false, "write JSON instead of a table")
+	asCSV := flag.Bool("csv", false, "write CSV instead of a table")
+	outName := flag.String("o", "", "write the report to `file` instead of standard error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [--] command [arg...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *runs < 1 || *msecs < 0 || (*asJSON && *asCSV) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	args := flag.Args()
+
+	// Parse the events and metrics.
+	var events []papi.Event
+	for _, name := range eventNames {
+		ev, err := papi.ParseEvent(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		events = append(events, ev)
+	}
+	var ms []*metrics.Metric
+	for _, spec := range metricSpecs {
+		m, err := parseMetric(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	events = append(events, metrics.Events(ms...)...)
+	if metricSpecs == nil {
+		ms = applicableBuiltins(events)
+	}
+
+	// Prepare to count.
+	counters, err := counting.New(events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &stat{counters: counters, interval: time.Duration(*msecs) * time.Millisecond}
+	if *allCPUs {
+		for cpu := 0; cpu < int(papi.GetHardwareInfo().TotalCPUs); cpu++ {
+			s.cpus = append(s.cpus, cpu)
+		}
+	}
+	var w io.Writer = os.Stderr
+	var outFile *os.File
+	if *outName != "" {
+		if outFile, err = os.Create(*outName); err != nil {
+			log.Fatal(err)
+		}
+		w = outFile
+	}
+	format := "table"
+	switch {
+	case *asJSON:
+		format = "json"
+	case *asCSV:
+		format = "csv"
+	}
+	var names []string
+	for _, ev := range counters.Events {
+		names = append(names, ev.String())
+	}
+	s.out = newOutput(w, format, names, *runs)
+
+	// Run the command, letting it rather than papi-stat handle
+	// interrupts.  Tracing the command requires that every run start
+	// from the same OS thread.
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+	runtime.LockOSThread()
+	var results []*run
+	exitCode := 0
+	for n := 1; n <= *runs; n++ {
+		s.out.startRun(n, s.interval > 0)
+		r, err := s.runOnce(n, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, r)
+		if r.exitCode != 0 {
+			exitCode = r.exitCode
+		}
+	}
+
+	// Report the results.
+	rep, err := s.summarize(args, ms, results)
+	if err == nil {
+		err = s.out.finish(rep)
+	}
+	if outFile != nil {
+		if cerr := outFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,196 @@
+// papidiff compares two sets of repeated measurements produced by the
+// harness package, much as benchstat compares two sets of benchmark
+// results.  It pairs up events by name, reports each event's change in
+// mean and whether that change is statistically significant, and
+// flags regressions that exceed configurable thresholds.
+//
+// Usage:
+//
+//	papidiff [-test t|u] [-alpha p] [-outliers none|mad|iqr]
+//	         [-metric name[=expr]]... [-threshold name=limit]...
+//	         old.json|old.csv new.json|new.csv
+//
+// Files ending in ".csv" are read with results.ReadResultCSV(); all
+// others are read with results.ReadResult().  A -metric option names
+// either a builtin metric from the metrics/formula package (e.g., "IPC")
+// or a new metric defined by an expression over event names (e.g.,
+// "L2_MISSES=PAPI_L2_TCM").  Events are matched by name exactly as
+// recorded in the files.  A -threshold option bounds the acceptable
+// relative change in an event, metric, or "elapsed_ns", or in every
+// quantity if the name is "*", as in "PAPI_L2_TCM=+5%" or "IPC=-2%".
+//
+// papidiff exits with status 1 if any quantity regressed, making it
+// suitable for gating merges in continuous integration, and with
+// status 2 on error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi/harness/results"
+	"github.com/lanl/go-papi/metrics/formula"
+)
+
+// A stringList is a repeatable command-line option.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// Read a result from a JSON or CSV file.  If outliers is non-nil, it
+// overrides the result's outlier-rejection method.
+func readResult(filename string, outliers *results.OutlierMethod) (*results.Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res *results.Result
+	if strings.HasSuffix(strings.ToLower(filename), ".csv") {
+		res, err = results.ReadResultCSV(f, results.NoRejection)
+	} else {
+		res, err = results.ReadResult(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if outliers != nil {
+		res.Outliers = *outliers
+	}
+	return res, nil
+}
+
+// Parse a -metric option into a metric.
+func parseMetric(spec string) (*formula.Formula, error) {
+	name, expr, ok := strings.Cut(spec, "=")
+	if !ok {
+		if m := formula.Builtin(spec); m != nil {
+			return m, nil
+		}
+		return nil, fmt.Errorf("%s is not a builtin metric", spec)
+	}
+	return formula.New(strings.TrimSpace(name), "", expr)
+}
+
+// Format a relative change as a signed percentage.
+func percent(delta float64) string {
+	if math.IsNaN(delta) {
+		return "~"
+	}
+	return fmt.Sprintf("%+.2f%%", 100*delta)
+}
+
+// Write a table of deltas.
+func writeDeltas(w io.Writer, deltas []results.Delta) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Name\tOld mean\tNew mean\tDelta\tChange\tp\tLimit\t\t")
+	for _, d := range deltas {
+		change := percent(d.Delta)
+		if !d.Significant {
+			change = "~"
+		}
+		limit := ""
+		if d.Threshold != nil {
+			limit = percent(d.Threshold.Limit)
+		}
+		mark := ""
+		if d.Regression {
+			mark = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%.6g\t%.6g\t%+.6g\t%s\t%.3g\t%s\t%s\t\n",
+			d.Name, d.OldMean, d.NewMean, d.NewMean-d.OldMean, change, d.P, limit, mark)
+	}
+	return tw.Flush()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papidiff: ")
+	var metricSpecs, thresholdSpecs stringList
+	test := flag.String("test", "t", "statistical `test`: t (Welch's t-test) or u (Mann-Whitney U test)")
+	alpha := flag.Float64("alpha", 0.05, "significance `level`")
+	outlierName := flag.String("outliers", "", "outlier-rejection `method` (none, mad, or iqr; default: as recorded in each file)")
+	flag.Var(&metricSpecs, "metric", "compare a builtin metric or one defined as `name[=expr]`")
+	flag.Var(&thresholdSpecs, "threshold", "flag changes in a quantity beyond a `name=limit` such as PAPI_L2_TCM=+5%")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] old.json|old.csv new.json|new.csv\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	fail := func(err error) {
+		log.Print(err)
+		os.Exit(2)
+	}
+
+	// Parse the options.
+	var opts results.DiffOptions
+	opts.Alpha = *alpha
+	switch *test {
+	case "t":
+		opts.Test = results.TTest
+	case "u":
+		opts.Test = results.MannWhitneyU
+	default:
+		fail(fmt.Errorf("unknown test %q", *test))
+	}
+	var outliers *results.OutlierMethod
+	if *outlierName != "" {
+		outliers = new(results.OutlierMethod)
+		if err := outliers.UnmarshalText([]byte(*outlierName)); err != nil {
+			fail(err)
+		}
+	}
+	for _, spec := range metricSpecs {
+		m, err := parseMetric(spec)
+		if err != nil {
+			fail(err)
+		}
+		opts.Metrics = append(opts.Metrics, m)
+	}
+	for _, spec := range thresholdSpecs {
+		t, err := results.ParseThreshold(spec)
+		if err != nil {
+			fail(err)
+		}
+		opts.Thresholds = append(opts.Thresholds, t)
+	}
+
+	// Compare the two files.
+	before, err := readResult(flag.Arg(0), outliers)
+	if err != nil {
+		fail(err)
+	}
+	after, err := readResult(flag.Arg(1), outliers)
+	if err != nil {
+		fail(err)
+	}
+	deltas, err := results.Diff(before, after, &opts)
+	if err != nil {
+		fail(err)
+	}
+	if err = writeDeltas(os.Stdout, deltas); err != nil {
+		fail(err)
+	}
+	for _, d := range deltas {
+		if d.Regression {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/lanl/go-papi
+
+go 1.19
@@ -0,0 +1,220 @@
+// papi-gotrace summarizes the hardware counts recorded in a Go
+// execution trace by the gotrace package, correlating each region's
+// counts with the time its goroutine actually spent executing.
+//
+// Usage:
+//
+//	papi-gotrace [-instances] [-json] trace.out
+//
+// By default, papi-gotrace writes one row per region type with the
+// number of instances, their total wall-clock and executing time, and
+// the total of each logged event.  The -instances option instead writes
+// one row per region instance, identified by its goroutine and task, in
+// the order in which the instances ended.  The -json option writes the
+// summary or the instances as JSON.
+//
+// Executing time excludes the time a goroutine spent runnable or
+// blocked, so dividing a count by it gives a rate that is not diluted
+// by scheduling delays.  Open the same trace with "go tool trace" to
+// see the counts alongside the goroutine's scheduling.
+//
+// papi-gotrace does not depend on PAPI and builds without cgo, so a
+// trace recorded on one machine can be analyzed on another.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi/gotrace/reader"
+)
+
+// A summary aggregates every instance of one region type.
+type summary struct {
+	Type    string   `json:"type"`
+	Count   int      `json:"count"`
+	WallNs  int64    `json:"wall_ns"`
+	ExecNs  int64    `json:"exec_ns"`
+	Events  []string `json:"events,omitempty"`
+	Counts  []int64  `json:"counts,omitempty"`
+	indexOf map[string]int
+}
+
+// Add a region instance's times and counts to a summary.
+func (s *summary) add(rc reader.RegionCounts) {
+	s.Count++
+	s.WallNs += rc.EndNs - rc.StartNs
+	s.ExecNs += rc.ExecNs
+	for i, name := range rc.Events {
+		j, ok := s.indexOf[name]
+		if !ok {
+			j = len(s.Events)
+			s.indexOf[name] = j
+			s.Events = append(s.Events, name)
+			s.Counts = append(s.Counts, 0)
+		}
+		s.Counts[j] += rc.Counts[i]
+	}
+}
+
+// Summarize region instances by type, in order of first appearance.
+func summarize(regions []reader.RegionCounts) []*summary {
+	var sums []*summary
+	byType := make(map[string]*summary)
+	for _, rc := range regions {
+		s, ok := byType[rc.Type]
+		if !ok {
+			s = &summary{Type: rc.Type, indexOf: make(map[string]int)}
+			byType[rc.Type] = s
+			sums = append(sums, s)
+		}
+		s.add(rc)
+	}
+	return sums
+}
+
+// Return the names of all events in order of first appearance.
+func eventNames(lists ...[]string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Format the count of a named event, or a dash if it was not logged.
+func countOf(name string, events []string, counts []int64) string {
+	for i, ev := range events {
+		if ev == name {
+			return fmt.Sprint(counts[i])
+		}
+	}
+	return "-"
+}
+
+// Format a duration in nanoseconds as milliseconds.
+func ms(ns int64) string {
+	return fmt.Sprintf("%.3f", float64(ns)/1e6)
+}
+
+// Write a table summarizing each region type.
+func writeSummary(w io.Writer, sums []*summary) error {
+	var lists [][]string
+	for _, s := range sums {
+		lists = append(lists, s.Events)
+	}
+	names := eventNames(lists...)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "Region\tCount\tWall (ms)\tExecuting (ms)")
+	for _, name := range names {
+		fmt.Fprintf(tw, "\t%s", name)
+	}
+	fmt.Fprintln(tw)
+	for _, s := range sums {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s", s.Type, s.Count, ms(s.WallNs), ms(s.ExecNs))
+		for _, name := range names {
+			fmt.Fprintf(tw, "\t%s", countOf(name, s.Events, s.Counts))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// Write a table of every region instance, with start times relative
+// to that of the earliest instance.
+func writeInstances(w io.Writer, regions []reader.RegionCounts) error {
+	var lists [][]string
+	for _, rc := range regions {
+		lists = append(lists, rc.Events)
+	}
+	names := eventNames(lists...)
+	origin := regions[0].StartNs
+	for _, rc := range regions {
+		if rc.StartNs < origin {
+			origin = rc.StartNs
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprint(tw, "Region\tGoroutine\tTask\tStart (ms)\tWall (ms)\tExecuting (ms)")
+	for _, name := range names {
+		fmt.Fprintf(tw, "\t%s", name)
+	}
+	fmt.Fprintln(tw)
+	for _, rc := range regions {
+		task := "-"
+		if rc.Task != 0 {
+			task = fmt.Sprint(rc.Task)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s", rc.Type, rc.Goroutine, task,
+			ms(rc.StartNs-origin), ms(rc.EndNs-rc.StartNs), ms(rc.ExecNs))
+		for _, name := range names {
+			fmt.Fprintf(tw, "\t%s", countOf(name, rc.Events, rc.Counts))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("papi-gotrace: ")
+	instances := flag.Bool("instances", false, "list every region instance instead of summarizing by region type")
+	asJSON := flag.Bool("json", false, "write JSON instead of a table")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-instances] [-json] trace.out\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Read the regions from the trace.
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	regions, err := reader.ReadRegions(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(regions) == 0 {
+		log.Fatalf("%s contains no regions", flag.Arg(0))
+	}
+
+	// Write the regions in the requested form.
+	var out interface{}
+	if *instances {
+		out = regions
+		if !*asJSON {
+			err = writeInstances(os.Stdout, regions)
+		}
+	} else {
+		sums := summarize(regions)
+		out = sums
+		if !*asJSON {
+			err = writeSummary(os.Stdout, sums)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(out)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
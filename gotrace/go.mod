@@ -0,0 +1,10 @@
+module github.com/lanl/go-papi/gotrace
+
+go 1.25.0
+
+require (
+	github.com/lanl/go-papi v0.0.0-00010101000000-000000000000
+	golang.org/x/exp v0.0.0-20260611194520-c48552f49976
+)
+
+replace github.com/lanl/go-papi => ../
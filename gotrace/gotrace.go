@@ -0,0 +1,108 @@
+/*
+Package gotrace correlates hardware counters with Go execution traces.
+
+StartRegion() and NewTask() delimit a section of code both as a
+runtime/trace region or task and as a papi.Region.  When the section
+ends, its counts are written to the execution trace as a log message
+in Category, so they appear alongside goroutine scheduling in
+"go tool trace".  Log() writes arbitrary counts in the same format.
+
+The reader subpackage reads the logged counts back from an execution
+trace without depending on PAPI.
+*/
+package gotrace
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strings"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/gotrace/reader"
+)
+
+// Category is the runtime/trace log category of counter annotations.
+const Category = reader.Category
+
+// A Region measures a section of code as both a runtime/trace region
+// and a papi.Region.
+type Region struct {
+	ctx    context.Context // Context in which the region began
+	region *papi.Region    // Region that counts events
+	trace  *trace.Region   // Region that appears in the execution trace
+}
+
+// Begin measuring a named section of code.  As with papi.Begin(), only
+// the calling goroutine is counted, and it is locked to its OS thread
+// until the region ends.
+func StartRegion(ctx context.Context, name string) *Region {
+	tr := trace.StartRegion(ctx, name)
+	return &Region{ctx: ctx, region: papi.Begin(name), trace: tr}
+}
+
+// Stop counting, log the counts to the execution trace, and end the
+// trace region.  End() must be called by the goroutine that began the
+// region, after any regions begun within it have ended.  If the counts
+// cannot be read, nothing is logged and End() returns the error.
+func (r *Region) End() error {
+	err := r.region.End()
+	if err == nil {
+		events, counts := r.region.Counts()
+		Log(r.ctx, events, counts)
+	}
+	r.trace.End()
+	return err
+}
+
+// Return the events counted and their counts, once the region has
+// ended successfully.
+func (r *Region) Counts() (events []papi.Event, counts []int64) {
+	return r.region.Counts()
+}
+
+// A Task measures a logical operation as a runtime/trace task together
+// with a region of the same name on the goroutine that created it.
+// Only work performed by that goroutine is counted, although other
+// goroutines can begin their own regions in the task's context.
+type Task struct {
+	*Region
+	task *trace.Task // Task that appears in the execution trace
+}
+
+// Begin measuring a named task and return a context to pass to the
+// task's regions and subtasks.
+func NewTask(ctx context.Context, name string) (context.Context, *Task) {
+	ctx, tt := trace.NewTask(ctx, name)
+	return ctx, &Task{Region: StartRegion(ctx, name), task: tt}
+}
+
+// End the task's region, logging its counts, and then the task itself.
+func (t *Task) End() error {
+	err := t.Region.End()
+	t.task.End()
+	return err
+}
+
+// Log counts to the execution trace in Category if tracing is enabled.
+func Log(ctx context.Context, events []papi.Event, counts []int64) {
+	if trace.IsEnabled() {
+		trace.Log(ctx, Category, Format(events, counts))
+	}
+}
+
+// Format counts as space-separated name=value pairs (e.g.,
+// "PAPI_TOT_INS=1234 PAPI_TOT_CYC=5678").
+func Format(events []papi.Event, counts []int64) string {
+	var sb strings.Builder
+	for i, ev := range events {
+		if i >= len(counts) {
+			break
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%s=%d", ev, counts[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+// This file tests the annotation and reading of execution traces.
+
+package gotrace
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"sync"
+	"testing"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/gotrace/reader"
+)
+
+// Perform some floating-point work.
+func work(n int) float64 {
+	x := 1.0
+	for i := 0; i < n; i++ {
+		x = x*1.000001 + 0.5
+	}
+	return x
+}
+
+// Ensure that counts survive formatting and parsing.
+func TestFormat(t *testing.T) {
+	msg := Format([]papi.Event{papi.TOT_INS, papi.TOT_CYC}, []int64{1234, 5678})
+	if msg != "PAPI_TOT_INS=1234 PAPI_TOT_CYC=5678" {
+		t.Fatalf("Unexpected message %q", msg)
+	}
+	names, counts, err := reader.Parse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[1] != "PAPI_TOT_CYC" || counts[1] != 5678 {
+		t.Fatalf("Unexpected parse %v %v", names, counts)
+	}
+}
+
+// Ensure that the counts logged by regions and tasks are attached to
+// the corresponding regions of an execution trace.
+func TestReadRegions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skip(err) // Tracing is already enabled, as by "go test -trace".
+	}
+	ctx, task := NewTask(context.Background(), "test-task")
+	outer := StartRegion(ctx, "test-outer")
+	work(100000)
+	inner := StartRegion(ctx, "test-inner")
+	work(100000)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := StartRegion(ctx, "test-worker")
+		work(100000)
+		if err := r.End(); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+	for _, r := range []interface{ End() error }{inner, outer, task} {
+		if err := r.End(); err != nil {
+			trace.Stop()
+			t.Fatal(err)
+		}
+	}
+	trace.Stop()
+
+	regions, err := reader.ReadRegions(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byType := make(map[string]reader.RegionCounts)
+	for _, rc := range regions {
+		byType[rc.Type] = rc
+	}
+	events, counts := inner.Counts()
+	for _, name := range []string{"test-task", "test-outer", "test-inner", "test-worker"} {
+		rc, ok := byType[name]
+		if !ok {
+			t.Fatalf("Region %s is missing from %+v", name, regions)
+		}
+		if rc.Task == 0 || rc.EndNs < rc.StartNs || rc.ExecNs > rc.EndNs-rc.StartNs {
+			t.Fatalf("Unexpected region %+v", rc)
+		}
+		if len(rc.Events) != len(events) {
+			t.Fatalf("%s: expected counts of %v but saw %v", name, events, rc.Events)
+		}
+	}
+	rc := byType["test-inner"]
+	for i, ev := range events {
+		if rc.Events[i] != ev.String() || rc.Counts[i] != counts[i] {
+			t.Fatalf("%s: expected %d but saw %s=%d", ev, counts[i], rc.Events[i], rc.Counts[i])
+		}
+	}
+	if byType["test-worker"].Goroutine == rc.Goroutine {
+		t.Fatal("Expected the worker region to be attributed to its own goroutine")
+	}
+	if byType["test-outer"].Counts[0] < rc.Counts[0] {
+		t.Fatal("Expected the outer region to count at least as much as the inner region")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Package reader reads the counts that the gotrace package logs to Go
+execution traces.
+
+ReadRegions() reads an execution trace, such as one written by
+"go test -trace", and attaches the logged counts to the goroutine
+regions in which they were logged, along with the time each goroutine
+spent executing within each region.  Unlike gotrace, reader does not
+depend on PAPI, so traces can be analyzed on machines where PAPI is
+not installed.
+*/
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/trace"
+)
+
+// Category is the runtime/trace log category of counter annotations.
+const Category = "papi"
+
+// Parse a message written by gotrace.Format() into event names and
+// counts.
+func Parse(msg string) (names []string, counts []int64, err error) {
+	for _, field := range strings.Fields(msg) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || name == "" {
+			return nil, nil, fmt.Errorf("reader: %q is not of the form name=value", field)
+		}
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reader: %s: %w", name, err)
+		}
+		names = append(names, name)
+		counts = append(counts, v)
+	}
+	return names, counts, nil
+}
+
+// A RegionCounts describes a single instance of a runtime/trace region
+// and the counts logged within it.
+type RegionCounts struct {
+	Type      string   `json:"type"`             // Name of the region
+	Goroutine int64    `json:"goroutine"`        // Goroutine that ran the region
+	Task      uint64   `json:"task,omitempty"`   // Task with which the region is associated, or 0 for none
+	StartNs   int64    `json:"start_ns"`         // Trace time in nanoseconds at which the region began
+	EndNs     int64    `json:"end_ns"`           // Trace time in nanoseconds at which the region ended
+	ExecNs    int64    `json:"exec_ns"`          // Time in nanoseconds the goroutine spent running or in system calls
+	Events    []string `json:"events,omitempty"` // Names of the events logged in Category
+	Counts    []int64  `json:"counts,omitempty"` // Sum of the logged counts of each event
+}
+
+// Add logged counts to a region's, accumulating repeated events.
+func (rc *RegionCounts) add(names []string, counts []int64) {
+	for i, name := range names {
+		j := 0
+		for j < len(rc.Events) && rc.Events[j] != name {
+			j++
+		}
+		if j == len(rc.Events) {
+			rc.Events = append(rc.Events, name)
+			rc.Counts = append(rc.Counts, 0)
+		}
+		rc.Counts[j] += counts[i]
+	}
+}
+
+// The state of a goroutine while reading a trace
+type goroutine struct {
+	open      []*RegionCounts // Regions currently open, innermost last
+	executing bool            // Whether the goroutine is running or in a system call
+	since     int64           // Time at which the goroutine last began executing
+}
+
+// Credit a region with the time its goroutine spent executing between
+// the later of the region's start and the goroutine's last start and a
+// given time.
+func (g *goroutine) credit(rc *RegionCounts, now int64) {
+	if !g.executing {
+		return
+	}
+	from := g.since
+	if rc.StartNs > from {
+		from = rc.StartNs
+	}
+	rc.ExecNs += now - from
+}
+
+// Read an execution trace and return every region that ended within
+// it, in the order in which the regions ended.  Counts logged in
+// Category are attached to the innermost region open on the logging
+// goroutine; regions in which nothing was logged have no counts.
+// Messages in Category that Parse() rejects, which gotrace did not
+// write, are ignored.
+func ReadRegions(r io.Reader) ([]RegionCounts, error) {
+	tr, err := trace.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reader: %w", err)
+	}
+	gs := make(map[trace.GoID]*goroutine)
+	state := func(id trace.GoID) *goroutine {
+		g, ok := gs[id]
+		if !ok {
+			g = new(goroutine)
+			gs[id] = g
+		}
+		return g
+	}
+	var regions []RegionCounts
+	for {
+		ev, err := tr.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reader: %w", err)
+		}
+		now := int64(ev.Time())
+		switch ev.Kind() {
+		case trace.EventStateTransition:
+			st := ev.StateTransition()
+			if st.Resource.Kind != trace.ResourceGoroutine {
+				break
+			}
+			g := state(st.Resource.Goroutine())
+			_, to := st.Goroutine()
+			switch {
+			case to.Executing() && !g.executing:
+				g.executing = true
+				g.since = now
+			case !to.Executing() && g.executing:
+				for _, rc := range g.open {
+					g.credit(rc, now)
+				}
+				g.executing = false
+			}
+
+		case trace.EventRegionBegin:
+			reg := ev.Region()
+			g := state(ev.Goroutine())
+			g.open = append(g.open, &RegionCounts{
+				Type:      reg.Type,
+				Goroutine: int64(ev.Goroutine()),
+				Task:      uint64(reg.Task),
+				StartNs:   now,
+			})
+
+		case trace.EventRegionEnd:
+			g := state(ev.Goroutine())
+			if len(g.open) == 0 {
+				break // The region began before tracing did.
+			}
+			rc := g.open[len(g.open)-1]
+			g.open = g.open[:len(g.open)-1]
+			g.credit(rc, now)
+			rc.EndNs = now
+			regions = append(regions, *rc)
+
+		case trace.EventLog:
+			lg := ev.Log()
+			g := state(ev.Goroutine())
+			if lg.Category != Category || len(g.open) == 0 {
+				break
+			}
+			names, counts, err := Parse(lg.Message)
+			if err != nil {
+				break
+			}
+			g.open[len(g.open)-1].add(names, counts)
+		}
+	}
+	return regions, nil
+}
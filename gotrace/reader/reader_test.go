@@ -0,0 +1,59 @@
+// This file tests reading counts from execution traces.
+
+package reader
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"testing"
+)
+
+// Ensure that messages are parsed into names and counts.
+func TestParse(t *testing.T) {
+	names, counts, err := Parse("PAPI_TOT_INS=1234 PAPI_TOT_CYC=5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[1] != "PAPI_TOT_CYC" || counts[1] != 5678 {
+		t.Fatalf("Unexpected parse %v %v", names, counts)
+	}
+	for _, msg := range []string{"PAPI_TOT_INS", "=5", "PAPI_TOT_INS=five"} {
+		if _, _, err := Parse(msg); err == nil {
+			t.Fatalf("Expected %q to be rejected", msg)
+		}
+	}
+}
+
+// Ensure that counts logged in Category are attached to their region
+// and that malformed messages are ignored rather than aborting the
+// read.
+func TestReadRegions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skip(err) // Tracing is already enabled, as by "go test -trace".
+	}
+	ctx := context.Background()
+	trace.WithRegion(ctx, "test-region", func() {
+		trace.Log(ctx, Category, "PAPI_TOT_INS=5")
+		trace.Log(ctx, Category, "not a count")
+		trace.Log(ctx, "other", "PAPI_TOT_INS=100")
+		trace.Log(ctx, Category, "PAPI_TOT_INS=7 PAPI_TOT_CYC=3")
+	})
+	trace.Stop()
+
+	regions, err := ReadRegions(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rc := range regions {
+		if rc.Type != "test-region" {
+			continue
+		}
+		if len(rc.Events) != 2 || rc.Events[0] != "PAPI_TOT_INS" || rc.Counts[0] != 12 || rc.Counts[1] != 3 {
+			t.Fatalf("Unexpected counts %v %v", rc.Events, rc.Counts)
+		}
+		return
+	}
+	t.Fatalf("Region test-region is missing from %+v", regions)
+}
@@ -0,0 +1,53 @@
+// This file pins threads to CPUs on Linux.
+
+package harness
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// cpuSetWords is the number of words in the CPU mask passed to the
+// kernel, enough for 1024 CPUs.
+const cpuSetWords = 1024 / 64
+
+// A cpuSet is a Linux CPU affinity mask.
+type cpuSet [cpuSetWords]uint64
+
+// Return the calling thread's affinity mask.
+func getAffinity() (*cpuSet, error) {
+	set := new(cpuSet)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(*set), uintptr(unsafe.Pointer(set)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return set, nil
+}
+
+// Set the calling thread's affinity mask.
+func setAffinity(set *cpuSet) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(*set), uintptr(unsafe.Pointer(set)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Pin the calling thread, which must be locked to its goroutine, to a
+// single CPU.  Return a function that restores the thread's previous
+// affinity.
+func pinThread(cpu int) (restore func(), err error) {
+	if cpu < 0 || cpu >= 64*cpuSetWords {
+		return nil, syscall.EINVAL
+	}
+	old, err := getAffinity()
+	if err != nil {
+		return nil, err
+	}
+	var set cpuSet
+	set[cpu/64] = 1 << (cpu % 64)
+	if err = setAffinity(&set); err != nil {
+		return nil, err
+	}
+	return func() { setAffinity(old) }, nil
+}
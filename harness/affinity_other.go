@@ -0,0 +1,11 @@
+//go:build !linux
+
+// This file stands in for CPU pinning on systems that lack
+// sched_setaffinity().
+
+package harness
+
+// Do not pin the calling thread.
+func pinThread(cpu int) (restore func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,30 @@
+// This file measures the overhead that the harness's own start and
+// stop operations contribute to every run so that it can be subtracted.
+
+package harness
+
+import (
+	"math"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/harness/results"
+)
+
+// Measure the overhead of measuring the given events by running an
+// empty function with a given configuration.  Outliers are rejected
+// before taking medians only if the configuration requests it.  Any
+// Calibration in the configuration is ignored.
+func Calibrate(events []papi.Event, config *Config) (*results.Calibration, error) {
+	cfg := config.withDefaults()
+	cfg.Calibration = nil
+	res, err := Run(events, func() {}, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	cal := &results.Calibration{Events: res.Events, Runs: len(res.ElapsedNs)}
+	for _, name := range res.Events {
+		cal.Counts = append(cal.Counts, int64(math.Round(res.Summary(name).Median)))
+	}
+	cal.ElapsedNs = int64(math.Round(res.Summary(results.ElapsedName).Median))
+	return cal, nil
+}
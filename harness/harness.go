@@ -0,0 +1,161 @@
+/*
+Package harness measures a function repeatedly and summarizes the
+resulting counter values statistically, because a single measurement
+is often too noisy to act upon.
+
+Run() executes a function a number of times after some unmeasured
+warm-up runs, optionally pinning the measuring thread to a CPU and
+flushing the caches before each run.  It reports, for each event and
+for elapsed time, the mean, median, standard deviation, extremes, and
+a confidence interval for the mean after rejecting outliers.
+Calibrate() measures the overhead of the measurement itself, which
+Run() subtracts from every run when given the calibration.  Results
+are described by the results subpackage, which writes them as JSON or
+CSV and determines which differences between two sets of results are
+significant.
+*/
+package harness
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/harness/results"
+)
+
+// Config specifies how to measure a function.  The zero value selects
+// the defaults.
+type Config struct {
+	Runs        int                   // Number of measured runs (default 30)
+	Warmup      int                   // Number of unmeasured runs that precede the measured runs (default 3; negative for none)
+	Pin         bool                  // Pin the measuring thread to CPU while measuring (Linux only)
+	CPU         int                   // CPU to which to pin the measuring thread if Pin is true
+	FlushCaches bool                  // Evict the caches before every run
+	Outliers    results.OutlierMethod // Method by which to reject outliers
+	Confidence  float64               // Confidence level of the confidence intervals (default 0.95)
+	Calibration *results.Calibration  // Measurement overhead to subtract from every run, if non-nil
+}
+
+// Return a copy of a configuration with defaults filled in.
+func (c *Config) withDefaults() Config {
+	var cfg Config
+	if c != nil {
+		cfg = *c
+	}
+	if cfg.Runs <= 0 {
+		cfg.Runs = 30
+	}
+	switch {
+	case cfg.Warmup == 0:
+		cfg.Warmup = 3
+	case cfg.Warmup < 0:
+		cfg.Warmup = 0
+	}
+	if cfg.Confidence <= 0 || cfg.Confidence >= 1 {
+		cfg.Confidence = 0.95
+	}
+	return cfg
+}
+
+// Measure a function repeatedly and summarize the counts of the given
+// events.  The function is run on a single OS thread, which is reused
+// for every run.
+func Run(events []papi.Event, work func(), config *Config) (*results.Result, error) {
+	cfg := config.withDefaults()
+	res := &results.Result{Outliers: cfg.Outliers, Confidence: cfg.Confidence, Calibration: cfg.Calibration}
+	for _, ev := range events {
+		res.Events = append(res.Events, ev.String())
+	}
+
+	// Prepare to measure.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if cfg.Pin {
+		restore, err := pinThread(cfg.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("harness: pinning to CPU %d: %w", cfg.CPU, err)
+		}
+		defer restore()
+	}
+	var flusher *cacheFlusher
+	if cfg.FlushCaches {
+		flusher = newCacheFlusher(papi.GetHardwareInfo())
+	}
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return nil, fmt.Errorf("harness: %w", err)
+	}
+	defer es.DestroyEventSet()
+	defer es.CleanupEventSet()
+	if err = es.AddEvents(events); err != nil {
+		return nil, fmt.Errorf("harness: %w", err)
+	}
+
+	// Run the function repeatedly.
+	for r := 0; r < cfg.Warmup+cfg.Runs; r++ {
+		if flusher != nil {
+			flusher.flush()
+		}
+		values := make([]int64, len(events))
+		if err = es.Start(); err != nil {
+			return nil, fmt.Errorf("harness: %w", err)
+		}
+		start := time.Now()
+		work()
+		elapsed := time.Since(start)
+		if err = es.Stop(values); err != nil {
+			return nil, fmt.Errorf("harness: %w", err)
+		}
+		if r >= cfg.Warmup {
+			ns := elapsed.Nanoseconds()
+			if cfg.Calibration != nil {
+				ns = cfg.Calibration.Subtract(res.Events, values, ns)
+			}
+			res.Counts = append(res.Counts, values)
+			res.ElapsedNs = append(res.ElapsedNs, ns)
+		}
+	}
+	res.Summarize()
+	return res, nil
+}
+
+// ----------------------------------------------------------------------
+
+// A cacheFlusher evicts the caches by writing a buffer larger than
+// the largest cache.
+type cacheFlusher struct {
+	buf []byte
+	sum byte // Accumulated to keep the compiler from eliding reads
+}
+
+// minFlushBytes is the smallest buffer used to flush the caches.
+const minFlushBytes = 32 << 20
+
+// Create a cache flusher sized to twice the largest cache described
+// by a memory hierarchy but no smaller than minFlushBytes.
+func newCacheFlusher(hw papi.HardwareInfo) *cacheFlusher {
+	largest := int64(0)
+	for _, level := range hw.MemHierarchy {
+		for _, c := range level.Cache {
+			if int64(c.Size) > largest {
+				largest = int64(c.Size)
+			}
+		}
+	}
+	size := 2 * largest
+	if size < minFlushBytes {
+		size = minFlushBytes
+	}
+	return &cacheFlusher{buf: make([]byte, size)}
+}
+
+// Evict the caches.
+func (f *cacheFlusher) flush() {
+	const stride = 64 // Smaller than any cache line
+	for i := 0; i < len(f.buf); i += stride {
+		f.buf[i]++
+		f.sum += f.buf[i]
+	}
+}
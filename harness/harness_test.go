@@ -0,0 +1,90 @@
+// This file tests the repeated-measurement harness.
+
+package harness
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/harness/results"
+)
+
+// Ensure that a function can be measured, serialized, and compared.
+func TestRun(t *testing.T) {
+	calls := 0
+	res, err := Run([]papi.Event{papi.TOT_INS}, func() { calls++ }, &Config{Runs: 5, Warmup: 2, Outliers: results.MAD, FlushCaches: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 7 || len(res.Counts) != 5 || len(res.ElapsedNs) != 5 {
+		t.Fatalf("Expected 7 calls and 5 measurements but saw %d calls, %d counts, and %d times",
+			calls, len(res.Counts), len(res.ElapsedNs))
+	}
+	if res.Summary("PAPI_TOT_INS") == nil || res.Summary(results.ElapsedName) == nil {
+		t.Fatalf("Missing summaries in %+v", res.Summaries)
+	}
+
+	var buf bytes.Buffer
+	if err = res.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	res2, err := results.ReadResult(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2.Outliers != results.MAD || len(res2.Counts) != 5 || res2.Summary("PAPI_TOT_INS").N != res.Summary("PAPI_TOT_INS").N {
+		t.Fatalf("Result changed in a round trip: %+v", res2)
+	}
+	cmps := results.Compare(res, res2, 0.05)
+	if len(cmps) != 2 || cmps[0].Name != "PAPI_TOT_INS" || cmps[1].Name != results.ElapsedName {
+		t.Fatalf("Unexpected comparisons %+v", cmps)
+	}
+	for _, c := range cmps {
+		if c.Significant {
+			t.Fatalf("Expected identical results not to differ significantly: %+v", c)
+		}
+	}
+}
+
+// Ensure that a calibration can be measured, serialized, and subtracted
+// from subsequent runs.
+func TestCalibrate(t *testing.T) {
+	cfg := &Config{Runs: 5, Warmup: 1}
+	cal, err := Calibrate([]papi.Event{papi.TOT_INS}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.Runs != 5 || len(cal.Events) != 1 || len(cal.Counts) != 1 || cal.Events[0] != "PAPI_TOT_INS" {
+		t.Fatalf("Unexpected calibration %+v", cal)
+	}
+
+	var buf bytes.Buffer
+	if err = cal.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cal2, err := results.ReadCalibration(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal2.Counts[0] != cal.Counts[0] || cal2.ElapsedNs != cal.ElapsedNs {
+		t.Fatalf("Calibration changed in a round trip: %+v", cal2)
+	}
+
+	// Subtraction applies only to calibrated events.
+	cal2.Events = append(cal2.Events, "PAPI_TOT_CYC")
+	cal2.Counts = append(cal2.Counts, 7)
+	values := []int64{1000, 1000}
+	if ns := cal2.Subtract([]string{"PAPI_TOT_CYC", "PAPI_L1_DCM"}, values, 500); values[0] != 993 || values[1] != 1000 || ns != 500-cal.ElapsedNs {
+		t.Fatalf("Unexpected corrected values %v and %d ns", values, ns)
+	}
+
+	cfg.Calibration = cal
+	res, err := Run([]papi.Event{papi.TOT_INS}, func() {}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Calibration != cal || len(res.Counts) != 5 {
+		t.Fatalf("Unexpected calibrated result %+v", res)
+	}
+}
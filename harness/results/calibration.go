@@ -0,0 +1,63 @@
+// This file describes the overhead that the harness's own start and
+// stop operations contribute to every run so that it can be subtracted.
+
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A Calibration records the counts and elapsed time that harness.Run()
+// attributes to an empty function.  These represent the cost of
+// starting and stopping the counters and the timer rather than the
+// cost of the function being measured.
+type Calibration struct {
+	Events    []string `json:"events"`     // Names of the events calibrated
+	Counts    []int64  `json:"counts"`     // Median count of each event across empty runs
+	ElapsedNs int64    `json:"elapsed_ns"` // Median elapsed time of an empty run in nanoseconds
+	Runs      int      `json:"runs"`       // Number of empty runs measured
+}
+
+// Return the overhead of a named event, and whether it was calibrated.
+func (c *Calibration) count(name string) (int64, bool) {
+	for i, ename := range c.Events {
+		if ename == name {
+			return c.Counts[i], true
+		}
+	}
+	return 0, false
+}
+
+// Subtract the calibrated overhead from a single run's counts, which
+// correspond to the named events, and elapsed time.  Events that were
+// not calibrated are left unchanged.  Results are not clamped at zero
+// lest the correction bias the mean of short runs upward.
+func (c *Calibration) Subtract(events []string, values []int64, elapsed int64) int64 {
+	for i, name := range events {
+		if ov, ok := c.count(name); ok {
+			values[i] -= ov
+		}
+	}
+	return elapsed - c.ElapsedNs
+}
+
+// Write a calibration to a stream in JSON format.
+func (c *Calibration) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// Read a calibration written by Calibration.Write().
+func ReadCalibration(r io.Reader) (*Calibration, error) {
+	cal := new(Calibration)
+	if err := json.NewDecoder(r).Decode(cal); err != nil {
+		return nil, err
+	}
+	if len(cal.Counts) != len(cal.Events) {
+		return nil, fmt.Errorf("results: calibration has %d counts but %d events", len(cal.Counts), len(cal.Events))
+	}
+	return cal, nil
+}
@@ -0,0 +1,161 @@
+// This file compares two harness results with configurable
+// statistical tests and regression thresholds.
+
+package results
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lanl/go-papi/metrics/formula"
+)
+
+// A Test is a statistical test of whether two sets of runs differ.
+type Test int
+
+const (
+	TTest        Test = iota // Welch's t-test, which compares means
+	MannWhitneyU             // Mann–Whitney U test, which makes no assumption about the distribution
+)
+
+// A Threshold bounds the acceptable relative change in a quantity.
+type Threshold struct {
+	Name  string  // Event, metric, or ElapsedName; "*" applies to every quantity without its own threshold
+	Limit float64 // Largest acceptable increase if positive or decrease if negative (e.g., 0.05 or -0.05)
+}
+
+// Parse a threshold of the form "name=limit", where limit is a
+// relative change written either as a fraction (e.g., "0.05") or as a
+// percentage (e.g., "+5%").  A negative limit bounds decreases, as is
+// appropriate for quantities such as instructions per cycle for which
+// larger values are better.
+func ParseThreshold(spec string) (Threshold, error) {
+	name, limit, ok := strings.Cut(spec, "=")
+	name, limit = strings.TrimSpace(name), strings.TrimSpace(limit)
+	if !ok || name == "" || limit == "" {
+		return Threshold{}, fmt.Errorf("results: threshold %q is not of the form name=limit", spec)
+	}
+	scale := 1.0
+	if strings.HasSuffix(limit, "%") {
+		limit, scale = strings.TrimSuffix(limit, "%"), 0.01
+	}
+	v, err := strconv.ParseFloat(limit, 64)
+	if err != nil {
+		return Threshold{}, fmt.Errorf("results: threshold %q has a malformed limit", spec)
+	}
+	return Threshold{Name: name, Limit: v * scale}, nil
+}
+
+// Return true if a relative change exceeds a threshold.
+func (t Threshold) exceeded(delta float64) bool {
+	if t.Limit < 0 {
+		return delta < t.Limit
+	}
+	return delta > t.Limit
+}
+
+// DiffOptions control Diff().  The zero value selects Welch's t-test at
+// a significance level of 0.05 with no thresholds.
+type DiffOptions struct {
+	Test       Test               // Statistical test to apply
+	Alpha      float64            // Significance level (default 0.05)
+	Metrics    []*formula.Formula // Derived metrics to compute from each run's counts and compare
+	Thresholds []Threshold        // Limits on the change in each quantity
+}
+
+// A Delta is the comparison of a single quantity between two results.
+type Delta struct {
+	Comparison
+	Threshold  *Threshold // Threshold applied to the quantity, if any
+	Regression bool       // true if the change is significant and exceeds the threshold
+}
+
+// Compare two results quantity by quantity.  Events are paired by
+// name; events that appear in only one result are ignored.  Elapsed
+// time and each of the given metrics, computed per run from the counts
+// of the events it names, are compared as well.  Outliers are rejected from each result as specified by
+// that result.  A quantity regresses if it changes significantly and
+// by more than its threshold.
+func Diff(before, after *Result, opts *DiffOptions) ([]Delta, error) {
+	var o DiffOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Alpha <= 0 {
+		o.Alpha = 0.05
+	}
+	test := welch
+	if o.Test == MannWhitneyU {
+		test = mannWhitney
+	}
+	thresholds := make(map[string]*Threshold, len(o.Thresholds))
+	for i := range o.Thresholds {
+		thresholds[o.Thresholds[i].Name] = &o.Thresholds[i]
+	}
+
+	// Gather the series of values to compare.
+	type pair struct {
+		name string
+		a, b []float64
+	}
+	var pairs []pair
+	for _, name := range append(append([]string(nil), before.Events...), ElapsedName) {
+		a, ok := before.series(name)
+		if !ok {
+			continue
+		}
+		if b, ok := after.series(name); ok {
+			pairs = append(pairs, pair{name, a, b})
+		}
+	}
+	for _, m := range o.Metrics {
+		a, err := before.metricSeries(m)
+		if err != nil {
+			return nil, err
+		}
+		b, err := after.metricSeries(m)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{m.Name, a, b})
+	}
+
+	// Compare each pair of series.
+	deltas := make([]Delta, 0, len(pairs))
+	for _, p := range pairs {
+		a := retain(p.a, before.Outliers.outliers(p.a))
+		b := retain(p.b, after.Outliers.outliers(p.b))
+		d := Delta{Comparison: test(p.name, a, b, o.Alpha)}
+		if t, ok := thresholds[p.name]; ok {
+			d.Threshold = t
+		} else if t, ok := thresholds["*"]; ok {
+			d.Threshold = t
+		}
+		d.Regression = d.Significant && d.Threshold != nil && d.Threshold.exceeded(d.Delta)
+		deltas = append(deltas, d)
+	}
+	return deltas, nil
+}
+
+// Return the value of a metric in each run.  The metric's events are
+// matched to the result's by name.
+func (r *Result) metricSeries(m *formula.Formula) ([]float64, error) {
+	xs := make([]float64, len(r.Counts))
+	for i, run := range r.Counts {
+		counts := make(map[string]int64, len(r.Events))
+		for e, name := range r.Events {
+			counts[name] = run[e]
+		}
+		v, err := m.Eval(counts)
+		if err != nil {
+			return nil, fmt.Errorf("results: metric %s, run %d: %w", m.Name, i, err)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("results: metric %s, run %d: value is %g", m.Name, i, v)
+		}
+		xs[i] = v
+	}
+	return xs, nil
+}
@@ -0,0 +1,174 @@
+/*
+Package results holds, summarizes, and compares the repeated
+measurements that the harness package produces.
+
+A Result records every run's event counts, by event name, and elapsed
+time, together with a statistical summary of each.  Results can be
+written and read as JSON or CSV, and Diff() and Compare() determine
+which quantities differ significantly between two results.  Because
+results does not depend on PAPI, programs that only analyze saved
+results, such as papidiff, need not link libpapi.
+*/
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ElapsedName is the name of the summary that describes the elapsed
+// time per run in nanoseconds.
+const ElapsedName = "elapsed_ns"
+
+// A Result holds every run's measurements and their summaries.
+type Result struct {
+	Events     []string      `json:"events"`     // Names of the events counted
+	Outliers   OutlierMethod `json:"outliers"`   // Method by which outliers were rejected
+	Confidence float64       `json:"confidence"` // Confidence level of the confidence intervals
+	Counts     [][]int64     `json:"counts"`     // Counts[r][e] is the count of event e in run r
+	ElapsedNs  []int64       `json:"elapsed_ns"` // Elapsed time of each run in nanoseconds
+	Summaries  []Summary     `json:"summaries"`  // Summary of each event followed by elapsed time
+
+	Calibration *Calibration `json:"calibration,omitempty"` // Overhead subtracted from every run, if any
+}
+
+// Return the values of the named quantity across runs.
+func (r *Result) series(name string) ([]float64, bool) {
+	xs := make([]float64, 0, len(r.ElapsedNs))
+	if name == ElapsedName {
+		for _, v := range r.ElapsedNs {
+			xs = append(xs, float64(v))
+		}
+		return xs, true
+	}
+	for e, ename := range r.Events {
+		if ename == name {
+			for _, run := range r.Counts {
+				xs = append(xs, float64(run[e]))
+			}
+			return xs, true
+		}
+	}
+	return nil, false
+}
+
+// Compute the summaries of every event and of elapsed time, for
+// example after appending runs or changing the outlier method.
+func (r *Result) Summarize() {
+	r.Summaries = r.Summaries[:0]
+	for _, name := range append(append([]string(nil), r.Events...), ElapsedName) {
+		xs, _ := r.series(name)
+		r.Summaries = append(r.Summaries, summarize(name, xs, r.Outliers, r.Confidence))
+	}
+}
+
+// Return the summary of a named event or of ElapsedName, or nil if
+// there is no such summary.
+func (r *Result) Summary(name string) *Summary {
+	for i := range r.Summaries {
+		if r.Summaries[i].Name == name {
+			return &r.Summaries[i]
+		}
+	}
+	return nil
+}
+
+// Write a result to a stream in JSON format.
+func (r *Result) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Read a result written by Result.Write().
+func ReadResult(r io.Reader) (*Result, error) {
+	res := new(Result)
+	if err := json.NewDecoder(r).Decode(res); err != nil {
+		return nil, err
+	}
+	for i, run := range res.Counts {
+		if len(run) != len(res.Events) {
+			return nil, fmt.Errorf("results: run %d has %d counts but %d events", i, len(run), len(res.Events))
+		}
+	}
+	return res, nil
+}
+
+// Compare the quantities common to two results using Welch's t-test,
+// each after rejecting outliers as specified by its result.  A
+// difference is deemed significant if its p-value is less than alpha
+// (e.g., 0.05).  See Diff() for more control over the comparison.
+func Compare(before, after *Result, alpha float64) []Comparison {
+	deltas, _ := Diff(before, after, &DiffOptions{Alpha: alpha})
+	cmps := make([]Comparison, len(deltas))
+	for i, d := range deltas {
+		cmps[i] = d.Comparison
+	}
+	return cmps
+}
+
+// Write each run's measurements to a stream in CSV format.  The header
+// row names the columns "run", each event, and ElapsedName.  Summaries
+// are not written; they are recomputed by ReadResultCSV().
+func (r *Result) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append(append([]string{"run"}, r.Events...), ElapsedName))
+	for i, run := range r.Counts {
+		row := []string{strconv.Itoa(i)}
+		for _, v := range run {
+			row = append(row, strconv.FormatInt(v, 10))
+		}
+		row = append(row, strconv.FormatInt(r.ElapsedNs[i], 10))
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Read a result written by Result.WriteCSV() and summarize it, rejecting
+// outliers with a given method.  The ElapsedName column is optional.
+func ReadResultCSV(r io.Reader, outliers OutlierMethod) (*Result, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) < 1 || rows[0][0] != "run" {
+		return nil, fmt.Errorf(`harness: CSV data must begin with a header row whose first column is "run"`)
+	}
+	res := &Result{Outliers: outliers, Confidence: 0.95}
+	header := rows[0][1:]
+	elapsedCol := -1
+	for i, name := range header {
+		if name == ElapsedName {
+			elapsedCol = i
+		} else {
+			res.Events = append(res.Events, name)
+		}
+	}
+	for n, row := range rows[1:] {
+		if len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("results: CSV row %d has %d columns but the header has %d", n+2, len(row), len(rows[0]))
+		}
+		run := make([]int64, 0, len(res.Events))
+		elapsed := int64(0)
+		for i, field := range row[1:] {
+			v, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("results: CSV row %d, column %s: %w", n+2, header[i], err)
+			}
+			if i == elapsedCol {
+				elapsed = v
+			} else {
+				run = append(run, v)
+			}
+		}
+		res.Counts = append(res.Counts, run)
+		res.ElapsedNs = append(res.ElapsedNs, elapsed)
+	}
+	res.Summarize()
+	return res, nil
+}
@@ -0,0 +1,166 @@
+// This file tests summarizing, serializing, and comparing results.
+
+package results
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/lanl/go-papi/metrics/formula"
+)
+
+// Ensure that descriptive statistics are computed correctly.
+func TestSummarize(t *testing.T) {
+	xs := []float64{10, 12, 11, 13, 12, 11, 10, 12, 11, 100}
+	s := summarize("x", xs, NoRejection, 0.95)
+	if s.N != 10 || s.Min != 10 || s.Max != 100 || s.Median != 11.5 || s.Mean != 20.2 {
+		t.Fatalf("Unexpected summary %+v", s)
+	}
+	for _, method := range []OutlierMethod{MAD, IQR} {
+		s = summarize("x", xs, method, 0.95)
+		if len(s.Outliers) != 1 || s.Outliers[0] != 9 || s.N != 9 || s.Max != 13 {
+			t.Fatalf("%s: expected only the last value to be rejected but saw %+v", method, s)
+		}
+		if s.CILow >= s.Mean || s.CIHigh <= s.Mean {
+			t.Fatalf("%s: confidence interval [%g, %g] excludes the mean %g", method, s.CILow, s.CIHigh, s.Mean)
+		}
+	}
+
+	// Nearly constant values have no spread by which to judge outliers.
+	for _, method := range []OutlierMethod{MAD, IQR} {
+		if idxs := method.outliers([]float64{5, 5, 5, 4, 5, 5, 6, 5}); idxs != nil {
+			t.Fatalf("%s: expected no outliers in nearly constant values but saw %v", method, idxs)
+		}
+	}
+}
+
+// Ensure that Student's t distribution is computed accurately.
+func TestStudentT(t *testing.T) {
+	for _, tc := range []struct {
+		p, df, want float64
+	}{
+		{0.975, 9, 2.262157},
+		{0.975, 1, 12.706205},
+		{0.995, 29, 2.756386},
+		{0.95, 1000, 1.646379},
+	} {
+		if got := tQuantile(tc.p, tc.df); math.Abs(got-tc.want) > 1e-5 {
+			t.Fatalf("t(%g, %g): expected %g but saw %g", tc.p, tc.df, tc.want, got)
+		}
+	}
+	if p := tPValue(2.262157, 9); math.Abs(p-0.05) > 1e-6 {
+		t.Fatalf("Expected a p-value of 0.05 but saw %g", p)
+	}
+}
+
+// Ensure that Welch's t-test distinguishes differing means.
+func TestWelch(t *testing.T) {
+	a := []float64{10, 11, 9, 10, 10, 11, 9, 10}
+	b := []float64{12, 13, 11, 12, 12, 13, 11, 12}
+	if c := welch("x", a, b, 0.05); !c.Significant || c.Delta != 0.2 || c.P > 1e-3 {
+		t.Fatalf("Expected a significant 20%% increase but saw %+v", c)
+	}
+	if c := welch("x", a, a, 0.05); c.Significant || c.P != 1 {
+		t.Fatalf("Expected no significant difference but saw %+v", c)
+	}
+}
+
+// Return a result with a single event whose counts are given.
+func resultOf(ename string, counts ...int64) *Result {
+	r := &Result{Events: []string{ename}, Confidence: 0.95}
+	for _, c := range counts {
+		r.Counts = append(r.Counts, []int64{c})
+		r.ElapsedNs = append(r.ElapsedNs, 1000)
+	}
+	r.Summarize()
+	return r
+}
+
+// Ensure that thresholds are parsed correctly.
+func TestParseThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want Threshold
+	}{
+		{"PAPI_L2_TCM=+5%", Threshold{"PAPI_L2_TCM", 0.05}},
+		{"IPC = -2%", Threshold{"IPC", -0.02}},
+		{"*=0.1", Threshold{"*", 0.1}},
+	} {
+		got, err := ParseThreshold(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != tc.want.Name || math.Abs(got.Limit-tc.want.Limit) > 1e-12 {
+			t.Fatalf("%s: expected %+v but saw %+v", tc.spec, tc.want, got)
+		}
+	}
+	for _, spec := range []string{"", "PAPI_L2_TCM", "=5%", "PAPI_L2_TCM=five"} {
+		if _, err := ParseThreshold(spec); err == nil {
+			t.Fatalf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// Ensure that Diff() flags only significant changes beyond their
+// thresholds.
+func TestDiff(t *testing.T) {
+	before := resultOf("PAPI_L2_TCM", 100, 101, 99, 100, 102, 98, 100, 100)
+	after := resultOf("PAPI_L2_TCM", 110, 111, 109, 110, 112, 108, 110, 110)
+	m, err := formula.New("DOUBLE_L2", "", "2 * PAPI_L2_TCM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []Test{TTest, MannWhitneyU} {
+		for _, tc := range []struct {
+			limit      string
+			regression bool
+		}{
+			{"PAPI_L2_TCM=+5%", true},
+			{"PAPI_L2_TCM=+15%", false},
+			{"PAPI_L2_TCM=-5%", false},
+			{"*=+5%", true},
+		} {
+			th, err := ParseThreshold(tc.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deltas, err := Diff(before, after, &DiffOptions{Test: test, Thresholds: []Threshold{th}, Metrics: []*formula.Formula{m}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deltas) != 3 || deltas[0].Name != "PAPI_L2_TCM" || deltas[1].Name != ElapsedName || deltas[2].Name != "DOUBLE_L2" {
+				t.Fatalf("Unexpected deltas %+v", deltas)
+			}
+			d := deltas[0]
+			if !d.Significant || math.Abs(d.Delta-0.1) > 1e-9 || d.Regression != tc.regression {
+				t.Fatalf("test %d, %s: unexpected delta %+v", test, tc.limit, d)
+			}
+			if deltas[1].Significant || deltas[1].Regression {
+				t.Fatalf("test %d: constant elapsed time should not differ: %+v", test, deltas[1])
+			}
+			if math.Abs(deltas[2].OldMean-2*d.OldMean) > 1e-9 {
+				t.Fatalf("test %d: unexpected metric delta %+v", test, deltas[2])
+			}
+		}
+	}
+}
+
+// Ensure that results survive a round trip through CSV.
+func TestResultCSV(t *testing.T) {
+	r := resultOf("PAPI_TOT_INS", 5, 6, 7)
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "run,PAPI_TOT_INS,elapsed_ns\n0,5,1000\n1,6,1000\n2,7,1000\n"; got != want {
+		t.Fatalf("Expected %q but saw %q", want, got)
+	}
+	r2, err := ReadResultCSV(&buf, IQR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2.Outliers != IQR || len(r2.Counts) != 3 || r2.Counts[2][0] != 7 || r2.Summary("PAPI_TOT_INS").Mean != 6 {
+		t.Fatalf("Result changed in a round trip: %+v", r2)
+	}
+}
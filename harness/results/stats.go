@@ -0,0 +1,390 @@
+// This file provides the descriptive statistics, outlier rejection,
+// and significance tests used to summarize and compare results.
+
+package results
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// An OutlierMethod specifies how outliers are identified.
+type OutlierMethod int
+
+const (
+	NoRejection OutlierMethod = iota // Retain every run
+	MAD                              // Reject runs more than 3 scaled median absolute deviations from the median
+	IQR                              // Reject runs more than 1.5 interquartile ranges outside the quartiles
+)
+
+// Names of each outlier method
+var outlierNames = []string{"none", "mad", "iqr"}
+
+// Return the name of an outlier method.
+func (m OutlierMethod) String() string {
+	if m < 0 || int(m) >= len(outlierNames) {
+		return "unknown"
+	}
+	return outlierNames[m]
+}
+
+// Represent an outlier method by its name in JSON.
+func (m OutlierMethod) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Parse an outlier method from its name.
+func (m *OutlierMethod) UnmarshalText(text []byte) error {
+	for i, name := range outlierNames {
+		if name == string(text) {
+			*m = OutlierMethod(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("results: unknown outlier method %q", text)
+}
+
+// madScale converts a median absolute deviation to an estimate of the
+// standard deviation of normally distributed data.
+const madScale = 1.4826
+
+// Return the indexes of the values that a given method deems to be
+// outliers, in increasing order.
+func (m OutlierMethod) outliers(xs []float64) []int {
+	if len(xs) < 3 {
+		return nil
+	}
+	var lo, hi float64
+	switch m {
+	case MAD:
+		med := median(xs)
+		devs := make([]float64, len(xs))
+		for i, x := range xs {
+			devs[i] = math.Abs(x - med)
+		}
+		mad := madScale * median(devs)
+		if mad == 0 {
+			return nil
+		}
+		lo, hi = med-3*mad, med+3*mad
+	case IQR:
+		sorted := sortedCopy(xs)
+		q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+		iqr := q3 - q1
+		if iqr == 0 {
+			return nil
+		}
+		lo, hi = q1-1.5*iqr, q3+1.5*iqr
+	default:
+		return nil
+	}
+	var idxs []int
+	for i, x := range xs {
+		if x < lo || x > hi {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// Return a sorted copy of a slice.
+func sortedCopy(xs []float64) []float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	return s
+}
+
+// Return the q-th quantile of sorted data using linear interpolation
+// between closest ranks.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(i)
+	return sorted[i] + frac*(sorted[i+1]-sorted[i])
+}
+
+// Return the median of unsorted data.
+func median(xs []float64) float64 {
+	return quantile(sortedCopy(xs), 0.5)
+}
+
+// Return the mean and sample variance of a data set.
+func meanVar(xs []float64) (mean, variance float64) {
+	n := float64(len(xs))
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= n
+	if n < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		d := x - mean
+		variance += d * d
+	}
+	return mean, variance / (n - 1)
+}
+
+// A Summary describes the distribution of a single quantity across
+// runs.
+type Summary struct {
+	Name     string  `json:"name"`               // Name of the event or other quantity
+	N        int     `json:"n"`                  // Number of runs retained
+	Outliers []int   `json:"outliers,omitempty"` // Indexes of the runs rejected as outliers
+	Mean     float64 `json:"mean"`               // Arithmetic mean
+	Median   float64 `json:"median"`             // Median
+	StdDev   float64 `json:"stddev"`             // Sample standard deviation
+	Min      float64 `json:"min"`                // Minimum
+	Max      float64 `json:"max"`                // Maximum
+	CILow    float64 `json:"ci_low"`             // Lower bound of the confidence interval for the mean
+	CIHigh   float64 `json:"ci_high"`            // Upper bound of the confidence interval for the mean
+}
+
+// Summarize a data set after rejecting outliers.  The confidence
+// interval for the mean is based on Student's t distribution.
+func summarize(name string, xs []float64, method OutlierMethod, confidence float64) Summary {
+	s := Summary{Name: name, Outliers: method.outliers(xs)}
+	kept := retain(xs, s.Outliers)
+	s.N = len(kept)
+	if s.N == 0 {
+		return s
+	}
+	sorted := sortedCopy(kept)
+	s.Min, s.Max = sorted[0], sorted[len(sorted)-1]
+	s.Median = quantile(sorted, 0.5)
+	var variance float64
+	s.Mean, variance = meanVar(kept)
+	s.StdDev = math.Sqrt(variance)
+	s.CILow, s.CIHigh = s.Mean, s.Mean
+	if s.N > 1 {
+		half := tQuantile(1-(1-confidence)/2, float64(s.N-1)) * s.StdDev / math.Sqrt(float64(s.N))
+		s.CILow, s.CIHigh = s.Mean-half, s.Mean+half
+	}
+	return s
+}
+
+// Return the values whose indexes do not appear in a sorted list of
+// indexes to omit.
+func retain(xs []float64, omit []int) []float64 {
+	kept := make([]float64, 0, len(xs)-len(omit))
+	j := 0
+	for i, x := range xs {
+		if j < len(omit) && omit[j] == i {
+			j++
+			continue
+		}
+		kept = append(kept, x)
+	}
+	return kept
+}
+
+// ----------------------------------------------------------------------
+
+// Return the regularized incomplete beta function I_x(a, b).
+func incBeta(x, a, b float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(x, a, b) / a
+	}
+	return 1 - front*betaCF(1-x, b, a)/b
+}
+
+// Evaluate the continued fraction for the incomplete beta function by
+// the modified Lentz method.
+func betaCF(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		m2 := 2 * m
+		num := m * (b - m) * x / ((a + m2 - 1) * (a + m2))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		num = -(a + m) * (a + b + m) * x / ((a + m2) * (a + m2 + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return h
+}
+
+// Return the two-sided p-value of a t statistic with df degrees of
+// freedom.
+func tPValue(t, df float64) float64 {
+	if math.IsNaN(t) {
+		return math.NaN()
+	}
+	if math.IsInf(t, 0) {
+		return 0
+	}
+	return incBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// Return the p-th quantile (p > 0.5) of Student's t distribution with
+// df degrees of freedom.
+func tQuantile(p, df float64) float64 {
+	target := 2 * (1 - p) // Two-sided p-value at the quantile
+	lo, hi := 0.0, 1.0
+	for tPValue(hi, df) > target {
+		hi *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if tPValue(mid, df) > target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// A Comparison reports whether a quantity differs significantly
+// between two sets of runs according to Welch's t-test or the
+// Mann–Whitney U test.
+type Comparison struct {
+	Name        string  `json:"name"`        // Name of the event or other quantity
+	OldMean     float64 `json:"old_mean"`    // Mean of the first set of runs
+	NewMean     float64 `json:"new_mean"`    // Mean of the second set of runs
+	Delta       float64 `json:"delta"`       // Relative change in the mean (e.g., 0.05 for a 5% increase)
+	T           float64 `json:"t"`           // Welch's t statistic (t-test only)
+	DF          float64 `json:"df"`          // Welch–Satterthwaite degrees of freedom (t-test only)
+	U           float64 `json:"u"`           // U statistic of the second set of runs (Mann–Whitney only)
+	P           float64 `json:"p"`           // Two-sided p-value
+	Significant bool    `json:"significant"` // true if P is less than the significance level
+}
+
+// Initialize a comparison's name, means, and relative change.
+func newComparison(name string, a, b []float64) Comparison {
+	c := Comparison{Name: name, P: math.NaN()}
+	c.OldMean, _ = meanVar(a)
+	c.NewMean, _ = meanVar(b)
+	if c.OldMean != 0 {
+		c.Delta = (c.NewMean - c.OldMean) / math.Abs(c.OldMean)
+	}
+	return c
+}
+
+// Compare two data sets with Welch's t-test.
+func welch(name string, a, b []float64, alpha float64) Comparison {
+	c := newComparison(name, a, b)
+	c.T, c.DF = math.NaN(), math.NaN()
+	ma, va := meanVar(a)
+	mb, vb := meanVar(b)
+	na, nb := float64(len(a)), float64(len(b))
+	if na < 2 || nb < 2 {
+		return c
+	}
+	sa, sb := va/na, vb/nb
+	if sa+sb == 0 {
+		// Both data sets are constant.
+		if ma == mb {
+			c.T, c.P = 0, 1
+		} else {
+			c.T, c.P = math.Inf(1), 0
+		}
+		c.DF = na + nb - 2
+	} else {
+		c.T = (mb - ma) / math.Sqrt(sa+sb)
+		c.DF = (sa + sb) * (sa + sb) / (sa*sa/(na-1) + sb*sb/(nb-1))
+		c.P = tPValue(c.T, c.DF)
+	}
+	c.Significant = c.P < alpha
+	return c
+}
+
+// Compare two data sets with the Mann–Whitney U test, using the normal
+// approximation with corrections for ties and continuity.
+func mannWhitney(name string, a, b []float64, alpha float64) Comparison {
+	c := newComparison(name, a, b)
+	na, nb := float64(len(a)), float64(len(b))
+	if na == 0 || nb == 0 {
+		return c
+	}
+
+	// Rank the pooled data, assigning tied values their mean rank.
+	type obs struct {
+		x     float64
+		fromB bool
+	}
+	pooled := make([]obs, 0, len(a)+len(b))
+	for _, x := range a {
+		pooled = append(pooled, obs{x, false})
+	}
+	for _, x := range b {
+		pooled = append(pooled, obs{x, true})
+	}
+	sort.Slice(pooled, func(i, j int) bool { return pooled[i].x < pooled[j].x })
+	rankSumB, tieTerm := 0.0, 0.0
+	for i := 0; i < len(pooled); {
+		j := i + 1
+		for j < len(pooled) && pooled[j].x == pooled[i].x {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // Mean of ranks i+1 through j
+		for k := i; k < j; k++ {
+			if pooled[k].fromB {
+				rankSumB += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+	c.U = rankSumB - nb*(nb+1)/2
+
+	// Approximate U's distribution as normal.
+	n := na + nb
+	mean := na * nb / 2
+	variance := na * nb / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		c.P = 1 // Every value is the same.
+	} else {
+		z := math.Max(math.Abs(c.U-mean)-0.5, 0) / math.Sqrt(variance)
+		c.P = math.Erfc(z / math.Sqrt2)
+	}
+	c.Significant = c.P < alpha
+	return c
+}
@@ -0,0 +1,192 @@
+// This file evaluates the C constant expressions that appear in PAPI's
+// header files.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	commentRE   = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	directiveRE = regexp.MustCompile(`(?m)^\s*#.*$`)
+	tokenRE     = regexp.MustCompile(`\s*(0[xX][0-9a-fA-F]+[uUlL]*|\d+[uUlL]*|[A-Za-z_]\w*|<<|>>|\S)`)
+	intSuffixRE = regexp.MustCompile(`[uUlL]+$`)
+)
+
+// Remove all C comments from a string.
+func stripComments(s string) string {
+	return commentRE.ReplaceAllString(s, " ")
+}
+
+// Remove all preprocessor directives from a string and trim the
+// result.
+func stripDirectives(s string) string {
+	return strings.TrimSpace(directiveRE.ReplaceAllString(s, ""))
+}
+
+// C types that may appear in casts, mapped to the number of bits in
+// each.  Only signed 32-bit ints affect the values PAPI uses.
+var castBits = map[string]int{
+	"int":      32,
+	"unsigned": 0,
+	"long":     0,
+}
+
+// An exprParser is a recursive-descent parser for C constant
+// expressions.
+type exprParser struct {
+	tokens []string         // Remaining tokens
+	idents map[string]int64 // Values of known identifiers
+}
+
+// Evaluate a C constant expression in which all macros have already
+// been expanded.  Identifiers are looked up in idents.
+func evaluate(expr string, idents map[string]int64) (int64, error) {
+	p := &exprParser{idents: idents}
+	for _, m := range tokenRE.FindAllStringSubmatch(strings.TrimSpace(expr), -1) {
+		p.tokens = append(p.tokens, m[1])
+	}
+	v, err := p.binary(0)
+	if err == nil && len(p.tokens) > 0 {
+		err = fmt.Errorf("unexpected %q in %q", p.tokens[0], expr)
+	}
+	return v, err
+}
+
+// Return the next token without consuming it.
+func (p *exprParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+// Consume and return the next token.
+func (p *exprParser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+// Binary operators in increasing order of precedence
+var binaryOps = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// Parse a binary expression whose operators have at least the given
+// precedence.
+func (p *exprParser) binary(prec int) (int64, error) {
+	if prec == len(binaryOps) {
+		return p.unary()
+	}
+	lhs, err := p.binary(prec + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		found := false
+		for _, o := range binaryOps[prec] {
+			found = found || o == op
+		}
+		if !found {
+			return lhs, nil
+		}
+		p.next()
+		rhs, err := p.binary(prec + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "|":
+			lhs |= rhs
+		case "^":
+			lhs ^= rhs
+		case "&":
+			lhs &= rhs
+		case "<<":
+			lhs <<= uint(rhs)
+		case ">>":
+			lhs >>= uint(rhs)
+		case "+":
+			lhs += rhs
+		case "-":
+			lhs -= rhs
+		case "*":
+			lhs *= rhs
+		case "/", "%":
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				lhs /= rhs
+			} else {
+				lhs %= rhs
+			}
+		}
+	}
+}
+
+// Parse a unary expression, cast, parenthesized expression, number, or
+// identifier.
+func (p *exprParser) unary() (int64, error) {
+	switch t := p.next(); {
+	case t == "":
+		return 0, fmt.Errorf("unexpected end of expression")
+	case t == "-", t == "+", t == "~", t == "!":
+		v, err := p.unary()
+		switch t {
+		case "-":
+			v = -v
+		case "~":
+			v = ^v
+		case "!":
+			if v == 0 {
+				v = 1
+			} else {
+				v = 0
+			}
+		}
+		return v, err
+	case t == "(":
+		if bits, isCast := castBits[p.peek()]; isCast {
+			for p.peek() != ")" && p.peek() != "" {
+				p.next()
+			}
+			if p.next() != ")" {
+				return 0, fmt.Errorf("malformed cast")
+			}
+			v, err := p.unary()
+			if bits == 32 {
+				v = int64(int32(v))
+			}
+			return v, err
+		}
+		v, err := p.binary(0)
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ")" {
+			return 0, fmt.Errorf("missing \")\"")
+		}
+		return v, nil
+	case t[0] >= '0' && t[0] <= '9':
+		return strconv.ParseInt(intSuffixRE.ReplaceAllString(t, ""), 0, 64)
+	default:
+		if v, ok := p.idents[t]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("unknown identifier %s", t)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+// Ensure that the kinds of expressions found in PAPI's headers
+// evaluate correctly.
+func TestEvaluate(t *testing.T) {
+	idents := map[string]int64{"PAPI_TOT_INS_idx": 0x32, "PAPI_PRESET_ENUM_FP": 14}
+	for _, tc := range []struct {
+		expr string
+		want int64
+	}{
+		{"-22", -22},
+		{"0x00FF0000", 0xff0000},
+		{"(PAPI_TOT_INS_idx | ((int)0x80000000))", -1<<31 | 0x32},
+		{"(1 << PAPI_PRESET_ENUM_FP)", 1 << 14},
+		{"(((7)<<24) | ((1)<<16) | ((0)<<8) | (0))", 0x07010000},
+		{"((unsigned int)0x80000000U)", 0x80000000},
+		{"~0 & 0xff", 0xff},
+		{"2 + 3 * 4", 14},
+	} {
+		got, err := evaluate(tc.expr, idents)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.expr, got, tc.want)
+		}
+	}
+	for _, expr := range []string{"(1 << 2", "PAPI_UNKNOWN", "1 +"} {
+		if _, err := evaluate(expr, idents); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+// Ensure that enumerations are numbered as in C.
+func TestParseEnums(t *testing.T) {
+	g := generator{enums: make(map[string]int64)}
+	g.parseEnums(`
+enum {
+   PAPI_A = 0,   /**< first */
+   PAPI_B,       /**< second */
+#define PAPI_C_BIT 4
+   PAPI_D = 10,
+   PAPI_E        /* last */
+};`)
+	want := map[string]int64{"PAPI_A": 0, "PAPI_B": 1, "PAPI_D": 10, "PAPI_E": 11}
+	for name, v := range want {
+		if g.enums[name] != v {
+			t.Errorf("%s = %d; want %d", name, g.enums[name], v)
+		}
+	}
+	if len(g.enums) != len(want) {
+		t.Errorf("got %d enumerators; want %d", len(g.enums), len(want))
+	}
+}
@@ -0,0 +1,442 @@
+// genconsts generates the Go files that define PAPI's error numbers,
+// preset events, and event modifiers from papi.h and
+// papiStdEventDefs.h.  It replaces the consts2code Perl script and is
+// run via "go generate" in the go-papi directory.
+//
+// Usage:
+//
+//	go run ./internal/cmd/genconsts [-I dir]... [-D name[=value]]... [-cpp cmd] [-o dir]
+//
+// The C preprocessor is used, as the C compiler would use it, both to
+// locate the header files and to evaluate each constant's definition.
+// Short descriptions and categories of preset events, which the header
+// files lack, are taken from presets.txt.
+//
+// The generated files are named after the PAPI major version found in
+// papi.h and carry a build tag for that version so that files for
+// several PAPI versions can coexist in the package.  Files for the
+// newest supported version are built by default; those for older
+// versions are selected with -tags papi5, -tags papi6, etc.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// supportedMajors lists the PAPI major versions for which go-papi can
+// be built, oldest first.  The last is the default.
+var supportedMajors = []int{5, 6, 7}
+
+// presetsTable provides the short description and categories of each
+// preset event, which the header files lack.
+//
+//go:embed presets.txt
+var presetsTable string
+
+// A stringList is a repeatable command-line option.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// A constant is a single C constant to be converted to Go.
+type constant struct {
+	Name    string // Name without the "PAPI_" prefix
+	Value   int64  // Value of the constant
+	Comment string // Description from the header file
+}
+
+// Literal returns the constant's value as Go source code.  Preset
+// events, which set the sign bit of a 32-bit int, are written in a
+// form that keeps their index readable.
+func (c constant) Literal() string {
+	const signBit = -1 << 31
+	if c.Value >= signBit && c.Value < signBit+0x10000 {
+		return fmt.Sprintf("-1<<31 | 0x%02x", c.Value-signBit)
+	}
+	if c.Value >= 0x10000 {
+		return fmt.Sprintf("0x%08x", c.Value)
+	}
+	return fmt.Sprint(c.Value)
+}
+
+// A generator holds the state needed to generate all of the Go files.
+type generator struct {
+	cpp      string            // C preprocessor command
+	cppFlags []string          // -I and -D options to pass to cpp
+	headers  map[string]string // Full path to each header file
+	enums    map[string]int64  // Values of all enumerated constants
+	major    int               // PAPI major version
+	version  string            // Full PAPI version
+}
+
+// Run the C preprocessor on a snippet of code and return its output.
+func (g *generator) preprocess(args []string, code string) []byte {
+	cmd := exec.Command(g.cpp, append(append([]string(nil), g.cppFlags...), args...)...)
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("%s: %v", g.cpp, err)
+	}
+	return out
+}
+
+// Locate papi.h and the headers it includes.
+func (g *generator) findHeaders() {
+	out := g.preprocess([]string{"-"}, "#include <papi.h>\n")
+	lineMarker := regexp.MustCompile(`^# \d+ "([^"]+)"`)
+	g.headers = make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := lineMarker.FindStringSubmatch(line); m != nil {
+			base := filepath.Base(m[1])
+			if _, seen := g.headers[base]; !seen {
+				g.headers[base] = m[1]
+			}
+		}
+	}
+	for _, h := range []string{"papi.h", "papiStdEventDefs.h"} {
+		if g.headers[h] == "" {
+			log.Fatalf("%s failed to find %s", g.cpp, h)
+		}
+	}
+}
+
+// Read a header file.
+func (g *generator) readHeader(name string) string {
+	text, err := os.ReadFile(g.headers[name])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(text)
+}
+
+// Parse all enumerations in a header file and record their values.
+func (g *generator) parseEnums(text string) {
+	enumRE := regexp.MustCompile(`(?s)\benum\s*\w*\s*\{(.*?)\}`)
+	for _, m := range enumRE.FindAllStringSubmatch(stripComments(text), -1) {
+		var next int64
+		for _, item := range strings.Split(m[1], ",") {
+			item = stripDirectives(item)
+			if item == "" {
+				continue
+			}
+			name, expr, hasValue := strings.Cut(item, "=")
+			name = strings.TrimSpace(name)
+			if hasValue {
+				v, err := evaluate(expr, g.enums)
+				if err != nil {
+					log.Fatalf("enum %s: %v", name, err)
+				}
+				next = v
+			}
+			g.enums[name] = next
+			next++
+		}
+	}
+}
+
+// Return the names of all macros that remain defined after the given
+// header file has been preprocessed.
+func (g *generator) definedMacros(header string) map[string]bool {
+	out := g.preprocess([]string{"-dM", "-"}, fmt.Sprintf("#include <%s>\n", header))
+	defineRE := regexp.MustCompile(`^#define\s+(\w+)`)
+	defined := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := defineRE.FindStringSubmatch(line); m != nil {
+			defined[m[1]] = true
+		}
+	}
+	return defined
+}
+
+// Find all commented PAPI_* definitions in a header file that match
+// every one of the given regular expressions.  If ifdef is true,
+// discard definitions that the preprocessor would discard.  Return
+// the definitions sorted by name.
+func (g *generator) findConstants(header string, ifdef bool, keep ...string) []constant {
+	var keepREs []*regexp.Regexp
+	for _, k := range keep {
+		keepREs = append(keepREs, regexp.MustCompile(k))
+	}
+	defRE := regexp.MustCompile(`^(\S*)\s+PAPI_([_A-Z0-9]+)\b.*/\*(?:\*<)?\s*(.*?)\s*\*/`)
+	comments := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(g.readHeader(header)))
+LINES:
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, re := range keepREs {
+			if !re.MatchString(line) {
+				continue LINES
+			}
+		}
+		if m := defRE.FindStringSubmatch(line); m != nil {
+			comments[m[2]] = m[3]
+		}
+	}
+	if ifdef {
+		defined := g.definedMacros(header)
+		for name := range comments {
+			if !defined["PAPI_"+name] {
+				delete(comments, name)
+			}
+		}
+	}
+
+	// Let the preprocessor expand each constant, then evaluate the
+	// result.
+	names := make([]string, 0, len(comments))
+	for name := range comments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := g.expand(names)
+	consts := make([]constant, len(names))
+	for i, name := range names {
+		consts[i] = constant{Name: name, Value: values[name], Comment: comments[name]}
+	}
+	return consts
+}
+
+// Use the preprocessor to expand PAPI_<name> for each of a list of
+// names and return the values of the resulting expressions.
+func (g *generator) expand(names []string) map[string]int64 {
+	var code bytes.Buffer
+	code.WriteString("#include <papi.h>\n")
+	for _, name := range names {
+		fmt.Fprintf(&code, "GENCONSTS_%s PAPI_%s\n", name, name)
+	}
+	out := g.preprocess([]string{"-"}, code.String())
+	values := make(map[string]int64, len(names))
+	for _, line := range strings.Split(string(out), "\n") {
+		name, expr, ok := strings.Cut(line, " ")
+		if !ok || !strings.HasPrefix(name, "GENCONSTS_") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "GENCONSTS_")
+		v, err := evaluate(expr, g.enums)
+		if err != nil {
+			log.Fatalf("PAPI_%s: %v", name, err)
+		}
+		values[name] = v
+	}
+	for _, name := range names {
+		if _, ok := values[name]; !ok {
+			log.Fatalf("failed to expand PAPI_%s", name)
+		}
+	}
+	return values
+}
+
+// Determine the version of PAPI described by papi.h.
+func (g *generator) findVersion() {
+	v := g.expand([]string{"VERSION"})["VERSION"]
+	g.major = int(v>>24) & 0xff
+	g.version = fmt.Sprintf("%d.%d.%d.%d", v>>24&0xff, v>>16&0xff, v>>8&0xff, v&0xff)
+	for _, m := range supportedMajors {
+		if m == g.major {
+			return
+		}
+	}
+	log.Fatalf("papi.h is from PAPI %s, but go-papi supports only PAPI %v", g.version, supportedMajors)
+}
+
+// Return the build constraint that selects the files for the current
+// PAPI major version.
+func (g *generator) buildConstraint() string {
+	latest := supportedMajors[len(supportedMajors)-1]
+	if g.major != latest {
+		return fmt.Sprintf("papi%d", g.major)
+	}
+	var tags []string
+	for _, m := range supportedMajors[:len(supportedMajors)-1] {
+		tags = append(tags, fmt.Sprintf("!papi%d", m))
+	}
+	return strings.Join(tags, " && ")
+}
+
+// Format and write a generated Go file.
+func (g *generator) writeFile(dir, kind, header, body string) {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by genconsts from %s (PAPI %s); DO NOT EDIT.\n\n", header, g.version)
+	fmt.Fprintf(&src, "//go:build %s\n\n", g.buildConstraint())
+	src.WriteString("package papi\n\n")
+	src.WriteString(body)
+	code, err := format.Source(src.Bytes())
+	if err != nil {
+		log.Fatalf("%s: %v\n%s", kind, err, src.Bytes())
+	}
+	fname := filepath.Join(dir, fmt.Sprintf("papi-%s-v%d.go", kind, g.major))
+	if err = os.WriteFile(fname, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Write a block of constants or variables.
+func writeBlock(b *bytes.Buffer, comment, keyword string, consts []constant, format string) {
+	fmt.Fprintf(b, "// %s\n%s (\n", comment, keyword)
+	for _, c := range consts {
+		fmt.Fprintf(b, "\t"+format+" // %s\n", c.Name, c.Literal(), c.Comment)
+	}
+	b.WriteString(")\n")
+}
+
+// Generate papi-errno-v*.go.
+func (g *generator) genErrno(dir string) {
+	consts := g.findConstants("papi.h", true, `#define`, `PAPI_E.*-\d`)
+	var b bytes.Buffer
+	writeBlock(&b, "The following constants can be returned as Errno values from PAPI functions.",
+		"var", consts, "%s error = Errno(%s)")
+	g.writeFile(dir, "errno", "papi.h", b.String())
+}
+
+// Generate papi-event-v*.go.
+func (g *generator) genEvent(dir string) {
+	var consts []constant
+	for _, c := range g.findConstants("papiStdEventDefs.h", true, `#define`, `_idx`) {
+		if c.Name != "END" {
+			consts = append(consts, c)
+		}
+	}
+	var b bytes.Buffer
+	writeBlock(&b, "The following constants represent PAPI's standard event types.",
+		"const", consts, "%s Event = %s")
+	g.writeFile(dir, "event", "papiStdEventDefs.h", b.String())
+}
+
+// Generate papi-emod-v*.go.
+func (g *generator) genEmod(dir string) {
+	consts := g.findConstants("papi.h", false, `PAPI_(NTV|PRESET_BIT|ENUM|PRESET_ENUM_AVAIL)`)
+	var b bytes.Buffer
+	writeBlock(&b, "An EventModifier filters the set of events returned by EnumEvents().",
+		"const", consts, "%s EventModifier = %s")
+	b.WriteString("\n// Map each of the above to a string.\n")
+	b.WriteString("var presetBitToString = map[EventModifier]string{\n")
+	for _, c := range consts {
+		if strings.HasPrefix(c.Name, "PRESET_BIT_") {
+			fmt.Fprintf(&b, "\t%s: \"PAPI_%s\",\n", c.Name, c.Name)
+		}
+	}
+	b.WriteString("}\n")
+	g.writeFile(dir, "emod", "papi.h", b.String())
+}
+
+// Generate papi-preset-v*.go, which describes each preset event.
+func (g *generator) genPreset(dir string) {
+	// Parse the table of short descriptions and categories.
+	type extraInfo struct{ categories, short string }
+	extras := make(map[string]extraInfo)
+	for _, line := range strings.Split(presetsTable, "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			log.Fatalf("presets.txt: malformed line %q", line)
+		}
+		extras[fields[0]] = extraInfo{fields[1], fields[2]}
+	}
+
+	// Output one entry per preset, indexed by the low bits of its
+	// event code.
+	const signBit = -1 << 31
+	consts := g.findConstants("papiStdEventDefs.h", true, `#define`, `_idx`)
+	sort.Slice(consts, func(i, j int) bool { return consts[i].Value < consts[j].Value })
+	var b bytes.Buffer
+	b.WriteString("// presets describes each preset event, indexed by the low bits of\n")
+	b.WriteString("// its event code.\n")
+	b.WriteString("var presets = [...]presetInfo{\n")
+	for _, c := range consts {
+		if c.Name == "END" {
+			continue
+		}
+		extra, ok := extras[c.Name]
+		if !ok {
+			log.Fatalf("PAPI_%s is missing from presets.txt", c.Name)
+		}
+		var cats []string
+		for _, cat := range strings.Split(extra.categories, "|") {
+			cats = append(cats, "PRESET_BIT_"+cat)
+		}
+		fmt.Fprintf(&b, "\t0x%02x: {%q, %q, %q, %s},\n",
+			c.Value-signBit, "PAPI_"+c.Name, extra.short, c.Comment, strings.Join(cats, " | "))
+	}
+	b.WriteString("}\n")
+	g.writeFile(dir, "preset", "papiStdEventDefs.h", b.String())
+}
+
+// Generate papi-version-v*.go, which refuses to build against a
+// papi.h from a different major version.
+func (g *generator) genVersion(dir string) {
+	var others []string
+	for _, m := range supportedMajors {
+		if m != g.major {
+			others = append(others, fmt.Sprintf("papi%d", m))
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `/*
+#include <papi.h>
+
+#if PAPI_VERSION_MAJOR(PAPI_VERSION) != %d
+#error "go-papi was built for PAPI %d; build with -tags %s to match the installed papi.h"
+#endif
+*/
+import "C"
+
+// HeaderVersion is the version of papi.h from which the package's
+// error numbers, events, and event modifiers were generated.
+const HeaderVersion = %q
+`, g.major, g.major, strings.Join(others, " or "), g.version)
+	g.writeFile(dir, "version", "papi.h", b.String())
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("genconsts: ")
+	var g generator
+	var incDirs, defines stringList
+	flag.Var(&incDirs, "I", "search `dir` for header files (default: $PAPI_INCDIR)")
+	flag.Var(&defines, "D", "define a preprocessor macro (`name[=value]`)")
+	flag.StringVar(&g.cpp, "cpp", "cpp", "C preprocessor `command`")
+	outDir := flag.String("o", ".", "output `directory`")
+	flag.Parse()
+	if len(incDirs) == 0 && os.Getenv("PAPI_INCDIR") != "" {
+		incDirs = append(incDirs, os.Getenv("PAPI_INCDIR"))
+	}
+	for _, dir := range incDirs {
+		g.cppFlags = append(g.cppFlags, "-I"+dir)
+	}
+	for _, def := range defines {
+		g.cppFlags = append(g.cppFlags, "-D"+def)
+	}
+
+	g.findHeaders()
+	g.enums = make(map[string]int64)
+	g.parseEnums(g.readHeader("papiStdEventDefs.h"))
+	g.parseEnums(g.readHeader("papi.h"))
+	g.findVersion()
+	g.genErrno(*outDir)
+	g.genEvent(*outDir)
+	g.genEmod(*outDir)
+	g.genPreset(*outDir)
+	g.genVersion(*outDir)
+}
@@ -0,0 +1,48 @@
+// Package cmdutil provides the helpers shared by the commands under
+// cmd: a comma-separated command-line option and the formatting of
+// event codes and descriptions.
+package cmdutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lanl/go-papi"
+)
+
+// A List is a command-line option that takes a comma-separated list,
+// typically of event names.  It implements flag.Value.
+type List []string
+
+// Return the list's elements as a comma-separated list.
+func (l *List) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Parse a comma-separated list, replacing any previous value.
+func (l *List) Set(s string) error {
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+// Format an event code in hexadecimal.
+func HexCode(ev papi.Event) string {
+	return fmt.Sprintf("0x%08x", uint32(ev))
+}
+
+// Return an event's name and description, preferring the long
+// description to the short one.  An event PAPI cannot describe is
+// named by its String() method and has an empty description.
+func Describe(ev papi.Event) (name, desc string) {
+	info, err := papi.GetEventInfo(ev)
+	if err != nil {
+		return ev.String(), ""
+	}
+	if info.Symbol == "" {
+		info.Symbol = ev.String()
+	}
+	if info.LongDescr == "" {
+		info.LongDescr = info.ShortDescr
+	}
+	return info.Symbol, info.LongDescr
+}
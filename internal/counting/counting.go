@@ -0,0 +1,373 @@
+// Package counting maintains the long-lived event sets behind the prom
+// and otel subpackages and the papi-stat command.  A Counters counts a
+// fixed list of events, using one event set per component, on each of
+// a number of CPUs, in each of a number of processes, and in each of
+// a number of named groups of threads, and reads them all on demand
+// without stopping or resetting them.
+package counting
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lanl/go-papi"
+)
+
+// A part is the subset of the events provided by a single component.
+// Each part requires its own event set.
+type part struct {
+	cidx int   // Component index
+	idxs []int // Index into Counters.Events of each event in the part
+}
+
+// A Counters counts a list of events on CPUs, in processes, and in
+// thread groups.
+type Counters struct {
+	Events     []papi.Event // Every event counted, without duplicates
+	Components []string     // Name of the component that provides each event
+
+	parts  []part            // Partition of Events by component
+	mu     sync.Mutex        // Protects the fields below and every event set
+	cpus   []*counter        // Counters attached to CPUs
+	procs  []*counter        // Counters attached to processes
+	groups map[string]*Group // Thread groups by name
+	order  []string          // Thread-group names in order of creation
+}
+
+// A Sample holds the cumulative counts of a single CPU, process, or
+// thread group.
+type Sample struct {
+	CPU    int     // CPU counted, or -1 for a process or thread group
+	PID    int     // Process counted, or 0 for a CPU or thread group
+	Group  string  // Name of the thread group counted, or "" for a CPU or process
+	Values []int64 // Count of each event, in the order of Counters.Events
+	Err    error   // Error encountered reading the counts, in which case Values is nil
+}
+
+// Prepare to count a list of events, ignoring duplicates.  New()
+// determines the component that provides each event but does not
+// count anything until AttachCPUs() or AttachProcess() is called or a
+// thread joins a Group.
+func New(events []papi.Event) (*Counters, error) {
+	c := &Counters{groups: make(map[string]*Group)}
+	seen := make(map[papi.Event]bool)
+	for _, ev := range events {
+		if seen[ev] {
+			continue
+		}
+		seen[ev] = true
+		cidx, err := papi.GetEventComponent(ev)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ev, err)
+		}
+		p := -1
+		for j := range c.parts {
+			if c.parts[j].cidx == cidx {
+				p = j
+				break
+			}
+		}
+		if p < 0 {
+			c.parts = append(c.parts, part{cidx: cidx})
+			p = len(c.parts) - 1
+		}
+		info, err := papi.GetComponentInfo(cidx)
+		if err != nil {
+			return nil, fmt.Errorf("component %d: %w", cidx, err)
+		}
+		c.parts[p].idxs = append(c.parts[p].idxs, len(c.Events))
+		c.Events = append(c.Events, ev)
+		c.Components = append(c.Components, info.Name)
+	}
+	return c, nil
+}
+
+// Start counting every event on each of the given CPUs, including
+// every thread of every process that runs there.
+func (c *Counters) AttachCPUs(cpus ...int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cpu := range cpus {
+		k, err := c.newCounter(cpu, 0)
+		if err != nil {
+			return fmt.Errorf("CPU %d: %w", cpu, err)
+		}
+		c.cpus = append(c.cpus, k)
+	}
+	return nil
+}
+
+// Start counting every event in a process, including every thread and
+// child process that it subsequently creates.  The process should not
+// yet be running (e.g., it should be stopped under ptrace), lest its
+// existing threads go uncounted.
+func (c *Counters) AttachProcess(pid int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, err := c.newCounter(-1, pid)
+	if err != nil {
+		return fmt.Errorf("process %d: %w", pid, err)
+	}
+	c.procs = append(c.procs, k)
+	return nil
+}
+
+// Stop counting on every CPU and in every process and return the final
+// counts, in the same order as Read().  Close() does not affect thread
+// groups, whose threads must leave their groups themselves.
+func (c *Counters) Close() ([]Sample, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	samples := make([]Sample, 0, len(c.cpus)+len(c.procs))
+	for _, k := range c.attached() {
+		values, err := k.stop(len(c.Events))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		samples = append(samples, Sample{CPU: k.cpu, PID: k.pid, Values: values, Err: err})
+	}
+	c.cpus = nil
+	c.procs = nil
+	return samples, firstErr
+}
+
+// Read the cumulative counts of every CPU, in the order in which the
+// CPUs were attached, followed by those of every process, in the
+// order in which the processes were attached, followed by those of
+// every thread group, in the order in which the groups were created.
+func (c *Counters) Read() []Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	samples := make([]Sample, 0, len(c.cpus)+len(c.procs)+len(c.order))
+	for _, k := range c.attached() {
+		values, err := k.read(len(c.Events))
+		samples = append(samples, Sample{CPU: k.cpu, PID: k.pid, Values: values, Err: err})
+	}
+	for _, name := range c.order {
+		values, err := c.groups[name].read()
+		samples = append(samples, Sample{CPU: -1, Group: name, Values: values, Err: err})
+	}
+	return samples
+}
+
+// Return every counter attached to a CPU or process.  The caller must
+// hold the lock.
+func (c *Counters) attached() []*counter {
+	return append(append([]*counter(nil), c.cpus...), c.procs...)
+}
+
+// Convert an event or metric name to a form suitable as part of a
+// metric name by lowercasing it, dropping any "PAPI_" prefix, and
+// replacing each run of characters other than letters and digits with
+// an underscore.
+func Sanitize(name string) string {
+	name = strings.TrimPrefix(name, "PAPI_")
+	var sb strings.Builder
+	under := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			under = false
+		} else if !under {
+			sb.WriteByte('_')
+			under = true
+		}
+	}
+	return strings.Trim(sb.String(), "_")
+}
+
+// Return an event's description, preferring the long description, for
+// use as the help text of an exported metric.
+func Describe(ev papi.Event) string {
+	info, err := papi.GetEventInfo(ev)
+	switch {
+	case err != nil:
+		return ev.String()
+	case info.LongDescr != "":
+		return info.LongDescr
+	case info.ShortDescr != "":
+		return info.ShortDescr
+	default:
+		return ev.String()
+	}
+}
+
+// ----------------------------------------------------------------------
+
+// A Group is a named set of threads whose counts are summed.
+type Group struct {
+	c       *Counters
+	name    string
+	retired []int64               // Final counts of threads that have left the group
+	threads map[*counter]struct{} // Counters of threads currently in the group
+}
+
+// Return the thread group with a given name, creating it if
+// necessary.  A group is sampled once it has been created, even if no
+// thread has joined it yet.
+func (c *Counters) Group(name string) *Group {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if g, ok := c.groups[name]; ok {
+		return g
+	}
+	g := &Group{
+		c:       c,
+		name:    name,
+		retired: make([]int64, len(c.Events)),
+		threads: make(map[*counter]struct{}),
+	}
+	c.groups[name] = g
+	c.order = append(c.order, name)
+	return g
+}
+
+// Start counting every event in the calling thread and add the counts
+// to the group's.  The caller must be locked to its OS thread until it
+// calls the returned function, which stops counting and must be
+// called from the same thread.
+func (g *Group) Join() (leave func() error, err error) {
+	c := g.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k, err := c.newCounter(-1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("group %s: %w", g.name, err)
+	}
+	g.threads[k] = struct{}{}
+	leave = func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ok := g.threads[k]; !ok {
+			return nil
+		}
+		delete(g.threads, k)
+		values, err := k.stop(len(c.Events))
+		for i, v := range values {
+			g.retired[i] += v
+		}
+		return err
+	}
+	return leave, nil
+}
+
+// Return the group's counts, summed over current and past threads.
+// The caller must hold the lock.
+func (g *Group) read() ([]int64, error) {
+	values := append([]int64(nil), g.retired...)
+	for k := range g.threads {
+		vs, err := k.read(len(values))
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vs {
+			values[i] += v
+		}
+	}
+	return values, nil
+}
+
+// ----------------------------------------------------------------------
+
+// A counter counts every event, using one event set per component, on
+// a CPU, in a process, or in the thread that created it.
+type counter struct {
+	parts []part          // Partition of the events by component
+	sets  []papi.EventSet // Event set corresponding to each part
+	cpu   int             // CPU being counted, or -1 for a process or thread
+	pid   int             // Process being counted, or 0 for a CPU or thread
+}
+
+// Create and start a counter for a given CPU or, if cpu is negative,
+// for a given process and its descendants or, if pid is also zero, for
+// the calling thread.  The caller must hold the lock.
+func (c *Counters) newCounter(cpu, pid int) (*counter, error) {
+	k := &counter{parts: c.parts, cpu: cpu, pid: pid}
+	for _, p := range c.parts {
+		es, err := papi.CreateEventSet()
+		if err != nil {
+			k.destroy()
+			return nil, err
+		}
+		k.sets = append(k.sets, es)
+		switch {
+		case cpu >= 0:
+			if err = es.AssignComponent(p.cidx); err == nil {
+				err = es.AttachCPU(cpu)
+			}
+		case pid > 0:
+			if err = es.AssignComponent(p.cidx); err == nil {
+				err = es.Attach(pid)
+			}
+			if err == nil {
+				err = es.SetInherit()
+			}
+		}
+		if err == nil {
+			evs := make([]papi.Event, len(p.idxs))
+			for j, i := range p.idxs {
+				evs[j] = c.Events[i]
+			}
+			err = es.AddEvents(evs)
+		}
+		if err != nil {
+			k.destroy()
+			return nil, err
+		}
+	}
+	for i, es := range k.sets {
+		if err := es.Start(); err != nil {
+			for _, started := range k.sets[:i] {
+				started.Stop(make([]int64, len(c.Events)))
+			}
+			k.destroy()
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Read the current counts without stopping or resetting the counter.
+func (k *counter) read(n int) ([]int64, error) {
+	values := make([]int64, n)
+	for j, es := range k.sets {
+		idxs := k.parts[j].idxs
+		vs := make([]int64, len(idxs))
+		if err := es.Read(vs); err != nil {
+			return nil, err
+		}
+		for m, i := range idxs {
+			values[i] = vs[m]
+		}
+	}
+	return values, nil
+}
+
+// Stop counting, release the event sets, and return the final counts.
+func (k *counter) stop(n int) ([]int64, error) {
+	values := make([]int64, n)
+	var firstErr error
+	for j, es := range k.sets {
+		idxs := k.parts[j].idxs
+		vs := make([]int64, len(idxs))
+		if err := es.Stop(vs); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for m, i := range idxs {
+			values[i] = vs[m]
+		}
+	}
+	k.destroy()
+	return values, firstErr
+}
+
+// Release the counter's event sets.
+func (k *counter) destroy() {
+	for i := range k.sets {
+		k.sets[i].CleanupEventSet()
+		k.sets[i].DestroyEventSet()
+	}
+	k.sets = nil
+}
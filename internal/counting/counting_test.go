@@ -0,0 +1,72 @@
+// This file tests the shared counting machinery.
+
+package counting
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/lanl/go-papi"
+)
+
+// Ensure that event names are converted to valid metric names.
+func TestSanitize(t *testing.T) {
+	for in, want := range map[string]string{
+		"PAPI_TOT_INS":          "tot_ins",
+		"perf::CYCLES":          "perf_cycles",
+		"rapl:::PACKAGE_ENERGY": "rapl_package_energy",
+		"IPC":                   "ipc",
+	} {
+		if got := Sanitize(in); got != want {
+			t.Fatalf("Sanitize(%q): expected %q but saw %q", in, want, got)
+		}
+	}
+}
+
+// Ensure that duplicate events are counted once and that each sample
+// has one value per event.
+func TestNew(t *testing.T) {
+	c, err := New([]papi.Event{papi.TOT_INS, papi.TOT_CYC, papi.TOT_INS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Events) != 2 || len(c.Components) != 2 {
+		t.Fatalf("Expected two events but saw %v from %v", c.Events, c.Components)
+	}
+	c.Group("empty")
+	smps := c.Read()
+	if len(smps) != 1 || smps[0].Group != "empty" || smps[0].CPU != -1 || len(smps[0].Values) != 2 {
+		t.Fatalf("Unexpected samples %+v", smps)
+	}
+}
+
+// Ensure that a child process can be counted and that its final counts
+// are returned when counting stops.
+func TestAttachProcess(t *testing.T) {
+	c, err := New([]papi.Event{papi.TOT_INS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sleep", "0.1")
+	if err = cmd.Start(); err != nil {
+		t.Skipf("Unable to start a child process: %v", err)
+	}
+	defer cmd.Wait()
+	if err = c.AttachProcess(cmd.Process.Pid); err != nil {
+		t.Skipf("Unable to count process %d: %v", cmd.Process.Pid, err)
+	}
+	if smps := c.Read(); len(smps) != 1 || smps[0].PID != cmd.Process.Pid || smps[0].CPU != -1 {
+		t.Fatalf("Unexpected samples %+v", smps)
+	}
+	cmd.Wait()
+	smps, err := c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(smps) != 1 || len(smps[0].Values) != 1 {
+		t.Fatalf("Unexpected final samples %+v", smps)
+	}
+	if smps = c.Read(); len(smps) != 0 {
+		t.Fatalf("Expected no samples after Close() but saw %+v", smps)
+	}
+}
@@ -0,0 +1,41 @@
+// This file exposes the formula subpackage's library of standard
+// metrics as metrics over PAPI's preset events.
+
+package metrics
+
+import (
+	"sort"
+
+	"github.com/lanl/go-papi/metrics/formula"
+)
+
+// builtins lists the standard metrics, one per builtin formula.
+var builtins = func() []*Metric {
+	var ms []*Metric
+	for _, f := range formula.Builtins() {
+		ms = append(ms, MustNew(f.Name, f.Description, f.Expr))
+	}
+	return ms
+}()
+
+// Map each builtin metric's name to the metric.
+var builtinsByName = make(map[string]*Metric, len(builtins))
+
+func init() {
+	for _, m := range builtins {
+		builtinsByName[m.Name] = m
+	}
+}
+
+// Return the standard metric with a given name (e.g., "IPC") or nil if
+// there is no such metric.
+func Builtin(name string) *Metric {
+	return builtinsByName[name]
+}
+
+// Return all of the standard metrics, sorted by name.
+func Builtins() []*Metric {
+	ms := append([]*Metric(nil), builtins...)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	return ms
+}
@@ -0,0 +1,80 @@
+// This file defines a library of standard metrics over PAPI's preset
+// events.
+
+package formula
+
+import "sort"
+
+// builtins lists the standard metrics.  Each is expressed in terms of
+// preset events so that every team computes, say, a cache miss ratio
+// the same way.
+var builtins = []*Formula{
+	MustNew("IPC", "Instructions per cycle",
+		"PAPI_TOT_INS / PAPI_TOT_CYC"),
+	MustNew("CPI", "Cycles per instruction",
+		"PAPI_TOT_CYC / PAPI_TOT_INS"),
+	MustNew("BR_MSP_RATE", "Fraction of conditional branches that were mispredicted",
+		"PAPI_BR_MSP / PAPI_BR_CN"),
+	MustNew("BR_MSP_PKI", "Mispredicted conditional branches per thousand instructions",
+		"1000 * PAPI_BR_MSP / PAPI_TOT_INS"),
+	MustNew("L1_DCM_RATIO", "Fraction of level 1 data cache accesses that missed",
+		"PAPI_L1_DCM / PAPI_L1_DCA"),
+	MustNew("L1_ICM_RATIO", "Fraction of level 1 instruction cache accesses that missed",
+		"PAPI_L1_ICM / PAPI_L1_ICA"),
+	MustNew("L1_DCM_PKI", "Level 1 data cache misses per thousand instructions",
+		"1000 * PAPI_L1_DCM / PAPI_TOT_INS"),
+	MustNew("L2_DCM_RATIO", "Fraction of level 2 data cache accesses that missed",
+		"PAPI_L2_DCM / PAPI_L2_DCA"),
+	MustNew("L2_TCM_RATIO", "Fraction of level 2 cache accesses that missed",
+		"PAPI_L2_TCM / PAPI_L2_TCA"),
+	MustNew("L2_TCM_PKI", "Level 2 cache misses per thousand instructions",
+		"1000 * PAPI_L2_TCM / PAPI_TOT_INS"),
+	MustNew("L3_TCM_RATIO", "Fraction of level 3 cache accesses that missed",
+		"PAPI_L3_TCM / PAPI_L3_TCA"),
+	MustNew("L3_TCM_PKI", "Level 3 cache misses per thousand instructions",
+		"1000 * PAPI_L3_TCM / PAPI_TOT_INS"),
+	MustNew("TLB_DM_PKI", "Data TLB misses per thousand instructions",
+		"1000 * PAPI_TLB_DM / PAPI_TOT_INS"),
+	MustNew("FLOPS_PER_CYCLE", "Floating-point operations per cycle",
+		"PAPI_FP_OPS / PAPI_TOT_CYC"),
+	MustNew("FLOPS_PER_BYTE", "Floating-point operations per byte loaded or stored, assuming 8-byte operands",
+		"PAPI_FP_OPS / (8 * PAPI_LST_INS)"),
+	MustNew("FLOPS_PER_DRAM_BYTE", "Floating-point operations per byte of memory traffic, assuming each level 3 cache miss transfers a 64-byte line",
+		"PAPI_FP_OPS / (64 * PAPI_L3_TCM)"),
+	MustNew("VEC_INS_FRACTION", "Fraction of instructions that were vector/SIMD instructions",
+		"PAPI_VEC_INS / PAPI_TOT_INS"),
+	MustNew("MEM_INS_FRACTION", "Fraction of instructions that were loads or stores",
+		"PAPI_LST_INS / PAPI_TOT_INS"),
+	MustNew("BR_INS_FRACTION", "Fraction of instructions that were branches",
+		"PAPI_BR_INS / PAPI_TOT_INS"),
+	MustNew("STALL_FRACTION", "Fraction of cycles stalled on any resource",
+		"PAPI_RES_STL / PAPI_TOT_CYC"),
+	MustNew("MEM_STALL_FRACTION", "Fraction of cycles stalled waiting for memory",
+		"PAPI_MEM_SCY / PAPI_TOT_CYC"),
+	MustNew("FP_STALL_FRACTION", "Fraction of cycles in which a floating-point unit was stalled",
+		"PAPI_FP_STAL / PAPI_TOT_CYC"),
+	MustNew("ISSUE_STALL_FRACTION", "Fraction of cycles with no instruction issued",
+		"PAPI_STL_ICY / PAPI_TOT_CYC"),
+}
+
+// Map each builtin metric's name to the metric.
+var builtinsByName = make(map[string]*Formula, len(builtins))
+
+func init() {
+	for _, m := range builtins {
+		builtinsByName[m.Name] = m
+	}
+}
+
+// Return the standard metric with a given name (e.g., "IPC") or nil if
+// there is no such metric.
+func Builtin(name string) *Formula {
+	return builtinsByName[name]
+}
+
+// Return all of the standard metrics, sorted by name.
+func Builtins() []*Formula {
+	ms := append([]*Formula(nil), builtins...)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	return ms
+}
@@ -0,0 +1,227 @@
+// This file parses and evaluates the expressions that define formulas.
+
+package formula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A node is one node of a parsed expression.
+type node interface {
+	eval(counts map[string]int64) (float64, error)
+}
+
+// A constNode is a numeric constant.
+type constNode float64
+
+func (n constNode) eval(map[string]int64) (float64, error) {
+	return float64(n), nil
+}
+
+// An eventNode is a reference to an event's count by name.
+type eventNode string
+
+func (n eventNode) eval(counts map[string]int64) (float64, error) {
+	v, ok := counts[string(n)]
+	if !ok {
+		return 0, &MissingEventError{Name: string(n)}
+	}
+	return float64(v), nil
+}
+
+// A negNode negates its operand.
+type negNode struct{ x node }
+
+func (n negNode) eval(counts map[string]int64) (float64, error) {
+	v, err := n.x.eval(counts)
+	return -v, err
+}
+
+// A binaryNode applies an arithmetic operator to two operands.
+type binaryNode struct {
+	op   byte // One of '+', '-', '*', or '/'
+	x, y node
+}
+
+func (n binaryNode) eval(counts map[string]int64) (float64, error) {
+	x, err := n.x.eval(counts)
+	if err != nil {
+		return 0, err
+	}
+	y, err := n.y.eval(counts)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	default:
+		if y == 0 {
+			return 0, ErrDivideByZero
+		}
+		return x / y, nil
+	}
+}
+
+// ----------------------------------------------------------------------
+
+// A parser converts an expression from a string to a tree of nodes,
+// recording each event the expression references.
+type parser struct {
+	src   string   // Expression being parsed
+	pos   int      // Current offset into src
+	names []string // Names of the events referenced, in order of first appearance
+}
+
+// Parse an expression according to the grammar given in the package
+// documentation.  Return the root of the parse tree and the names of
+// the events the expression references.
+func parse(src string) (n node, names []string, err error) {
+	p := &parser{src: src}
+	if n, err = p.expr(); err != nil {
+		return
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return n, p.names, nil
+}
+
+// Return a parse error that indicates the current position.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("formula: %q, offset %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+// Advance past any whitespace.
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// Consume the next character if it is one of those given.
+func (p *parser) accept(chars string) (byte, bool) {
+	p.skipSpace()
+	if p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) >= 0 {
+		p.pos++
+		return p.src[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *parser) expr() (node, error) {
+	x, err := p.term()
+	for err == nil {
+		op, ok := p.accept("+-")
+		if !ok {
+			break
+		}
+		var y node
+		if y, err = p.term(); err == nil {
+			x = binaryNode{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) term() (node, error) {
+	x, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*/")
+		if !ok {
+			break
+		}
+		var y node
+		if y, err = p.unary(); err == nil {
+			x = binaryNode{op: op, x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) unary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.unary()
+		return negNode{x}, err
+	}
+	return p.primary()
+}
+
+// Return true if a character can appear in an unbraced event name.
+func isNameChar(c byte) bool {
+	return c == '_' || c == ':' || c == '.' || c >= '0' && c <= '9' ||
+		c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *parser) primary() (node, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	start := p.pos
+	switch c := p.src[p.pos]; {
+	case c == '(':
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("missing \")\"")
+		}
+		return x, nil
+
+	case c == '{':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, p.errorf("missing \"}\"")
+		}
+		p.pos += end + 1
+		return p.event(strings.TrimSpace(p.src[start+1 : p.pos-1]))
+
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || strings.IndexByte(".eE", p.src[p.pos]) >= 0 ||
+			(p.src[p.pos] == '-' || p.src[p.pos] == '+') && strings.IndexByte("eE", p.src[p.pos-1]) >= 0) {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("malformed number %q", text)
+		}
+		return constNode(v), nil
+
+	case isNameChar(c):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		return p.event(p.src[start:p.pos])
+
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+// Record a reference to an event by name.
+func (p *parser) event(name string) (node, error) {
+	if name == "" {
+		return nil, p.errorf("empty event name")
+	}
+	found := false
+	for _, n := range p.names {
+		found = found || n == name
+	}
+	if !found {
+		p.names = append(p.names, name)
+	}
+	return eventNode(name), nil
+}
@@ -0,0 +1,96 @@
+/*
+Package formula parses and evaluates the expressions that define
+derived metrics, such as instructions per cycle or cache miss ratios,
+in terms of event names alone.
+
+Unlike the metrics package, which resolves each name to a papi.Event,
+formula does not depend on PAPI, so programs that only analyze counts
+recorded elsewhere, such as papidiff, need not link libpapi.  Counts
+are therefore matched to events by name exactly as written in the
+expression.
+
+Expressions follow the usual rules of arithmetic:
+
+	expr    = term { ("+" | "-") term }
+	term    = unary { ("*" | "/") unary }
+	unary   = "-" unary | primary
+	primary = number | event | "{" event "}" | "(" expr ")"
+
+An event is a preset or native event name.  Names containing
+characters other than letters, digits, "_", ":", and "." must be
+enclosed in braces.
+*/
+package formula
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Formula is a named quantity derived from event counts.
+type Formula struct {
+	Name        string   // Name of the formula
+	Description string   // Textual description of the formula
+	Expr        string   // Expression that defines the formula
+	root        node     // Parsed form of Expr
+	names       []string // Names of the events referenced by Expr
+}
+
+// ErrDivideByZero is returned when evaluating a formula would divide by
+// zero, for example when computing a miss ratio for a cache that was
+// never accessed.
+var ErrDivideByZero = errors.New("formula: division by zero")
+
+// A MissingEventError is returned when evaluating a formula that
+// requires an event whose count was not provided.
+type MissingEventError struct {
+	Name string // Name of the event whose count is missing
+}
+
+func (e *MissingEventError) Error() string {
+	return fmt.Sprintf("formula: no count for %s", e.Name)
+}
+
+// Define a formula by parsing an expression over event names.  See the
+// package documentation for the expression syntax.
+func New(name, description, expr string) (*Formula, error) {
+	root, names, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Formula{
+		Name:        name,
+		Description: description,
+		Expr:        expr,
+		root:        root,
+		names:       names,
+	}, nil
+}
+
+// Define a formula as with New() but panic if the expression is
+// invalid.  MustNew() is intended for initializing global variables.
+func MustNew(name, description, expr string) *Formula {
+	f, err := New(name, description, expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Return the names of the events a formula requires, in order of first
+// appearance in its expression.
+func (f *Formula) Names() []string {
+	return append([]string(nil), f.names...)
+}
+
+// Compute a formula's value from a map of event names to counts.  A
+// *MissingEventError is returned if a required event has no count, and
+// ErrDivideByZero is returned if the computation divides by zero.
+func (f *Formula) Eval(counts map[string]int64) (float64, error) {
+	return f.root.eval(counts)
+}
+
+// Return a formula's name.
+func (f *Formula) String() string {
+	return f.Name
+}
@@ -0,0 +1,70 @@
+// This file tests formulas over event names.
+
+package formula
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// Ensure that expressions parse and evaluate correctly by event name.
+func TestEval(t *testing.T) {
+	counts := map[string]int64{
+		"PAPI_TOT_INS":            3000,
+		"PAPI_TOT_CYC":            2000,
+		"PAPI_L1_DCM":             10,
+		"PAPI_L1_DCA":             0,
+		"perf::CYCLES:u=0":        500,
+		"skx_unc_imc0::CAS_COUNT": 7,
+	}
+	for _, tc := range []struct {
+		expr string
+		want float64
+	}{
+		{"PAPI_TOT_INS / PAPI_TOT_CYC", 1.5},
+		{"PAPI_TOT_INS - PAPI_TOT_CYC * 2", -1000},
+		{"(PAPI_TOT_INS - PAPI_TOT_CYC) * 2", 2000},
+		{"-PAPI_TOT_CYC / 4 + 1e3", 500},
+		{"{PAPI_TOT_INS}/1000", 3},
+		{"{perf::CYCLES:u=0} + skx_unc_imc0::CAS_COUNT", 507},
+	} {
+		f, err := New("test", "", tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.Eval(counts)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.expr, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("%s: expected %g but saw %g", tc.expr, tc.want, got)
+		}
+	}
+
+	// Test error handling.
+	if _, err := Builtin("L1_DCM_RATIO").Eval(counts); err != ErrDivideByZero {
+		t.Fatalf("Expected ErrDivideByZero but saw %v", err)
+	}
+	var missing *MissingEventError
+	if _, err := Builtin("BR_MSP_RATE").Eval(counts); !errors.As(err, &missing) || missing.Name != "PAPI_BR_MSP" {
+		t.Fatalf("Expected a missing PAPI_BR_MSP but saw %v", err)
+	}
+	for _, expr := range []string{"", "PAPI_TOT_INS /", "(PAPI_TOT_INS", "{}", "{PAPI_TOT_INS", "2 $ 3"} {
+		if _, err := New("bad", "", expr); err == nil {
+			t.Fatalf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+// Ensure that formulas report the names of the events they need.
+func TestNames(t *testing.T) {
+	f := MustNew("test", "", "PAPI_L1_DCM + {PAPI_TOT_INS} / PAPI_L1_DCM")
+	names := f.Names()
+	if len(names) != 2 || names[0] != "PAPI_L1_DCM" || names[1] != "PAPI_TOT_INS" {
+		t.Fatalf("Expected [PAPI_L1_DCM PAPI_TOT_INS] but saw %v", names)
+	}
+	if len(Builtins()) == 0 || Builtin("IPC") == nil || Builtin("NO_SUCH_METRIC") != nil {
+		t.Fatal("Unexpected builtin formulas")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Package metrics computes derived metrics, such as instructions per
+cycle or cache miss ratios, from PAPI event counts.
+
+A Metric is declared as an arithmetic expression over event names,
+for example "PAPI_TOT_INS / PAPI_TOT_CYC".  The expression is parsed
+once, when the Metric is created.  Events() reports the events a set
+of metrics requires, from which an EventSet can be built, and Eval()
+computes a metric's value from the counts that EventSet produces.
+Builtin() and Builtins() provide a curated library of standard
+metrics.
+
+Expressions are parsed by the formula subpackage, whose documentation
+gives their syntax.  Each event named in an expression must be a preset
+or native event name as accepted by papi.ParseEvent().
+*/
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/metrics/formula"
+)
+
+// A Metric is a named quantity derived from event counts.
+type Metric struct {
+	Name        string           // Name of the metric
+	Description string           // Textual description of the metric
+	Expr        string           // Expression that defines the metric
+	formula     *formula.Formula // Parsed form of Expr
+	codes       []papi.Event     // Event corresponding to each of formula.Names()
+	events      []papi.Event     // Events referenced by Expr, without duplicates
+}
+
+// ErrDivideByZero is returned when evaluating a metric would divide by
+// zero, for example when computing a miss ratio for a cache that was
+// never accessed.
+var ErrDivideByZero = formula.ErrDivideByZero
+
+// A MissingEventError is returned when evaluating a metric that
+// requires an event whose count was not provided.
+type MissingEventError struct {
+	Event papi.Event // Event whose count is missing
+}
+
+func (e *MissingEventError) Error() string {
+	return fmt.Sprintf("metrics: no count for %s", e.Event)
+}
+
+// Define a metric by parsing an expression over event names.  See the
+// package documentation for the expression syntax.
+func New(name, description, expr string) (*Metric, error) {
+	f, err := formula.New(name, description, expr)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metric{
+		Name:        name,
+		Description: description,
+		Expr:        expr,
+		formula:     f,
+	}
+	for _, ename := range f.Names() {
+		ecode, err := papi.ParseEvent(ename)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: %q: unknown event %s: %v", expr, ename, err)
+		}
+		m.codes = append(m.codes, ecode)
+		found := false
+		for _, e := range m.events {
+			found = found || e == ecode
+		}
+		if !found {
+			m.events = append(m.events, ecode)
+		}
+	}
+	return m, nil
+}
+
+// Define a metric as with New() but panic if the expression is
+// invalid.  MustNew() is intended for initializing global variables.
+func MustNew(name, description, expr string) *Metric {
+	m, err := New(name, description, expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Return the events a metric requires, in order of first appearance
+// in its expression.
+func (m *Metric) Events() []papi.Event {
+	return append([]papi.Event(nil), m.events...)
+}
+
+// Compute a metric's value from a map of event counts.  A
+// *MissingEventError is returned if a required event has no count,
+// and ErrDivideByZero is returned if the computation divides by zero.
+func (m *Metric) Eval(counts map[papi.Event]int64) (float64, error) {
+	named := make(map[string]int64, len(m.codes))
+	for i, ename := range m.formula.Names() {
+		v, ok := counts[m.codes[i]]
+		if !ok {
+			return 0, &MissingEventError{Event: m.codes[i]}
+		}
+		named[ename] = v
+	}
+	return m.formula.Eval(named)
+}
+
+// Return the formula that defines a metric.  A formula refers to
+// events by name and can evaluate counts that were recorded by name,
+// such as those in a saved harness result.
+func (m *Metric) Formula() *formula.Formula {
+	return m.formula
+}
+
+// Return a metric's name.
+func (m *Metric) String() string {
+	return m.Name
+}
+
+// Return the union of the events required by a list of metrics, with
+// no duplicates, in order of first appearance.  The result is suitable
+// for passing to EventSet.AddEvents().
+func Events(ms ...*Metric) []papi.Event {
+	var events []papi.Event
+	seen := make(map[papi.Event]bool)
+	for _, m := range ms {
+		for _, e := range m.events {
+			if !seen[e] {
+				seen[e] = true
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+// Pair each event with the corresponding counter value, as returned by
+// EventSet.Read() or EventSet.Stop(), to produce a map suitable for
+// passing to Metric.Eval().
+func Counts(events []papi.Event, values []int64) map[papi.Event]int64 {
+	counts := make(map[papi.Event]int64, len(events))
+	for i, e := range events {
+		if i < len(values) {
+			counts[e] = values[i]
+		}
+	}
+	return counts
+}
+
+// Evaluate each of a list of metrics against the same counts and return
+// a map from metric name to value.  Metrics that cannot be evaluated
+// are omitted from the map; the first error encountered is returned.
+func EvalAll(counts map[papi.Event]int64, ms ...*Metric) (map[string]float64, error) {
+	var firstErr error
+	values := make(map[string]float64, len(ms))
+	for _, m := range ms {
+		v, err := m.Eval(counts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", m.Name, err)
+			}
+			continue
+		}
+		values[m.Name] = v
+	}
+	return values, firstErr
+}
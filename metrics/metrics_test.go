@@ -0,0 +1,110 @@
+// This file tests derived metrics.
+
+package metrics
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/lanl/go-papi"
+)
+
+// Ensure that expressions parse and evaluate correctly.
+func TestEval(t *testing.T) {
+	counts := map[papi.Event]int64{
+		papi.TOT_INS: 3000,
+		papi.TOT_CYC: 2000,
+		papi.L1_DCM:  10,
+		papi.L1_DCA:  0,
+	}
+	for _, tc := range []struct {
+		expr string
+		want float64
+	}{
+		{"PAPI_TOT_INS / PAPI_TOT_CYC", 1.5},
+		{"PAPI_TOT_INS - PAPI_TOT_CYC * 2", -1000},
+		{"(PAPI_TOT_INS - PAPI_TOT_CYC) * 2", 2000},
+		{"-PAPI_TOT_CYC / 4 + 1e3", 500},
+		{"{PAPI_TOT_INS}/1000", 3},
+	} {
+		m, err := New("test", "", tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.Eval(counts)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.expr, err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("%s: expected %g but saw %g", tc.expr, tc.want, got)
+		}
+	}
+
+	// Test error handling.
+	if _, err := Builtin("L1_DCM_RATIO").Eval(counts); err != ErrDivideByZero {
+		t.Fatalf("Expected ErrDivideByZero but saw %v", err)
+	}
+	var missing *MissingEventError
+	if _, err := Builtin("BR_MSP_RATE").Eval(counts); !errors.As(err, &missing) || missing.Event != papi.BR_MSP {
+		t.Fatalf("Expected a missing PAPI_BR_MSP but saw %v", err)
+	}
+	for _, expr := range []string{"", "PAPI_TOT_INS /", "(PAPI_TOT_INS", "PAPI_NO_SUCH_EVENT", "2 $ 3"} {
+		if _, err := New("bad", "", expr); err == nil {
+			t.Fatalf("Expected %q to be rejected", expr)
+		}
+	}
+}
+
+// Ensure that metrics report the events they need.
+func TestEvents(t *testing.T) {
+	ipc, cpi, l1 := Builtin("IPC"), Builtin("CPI"), Builtin("L1_DCM_PKI")
+	events := Events(ipc, cpi, l1)
+	expected := []papi.Event{papi.TOT_INS, papi.TOT_CYC, papi.L1_DCM}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v but saw %v", expected, events)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Fatalf("Expected %v but saw %v", expected, events)
+		}
+	}
+	values, err := EvalAll(Counts(events, []int64{4000, 2000, 8}), ipc, cpi, l1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["IPC"] != 2 || values["CPI"] != 0.5 || values["L1_DCM_PKI"] != 2 {
+		t.Fatalf("Unexpected metric values %v", values)
+	}
+	if len(Builtins()) == 0 || Builtin("NO_SUCH_METRIC") != nil {
+		t.Fatal("Unexpected builtin metrics")
+	}
+}
+
+// Measure a builtin metric using a real event set.
+func TestMeasure(t *testing.T) {
+	ipc := Builtin("IPC")
+	events, err := papi.CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.DestroyEventSet()
+	defer events.CleanupEventSet()
+	if err = events.AddEvents(ipc.Events()); err != nil {
+		t.Skipf("Cannot count %v: %s", ipc.Events(), err)
+	}
+	values := make([]int64, len(ipc.Events()))
+	if err = events.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sum := 0.0
+	for i := 0; i < 100000; i++ {
+		sum += float64(i)
+	}
+	if err = events.Stop(values); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ipc.Eval(Counts(ipc.Events(), values)); err != nil || v <= 0 {
+		t.Fatalf("Expected a positive IPC but saw %g (%v)", v, err)
+	}
+}
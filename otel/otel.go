@@ -0,0 +1,243 @@
+/*
+Package otel reports PAPI counters through OpenTelemetry, both as
+metrics and as attributes of trace spans.
+
+NewInstruments() registers an asynchronous counter for each of a list
+of events, named after the event (e.g., papi.tot_ins for
+PAPI_TOT_INS) and described by papi.GetEventInfo(), and an
+asynchronous gauge for each of a list of derived metrics.  As with the
+prom subpackage, the counts come from one or more CPUs or from named
+groups of threads, are read without stopping or resetting the
+counters whenever the meter collects them, and carry event,
+component, and either cpu or group attributes.
+
+Start() starts a trace span and a papi.Region of the same name.
+Span.End() ends both and attaches the region's counts to the span as
+attributes named after each event (e.g., papi.TOT_INS), together with
+each of SpanMetrics that can be computed from them (e.g., papi.IPC):
+
+	ctx, span := otel.Start(ctx, tracer, "handle-request")
+	defer span.End()
+
+The events are those counted by every region, as selected by
+papi.SetRegionEvents() or the PAPI_EVENTS environment variable.  Like
+a region, a span counts only the goroutine that started it, which is
+locked to its OS thread until the span ends.  Spans must therefore be
+ended by the goroutine that started them, innermost first.
+*/
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/counting"
+	"github.com/lanl/go-papi/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options control NewInstruments().  The zero value counts
+// papi.DefaultRegionEvents and reports no derived metrics.
+type Options struct {
+	Prefix  string            // Prefix of every instrument name (default "papi.")
+	Events  []papi.Event      // Events to count (default papi.DefaultRegionEvents)
+	Metrics []*metrics.Metric // Derived metrics to report; the events they require are counted as well
+}
+
+// Instruments report PAPI counters and derived metrics as
+// asynchronous OpenTelemetry instruments.
+type Instruments struct {
+	counters     *counting.Counters              // Event sets being read
+	instruments  []metric.Int64ObservableCounter // Counter for each event
+	metrics      []*metrics.Metric               // Derived metrics
+	gauges       []metric.Float64ObservableGauge // Gauge for each derived metric
+	registration metric.Registration             // Callback registered with the meter
+}
+
+// Create an instrument for each event and derived metric and register
+// a callback with a meter that observes them all.  Nothing is counted
+// until AttachCPUs() is called or a thread joins a Group.
+func NewInstruments(meter metric.Meter, opts *Options) (*Instruments, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Prefix == "" {
+		o.Prefix = "papi."
+	}
+	if o.Events == nil {
+		o.Events = papi.DefaultRegionEvents
+	}
+	events := append(append([]papi.Event(nil), o.Events...), metrics.Events(o.Metrics...)...)
+	counters, err := counting.New(events)
+	if err != nil {
+		return nil, fmt.Errorf("otel: %w", err)
+	}
+	in := &Instruments{counters: counters, metrics: o.Metrics}
+	var observables []metric.Observable
+	for _, ev := range counters.Events {
+		inst, err := meter.Int64ObservableCounter(o.Prefix+counting.Sanitize(ev.String()),
+			metric.WithDescription(counting.Describe(ev)), metric.WithUnit("{event}"))
+		if err != nil {
+			return nil, fmt.Errorf("otel: %s: %w", ev, err)
+		}
+		in.instruments = append(in.instruments, inst)
+		observables = append(observables, inst)
+	}
+	for _, m := range o.Metrics {
+		desc := m.Description
+		if desc == "" {
+			desc = m.Expr
+		}
+		gauge, err := meter.Float64ObservableGauge(o.Prefix+counting.Sanitize(m.Name),
+			metric.WithDescription(desc), metric.WithUnit("1"))
+		if err != nil {
+			return nil, fmt.Errorf("otel: %s: %w", m.Name, err)
+		}
+		in.gauges = append(in.gauges, gauge)
+		observables = append(observables, gauge)
+	}
+	if in.registration, err = meter.RegisterCallback(in.observe, observables...); err != nil {
+		return nil, fmt.Errorf("otel: %w", err)
+	}
+	return in, nil
+}
+
+// Observe every CPU's and every thread group's counts and the derived
+// metrics computed from them.
+func (in *Instruments) observe(_ context.Context, obs metric.Observer) error {
+	var errs []error
+	events := in.counters.Events
+	for _, smp := range in.counters.Read() {
+		if smp.Err != nil {
+			errs = append(errs, smp.Err)
+			continue
+		}
+		var where attribute.KeyValue
+		if smp.CPU >= 0 {
+			where = attribute.Int("cpu", smp.CPU)
+		} else {
+			where = attribute.String("group", smp.Group)
+		}
+		for i, ev := range events {
+			obs.ObserveInt64(in.instruments[i], smp.Values[i], metric.WithAttributes(
+				attribute.String("event", ev.String()),
+				attribute.String("component", in.counters.Components[i]),
+				where))
+		}
+		counts := metrics.Counts(events, smp.Values)
+		for i, m := range in.metrics {
+			if v, err := m.Eval(counts); err == nil {
+				obs.ObserveFloat64(in.gauges[i], v, metric.WithAttributes(where))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("otel: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Start counting every event on each of the given CPUs, including
+// every thread of every process that runs there.  This typically
+// requires elevated privileges.
+func (in *Instruments) AttachCPUs(cpus ...int) error {
+	if err := in.counters.AttachCPUs(cpus...); err != nil {
+		return fmt.Errorf("otel: %w", err)
+	}
+	return nil
+}
+
+// Unregister the instruments' callback and stop counting on every CPU.
+// Close() does not affect thread groups, whose threads must leave
+// their groups themselves.
+func (in *Instruments) Close() error {
+	err := in.registration.Unregister()
+	if _, cerr := in.counters.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("otel: %w", err)
+	}
+	return nil
+}
+
+// A Group is a named set of threads whose counts are summed.
+type Group struct {
+	g *counting.Group
+}
+
+// Return the thread group with a given name, creating it if
+// necessary.  A group is observed once it has been created, even if
+// no thread has joined it yet.
+func (in *Instruments) Group(name string) *Group {
+	return &Group{in.counters.Group(name)}
+}
+
+// Start counting every event in the calling thread and add the counts
+// to the group's.  The caller must be locked to its OS thread (see
+// runtime.LockOSThread()) until it calls the returned function, which
+// stops counting and must be called from the same thread.
+func (g *Group) Join() (leave func() error, err error) {
+	leave, err = g.g.Join()
+	if err != nil {
+		return nil, fmt.Errorf("otel: %w", err)
+	}
+	return leave, nil
+}
+
+// ----------------------------------------------------------------------
+
+// SpanMetrics lists the derived metrics that Span.End() attaches to
+// each span, when the events they require are counted.
+var SpanMetrics = []*metrics.Metric{metrics.Builtin("IPC")}
+
+// A Span is a trace span that counts events for its duration.
+type Span struct {
+	trace.Span
+	region *papi.Region // Region measuring the span
+}
+
+// Return the attribute name under which a span reports an event or
+// metric.
+func spanAttribute(name string) string {
+	return "papi." + strings.TrimPrefix(name, "PAPI_")
+}
+
+// Start a span with a tracer and begin counting events in the calling
+// goroutine, which remains locked to its OS thread until the span
+// ends.  The span's counts are also aggregated, by span name, into
+// papi.RegionReport().
+func Start(ctx context.Context, tracer trace.Tracer, name string, opts ...trace.SpanStartOption) (context.Context, *Span) {
+	ctx, span := tracer.Start(ctx, name, opts...)
+	return ctx, &Span{Span: span, region: papi.Begin(name)}
+}
+
+// Stop counting, attach the counts and derived metrics to the span as
+// attributes, and end the span.  End() must be called by the goroutine
+// that started the span, after any spans started within it have
+// ended.  Errors are attached to the span as a papi.error attribute.
+func (s *Span) End(options ...trace.SpanEndOption) {
+	if err := s.region.End(); err != nil {
+		s.SetAttributes(attribute.String("papi.error", err.Error()))
+	} else {
+		events, counts := s.region.Counts()
+		attrs := make([]attribute.KeyValue, 0, len(events)+len(SpanMetrics))
+		for i, ev := range events {
+			attrs = append(attrs, attribute.Int64(spanAttribute(ev.String()), counts[i]))
+		}
+		byEvent := metrics.Counts(events, counts)
+		for _, m := range SpanMetrics {
+			if v, err := m.Eval(byEvent); err == nil {
+				attrs = append(attrs, attribute.Float64(spanAttribute(m.Name), v))
+			}
+		}
+		s.SetAttributes(attrs...)
+	}
+	s.Span.End(options...)
+}
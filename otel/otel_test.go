@@ -0,0 +1,110 @@
+// This file tests the OpenTelemetry integration.
+
+package otel
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Do a bit of work to give the counters something to count.
+func performWork() float64 {
+	x := 1.0
+	for i := 0; i < 1000000; i++ {
+		x = x*1.0000001 + 1e-9
+	}
+	return x
+}
+
+// Ensure that a thread group's counts are observed by a meter.
+func TestInstruments(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	in, err := NewInstruments(provider.Meter("test"), &Options{
+		Events:  []papi.Event{papi.TOT_INS},
+		Metrics: []*metrics.Metric{metrics.Builtin("IPC")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	leave, err := in.Group("test").Join()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leave()
+	performWork()
+
+	var rm metricdata.ResourceMetrics
+	if err = reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			seen[m.Name] = true
+			if m.Name != "papi.tot_ins" {
+				continue
+			}
+			if m.Description == "" {
+				t.Fatal("papi.tot_ins has no description")
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || !sum.IsMonotonic || len(sum.DataPoints) != 1 {
+				t.Fatalf("Unexpected data for papi.tot_ins: %+v", m.Data)
+			}
+			dp := sum.DataPoints[0]
+			if g, _ := dp.Attributes.Value("group"); g.AsString() != "test" || dp.Value <= 0 {
+				t.Fatalf("Unexpected data point %+v", dp)
+			}
+		}
+	}
+	for _, name := range []string{"papi.tot_ins", "papi.tot_cyc", "papi.ipc"} {
+		if !seen[name] {
+			t.Fatalf("%s was not observed", name)
+		}
+	}
+}
+
+// Ensure that nested spans carry their counts as attributes.
+func TestSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	ctx, outer := Start(context.Background(), tracer, "test-outer-span")
+	performWork()
+	_, inner := Start(ctx, tracer, "test-inner-span")
+	performWork()
+	inner.End()
+	outer.End()
+
+	counts := make(map[string]int64)
+	for _, s := range recorder.Ended() {
+		attrs := attribute.NewSet(s.Attributes()...)
+		if e, ok := attrs.Value("papi.error"); ok {
+			t.Fatalf("%s: %s", s.Name(), e.AsString())
+		}
+		v, ok := attrs.Value("papi.TOT_INS")
+		if !ok {
+			t.Fatalf("%s has no papi.TOT_INS attribute", s.Name())
+		}
+		if _, ok = attrs.Value("papi.IPC"); !ok {
+			t.Fatalf("%s has no papi.IPC attribute", s.Name())
+		}
+		counts[s.Name()] = v.AsInt64()
+	}
+	if len(counts) != 2 || counts["test-outer-span"] < counts["test-inner-span"] {
+		t.Fatalf("Expected the outer span to count at least as much as the inner span: %v", counts)
+	}
+}
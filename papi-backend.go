@@ -0,0 +1,80 @@
+// This file defines the interface through which the rest of the
+// package talks to PAPI.  Normally that is the C library itself, but
+// calls can also be recorded to or replayed from a Trace.
+
+package papi
+
+import "sync"
+
+// A backend implements every PAPI interaction that can be recorded
+// and replayed.  Methods mirror the exported functions that call
+// them; argument checking is left to the exported functions.
+type backend interface {
+	// Event names and descriptions
+	eventCodeToName(ecode Event) (string, error)
+	eventNameToCode(ename string) (Event, error)
+	getEventInfo(ecode Event) (EventInfo, error)
+	getEventComponent(ecode Event) (int, error)
+	enumEvents(emask EventMask, modifier EventModifier) ([]Event, error)
+	enumUmasks(ecode Event) ([]Event, error)
+
+	// System information
+	getExecutableInfo() ProgramInfo
+	getHardwareInfo() HardwareInfo
+	getDynMemInfo() (DynMemInfo, error)
+	numComponents() int
+	numCmpHwctrs(idx int) int
+	getComponentInfo(idx int) (ComponentInfo, error)
+
+	// Timers
+	getRealCyc() int64
+	getRealUsec() int64
+	getVirtCyc() int64
+	getVirtUsec() int64
+
+	// Event-set composition
+	createEventSet() (EventSet, error)
+	addEvent(es EventSet, ecode Event) error
+	addEvents(es EventSet, ecodes []Event) error
+	removeEvent(es EventSet, ecode Event) error
+	removeEvents(es EventSet, ecodes []Event) error
+	numEvents(es EventSet) (int, error)
+	listEvents(es EventSet, numEvents int) ([]Event, error)
+	cleanupEventSet(es EventSet) error
+	destroyEventSet(es *EventSet) error
+	getMultiplex(es EventSet) (bool, error)
+	setMultiplex(es EventSet) error
+	assignComponent(es EventSet, idx int) error
+	attachCPU(es EventSet, cpu int) error
+	attach(es EventSet, tid int) error
+	setInherit(es EventSet) error
+
+	// Counting
+	start(es EventSet) error
+	stop(es EventSet, values []int64) error
+	read(es EventSet, values []int64) error
+	readTS(es EventSet, values []int64) (int64, error)
+	reset(es EventSet) error
+}
+
+// lib is the backend currently in use.  It is replaced while
+// recording or replaying.
+var (
+	libLock sync.RWMutex
+	lib     backend = cgoBackend{}
+)
+
+// Return the backend currently in use.
+func current() backend {
+	libLock.RLock()
+	defer libLock.RUnlock()
+	return lib
+}
+
+// Replace the current backend with a new one and return the old one.
+func swapBackend(b backend) (old backend) {
+	libLock.Lock()
+	defer libLock.Unlock()
+	old, lib = lib, b
+	return
+}
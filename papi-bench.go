@@ -0,0 +1,173 @@
+// This file integrates PAPI counters with Go benchmarks so that
+// "go test -bench" reports hardware-counter values alongside ns/op.
+
+package papi
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// A B wraps a testing.B to count events while the benchmark timer
+// runs.  Its StartTimer(), StopTimer(), and ResetTimer() methods
+// control the counters as well as the timer, so benchmarks should
+// call those methods on the B rather than on the underlying
+// testing.B.
+type B struct {
+	*testing.B
+	events  []Event  // Events being counted
+	es      EventSet // Event set counting the events
+	totals  []int64  // Counts accumulated while the timer was running
+	scratch []int64  // Buffer for reading counter values
+	running bool     // true if the counters are running
+}
+
+// Count events for the duration of a benchmark and report each event's
+// count per iteration as a custom benchmark metric named after the
+// event (e.g., "L1_DCM/op" for PAPI_L1_DCM).  If both PAPI_TOT_INS and
+// PAPI_TOT_CYC are counted, instructions per cycle are additionally
+// reported as "IPC".  If no events are specified, DefaultRegionEvents
+// are counted.
+//
+// Benchmark() locks the calling goroutine to its OS thread until the
+// benchmark completes and starts counting immediately.  Call
+// ResetTimer(), StopTimer(), and StartTimer() on the returned B to
+// exclude setup code from both the timer and the counters:
+//
+//	func BenchmarkSort(b *testing.B) {
+//		pb := papi.Benchmark(b, papi.TOT_INS, papi.TOT_CYC, papi.L1_DCM)
+//		for i := 0; i < pb.N; i++ {
+//			pb.StopTimer()
+//			data := makeData()
+//			pb.StartTimer()
+//			sort.Ints(data)
+//		}
+//	}
+func Benchmark(b *testing.B, events ...Event) *B {
+	b.Helper()
+	if len(events) == 0 {
+		events = DefaultRegionEvents
+	}
+	pb := &B{
+		B:       b,
+		events:  append([]Event(nil), events...),
+		totals:  make([]int64, len(events)),
+		scratch: make([]int64, len(events)),
+		es:      -1,
+	}
+	runtime.LockOSThread()
+	b.Cleanup(pb.finish)
+	es, err := CreateEventSet()
+	if err != nil {
+		b.Fatalf("papi: %v", err)
+	}
+	pb.es = es
+	if err = es.AddEvents(pb.events); err != nil {
+		b.Fatalf("papi: %v", err)
+	}
+	if err = es.Start(); err != nil {
+		b.Fatalf("papi: %v", err)
+	}
+	pb.running = true
+	return pb
+}
+
+// Start timing and counting.  The benchmark framework calls the
+// underlying testing.B's StartTimer() automatically before the
+// benchmark begins.
+func (pb *B) StartTimer() {
+	pb.Helper()
+	pb.B.StartTimer()
+	if !pb.running {
+		if err := pb.es.Start(); err != nil {
+			pb.Fatalf("papi: %v", err)
+		}
+		pb.running = true
+	}
+}
+
+// Stop timing and counting.  Counts accumulate across StopTimer() and
+// StartTimer() pairs.
+func (pb *B) StopTimer() {
+	pb.Helper()
+	if pb.running {
+		pb.running = false
+		if err := pb.es.Stop(pb.scratch); err != nil {
+			pb.Fatalf("papi: %v", err)
+		}
+		for i, v := range pb.scratch {
+			pb.totals[i] += v
+		}
+	}
+	pb.B.StopTimer()
+}
+
+// Zero the elapsed time, memory-allocation counters, and event counts.
+func (pb *B) ResetTimer() {
+	pb.Helper()
+	pb.B.ResetTimer()
+	for i := range pb.totals {
+		pb.totals[i] = 0
+	}
+	if pb.running {
+		if err := pb.es.Reset(); err != nil {
+			pb.Fatalf("papi: %v", err)
+		}
+	}
+}
+
+// Return the counts accumulated so far, one per event.
+func (pb *B) Counts() []int64 {
+	counts := append([]int64(nil), pb.totals...)
+	if pb.running {
+		if err := pb.es.Read(pb.scratch); err == nil {
+			for i, v := range pb.scratch {
+				counts[i] += v
+			}
+		}
+	}
+	return counts
+}
+
+// Return the name under which an event is reported as a benchmark
+// metric.
+func benchMetricName(ecode Event) string {
+	return strings.TrimPrefix(ecode.String(), "PAPI_") + "/op"
+}
+
+// Stop counting, report the counts, and release the event set.
+func (pb *B) finish() {
+	defer runtime.UnlockOSThread()
+	if pb.es == -1 {
+		return
+	}
+	defer pb.es.DestroyEventSet()
+	defer pb.es.CleanupEventSet()
+	if pb.running {
+		pb.running = false
+		if err := pb.es.Stop(pb.scratch); err != nil {
+			pb.Errorf("papi: %v", err)
+			return
+		}
+		for i, v := range pb.scratch {
+			pb.totals[i] += v
+		}
+	}
+	if pb.N <= 0 {
+		return
+	}
+	var ins, cyc int64 = -1, -1
+	for i, ev := range pb.events {
+		pb.ReportMetric(float64(pb.totals[i])/float64(pb.N), benchMetricName(ev))
+		switch ev {
+		case TOT_INS:
+			ins = pb.totals[i]
+		case TOT_CYC:
+			cyc = pb.totals[i]
+		}
+	}
+	if ins >= 0 && cyc > 0 {
+		pb.ReportMetric(float64(ins)/float64(cyc), "IPC")
+	}
+}
@@ -0,0 +1,284 @@
+// This file emulates PAPI's high-level functions using event sets.
+// It provides the functions that were removed in PAPI 6 when running
+// with a newer PAPI and the functions that were added in PAPI 6 when
+// running with an older PAPI.
+
+package papi
+
+import (
+	"runtime"
+	"sync"
+)
+
+// An hlKind indicates which high-level function is using a thread's
+// emulated high-level event set.
+type hlKind int
+
+const (
+	hlCounters  hlKind = iota // StartCounters()
+	hlFlips                   // Flips()
+	hlFlops                   // Flops()
+	hlIpc                     // Ipc()
+	hlFlipsRate               // FlipsRate()
+	hlFlopsRate               // FlopsRate()
+	hlEpc                     // Epc()
+	hlIpcRate                 // IpcRate()
+)
+
+// Report whether a kind of high-level function belongs to the rate
+// functions introduced in PAPI 6, which RateStop() stops, rather than
+// to the original functions, which StopCounters() stops.
+func (k hlKind) isRate() bool {
+	return k >= hlFlipsRate
+}
+
+// An hlState is the emulated high-level event set of a single thread.
+// As with PAPI's own high-level functions, each thread counts
+// independently.  The calling goroutine is locked to its OS thread
+// from the time counting starts until it stops.
+type hlState struct {
+	kind      hlKind   // High-level function that started counting
+	event     Event    // Event requested by that function
+	events    EventSet // Event set that does the counting
+	startReal int64    // Real time in microseconds when counting started
+	startVirt int64    // Virtual time in microseconds when counting started
+	lastReal  int64    // Real time in microseconds at the previous call
+	lastVirt  int64    // Virtual time in microseconds at the previous call
+	last      []int64  // Counter values at the previous call
+}
+
+// Every thread's emulated high-level event set
+var hlThreads = struct {
+	sync.Mutex
+	m map[uint64]*hlState
+}{m: make(map[uint64]*hlState)}
+
+// Return the calling thread's emulated high-level event set or nil if
+// the thread isn't counting.
+func hlCurrent() *hlState {
+	hlThreads.Lock()
+	defer hlThreads.Unlock()
+	return hlThreads.m[threadID()]
+}
+
+// Start counting a set of events on the calling thread.
+func hlStart(kind hlKind, event Event, ecodes []Event) (st *hlState, err error) {
+	if hlCurrent() != nil {
+		return nil, EISRUN
+	}
+	runtime.LockOSThread()
+	defer func() {
+		if err != nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+	events, err := CreateEventSet()
+	if err != nil {
+		return
+	}
+	if err = events.AddEvents(ecodes); err != nil {
+		events.CleanupEventSet()
+		events.DestroyEventSet()
+		return
+	}
+	st = &hlState{
+		kind:   kind,
+		event:  event,
+		events: events,
+		last:   make([]int64, len(ecodes)),
+	}
+	st.startReal, st.startVirt = GetRealUsec(), GetVirtUsec()
+	st.lastReal, st.lastVirt = st.startReal, st.startVirt
+	if err = events.Start(); err != nil {
+		events.CleanupEventSet()
+		events.DestroyEventSet()
+		return nil, err
+	}
+	hlThreads.Lock()
+	hlThreads.m[threadID()] = st
+	hlThreads.Unlock()
+	return
+}
+
+// Perform one call to an emulated rate function.  The first call
+// starts counting the given events and returns a nil slice of counter
+// values.  Subsequent calls return the current counter values.
+func hlRateCall(kind hlKind, event Event, ecodes []Event) (st *hlState, now []int64, err error) {
+	if st = hlCurrent(); st == nil {
+		st, err = hlStart(kind, event, ecodes)
+		return
+	}
+	if st.kind != kind || st.event != event {
+		return nil, nil, ECNFLCT
+	}
+	now = make([]int64, len(st.last))
+	err = st.events.Read(now)
+	return
+}
+
+// Compute a rate in millions per second from a count and a duration
+// in microseconds.
+func perUsec(count, usec int64) float32 {
+	if usec <= 0 {
+		return 0
+	}
+	return float32(float64(count) / float64(usec))
+}
+
+// Compute a ratio of two counts.
+func ratio(num, denom int64) float32 {
+	if denom == 0 {
+		return 0
+	}
+	return float32(float64(num) / float64(denom))
+}
+
+// Emulate PAPI 5's PAPI_flips() or PAPI_flops(), which report totals
+// since the first call and a rate since the previous call.
+func emulateLegacyRate(kind hlKind, event Event) (rtime, ptime float32, count int64, rate float32, err error) {
+	st, now, err := hlRateCall(kind, event, []Event{event})
+	if err != nil || now == nil {
+		return
+	}
+	real, virt := GetRealUsec(), GetVirtUsec()
+	rtime = float32(real-st.startReal) / 1e6
+	ptime = float32(virt-st.startVirt) / 1e6
+	count = now[0]
+	rate = perUsec(now[0]-st.last[0], real-st.lastReal)
+	st.lastReal, st.lastVirt, st.last = real, virt, now
+	return
+}
+
+// Emulate PAPI 5's PAPI_ipc(), which reports totals since the first
+// call and instructions per cycle since the previous call.
+func emulateLegacyIpc() (rtime, ptime float32, ins int64, ipc float32, err error) {
+	st, now, err := hlRateCall(hlIpc, TOT_INS, []Event{TOT_INS, TOT_CYC})
+	if err != nil || now == nil {
+		return
+	}
+	real, virt := GetRealUsec(), GetVirtUsec()
+	rtime = float32(real-st.startReal) / 1e6
+	ptime = float32(virt-st.startVirt) / 1e6
+	ins = now[0]
+	ipc = ratio(now[0]-st.last[0], now[1]-st.last[1])
+	st.lastReal, st.lastVirt, st.last = real, virt, now
+	return
+}
+
+// Emulate PAPI 6's PAPI_flips_rate() or PAPI_flops_rate(), which
+// report everything since the previous call.
+func emulateRate(kind hlKind, event Event) (rtime, ptime float32, count int64, rate float32, err error) {
+	st, now, err := hlRateCall(kind, event, []Event{event})
+	if err != nil || now == nil {
+		return
+	}
+	real, virt := GetRealUsec(), GetVirtUsec()
+	rtime = float32(real-st.lastReal) / 1e6
+	ptime = float32(virt-st.lastVirt) / 1e6
+	count = now[0] - st.last[0]
+	rate = perUsec(count, real-st.lastReal)
+	st.lastReal, st.lastVirt, st.last = real, virt, now
+	return
+}
+
+// Emulate PAPI 6's PAPI_ipc(), which reports everything since the
+// previous call.
+func emulateIpcRate() (rtime, ptime float32, ins int64, ipc float32, err error) {
+	st, now, err := hlRateCall(hlIpcRate, TOT_INS, []Event{TOT_INS, TOT_CYC})
+	if err != nil || now == nil {
+		return
+	}
+	real, virt := GetRealUsec(), GetVirtUsec()
+	rtime = float32(real-st.lastReal) / 1e6
+	ptime = float32(virt-st.lastVirt) / 1e6
+	ins = now[0] - st.last[0]
+	ipc = ratio(ins, now[1]-st.last[1])
+	st.lastReal, st.lastVirt, st.last = real, virt, now
+	return
+}
+
+// Emulate PAPI 6's PAPI_epc(), which reports everything since the
+// previous call.
+func emulateEpc(event Event) (rtime, ptime float32, ref, core, evt int64, epc float32, err error) {
+	if event == 0 {
+		event = TOT_INS
+	}
+	st, now, err := hlRateCall(hlEpc, event, []Event{event, TOT_CYC, REF_CYC})
+	if err != nil && hlCurrent() == nil {
+		// Try again without reference cycles.
+		st, now, err = hlRateCall(hlEpc, event, []Event{event, TOT_CYC})
+	}
+	if err != nil || now == nil {
+		return
+	}
+	real, virt := GetRealUsec(), GetVirtUsec()
+	rtime = float32(real-st.lastReal) / 1e6
+	ptime = float32(virt-st.lastVirt) / 1e6
+	evt = now[0] - st.last[0]
+	core = now[1] - st.last[1]
+	if len(now) > 2 {
+		ref = now[2] - st.last[2]
+	}
+	epc = ratio(evt, core)
+	st.lastReal, st.lastVirt, st.last = real, virt, now
+	return
+}
+
+// Emulate PAPI 5's PAPI_start_counters().
+func emulateStartCounters(evcodes []Event) error {
+	_, err := hlStart(hlCounters, 0, evcodes)
+	return err
+}
+
+// Emulate PAPI 5's PAPI_read_counters() or, if accum is true,
+// PAPI_accum_counters().
+func emulateReadCounters(values []int64, accum bool) error {
+	st := hlCurrent()
+	if st == nil {
+		return ENOTRUN
+	}
+	if len(values) < len(st.last) {
+		return EINVAL
+	}
+	now := make([]int64, len(st.last))
+	if err := st.events.Read(now); err != nil {
+		return err
+	}
+	if err := st.events.Reset(); err != nil {
+		return err
+	}
+	for i, v := range now {
+		if accum {
+			values[i] += v
+		} else {
+			values[i] = v
+		}
+	}
+	return nil
+}
+
+// Emulate PAPI 5's PAPI_stop_counters() or, if rate is true, PAPI 6's
+// PAPI_rate_stop().  Each stops only counting started by its own set of
+// high-level functions and otherwise returns ECNFLCT.  values may be
+// nil.
+func emulateStopCounters(values []int64, rate bool) error {
+	st := hlCurrent()
+	if st == nil {
+		return ENOTRUN
+	}
+	if st.kind.isRate() != rate {
+		return ECNFLCT
+	}
+	now := make([]int64, len(st.last))
+	if err := st.events.Stop(now); err != nil {
+		return err
+	}
+	copy(values, now)
+	st.events.CleanupEventSet()
+	st.events.DestroyEventSet()
+	hlThreads.Lock()
+	delete(hlThreads.m, threadID())
+	hlThreads.Unlock()
+	runtime.UnlockOSThread()
+	return nil
+}
@@ -0,0 +1,117 @@
+// Code generated by genconsts from papiStdEventDefs.h (PAPI 5.7.0.0); DO NOT EDIT.
+
+//go:build papi5
+
+package papi
+
+// The following constants represent PAPI's standard event types.
+const (
+	BRU_IDL Event = -1<<31 | 0x10 // Cycles branch units are idle
+	BR_CN   Event = -1<<31 | 0x2b // Conditional branch instructions executed
+	BR_INS  Event = -1<<31 | 0x37 // Total branch instructions executed
+	BR_MSP  Event = -1<<31 | 0x2e // Conditional branch instructions mispred
+	BR_NTK  Event = -1<<31 | 0x2d // Conditional branch instructions not taken
+	BR_PRC  Event = -1<<31 | 0x2f // Conditional branch instructions corr. pred
+	BR_TKN  Event = -1<<31 | 0x2c // Conditional branch instructions taken
+	BR_UCN  Event = -1<<31 | 0x2a // Unconditional branch instructions executed
+	BTAC_M  Event = -1<<31 | 0x1b // BTAC miss
+	CA_CLN  Event = -1<<31 | 0x0b // Request for clean cache line (SMP)
+	CA_INV  Event = -1<<31 | 0x0c // Request for cache line Invalidation (SMP)
+	CA_ITV  Event = -1<<31 | 0x0d // Request for cache line Intervention (SMP)
+	CA_SHR  Event = -1<<31 | 0x0a // Request for shared cache line (SMP)
+	CA_SNP  Event = -1<<31 | 0x09 // Snoops
+	CSR_FAL Event = -1<<31 | 0x1f // Failed store conditional instructions
+	CSR_SUC Event = -1<<31 | 0x20 // Successful store conditional instructions
+	CSR_TOT Event = -1<<31 | 0x21 // Total store conditional instructions
+	DP_OPS  Event = -1<<31 | 0x68 // Floating point operations executed; optimized to count scaled double precision vector operations
+	FAD_INS Event = -1<<31 | 0x62 // FA ins
+	FDV_INS Event = -1<<31 | 0x63 // FD ins
+	FMA_INS Event = -1<<31 | 0x30 // FMA instructions completed
+	FML_INS Event = -1<<31 | 0x61 // FM ins
+	FNV_INS Event = -1<<31 | 0x65 // Finv ins
+	FPU_IDL Event = -1<<31 | 0x12 // Cycles floating point units are idle
+	FP_INS  Event = -1<<31 | 0x34 // Floating point instructions executed
+	FP_OPS  Event = -1<<31 | 0x66 // Floating point operations executed
+	FP_STAL Event = -1<<31 | 0x3a // Cycles any FP units are stalled
+	FSQ_INS Event = -1<<31 | 0x64 // FSq ins
+	FUL_CCY Event = -1<<31 | 0x28 // Cycles with Maximum Instruction Completion
+	FUL_ICY Event = -1<<31 | 0x26 // Cycles with Maximum Instruction Issue
+	FXU_IDL Event = -1<<31 | 0x11 // Cycles integer units are idle
+	HW_INT  Event = -1<<31 | 0x29 // Hardware interrupts
+	INT_INS Event = -1<<31 | 0x33 // Integer instructions executed
+	L1_DCA  Event = -1<<31 | 0x40 // L1 D Cache Access
+	L1_DCH  Event = -1<<31 | 0x3e // L1 D Cache Hit
+	L1_DCM  Event = -1<<31 | 0x00 // Level 1 data cache misses
+	L1_DCR  Event = -1<<31 | 0x43 // L1 D Cache Read
+	L1_DCW  Event = -1<<31 | 0x46 // L1 D Cache Write
+	L1_ICA  Event = -1<<31 | 0x4c // L1 instruction cache accesses
+	L1_ICH  Event = -1<<31 | 0x49 // L1 instruction cache hits
+	L1_ICM  Event = -1<<31 | 0x01 // Level 1 instruction cache misses
+	L1_ICR  Event = -1<<31 | 0x4f // L1 instruction cache reads
+	L1_ICW  Event = -1<<31 | 0x52 // L1 instruction cache writes
+	L1_LDM  Event = -1<<31 | 0x17 // Level 1 load misses
+	L1_STM  Event = -1<<31 | 0x18 // Level 1 store misses
+	L1_TCA  Event = -1<<31 | 0x58 // L1 total cache accesses
+	L1_TCH  Event = -1<<31 | 0x55 // L1 total cache hits
+	L1_TCM  Event = -1<<31 | 0x06 // Level 1 total cache misses
+	L1_TCR  Event = -1<<31 | 0x5b // L1 total cache reads
+	L1_TCW  Event = -1<<31 | 0x5e // L1 total cache writes
+	L2_DCA  Event = -1<<31 | 0x41 // L2 D Cache Access
+	L2_DCH  Event = -1<<31 | 0x3f // L2 D Cache Hit
+	L2_DCM  Event = -1<<31 | 0x02 // Level 2 data cache misses
+	L2_DCR  Event = -1<<31 | 0x44 // L2 D Cache Read
+	L2_DCW  Event = -1<<31 | 0x47 // L2 D Cache Write
+	L2_ICA  Event = -1<<31 | 0x4d // L2 instruction cache accesses
+	L2_ICH  Event = -1<<31 | 0x4a // L2 instruction cache hits
+	L2_ICM  Event = -1<<31 | 0x03 // Level 2 instruction cache misses
+	L2_ICR  Event = -1<<31 | 0x50 // L2 instruction cache reads
+	L2_ICW  Event = -1<<31 | 0x53 // L2 instruction cache writes
+	L2_LDM  Event = -1<<31 | 0x19 // Level 2 load misses
+	L2_STM  Event = -1<<31 | 0x1a // Level 2 store misses
+	L2_TCA  Event = -1<<31 | 0x59 // L2 total cache accesses
+	L2_TCH  Event = -1<<31 | 0x56 // L2 total cache hits
+	L2_TCM  Event = -1<<31 | 0x07 // Level 2 total cache misses
+	L2_TCR  Event = -1<<31 | 0x5c // L2 total cache reads
+	L2_TCW  Event = -1<<31 | 0x5f // L2 total cache writes
+	L3_DCA  Event = -1<<31 | 0x42 // L3 D Cache Access
+	L3_DCH  Event = -1<<31 | 0x1d // Level 3 Data Cache Hit
+	L3_DCM  Event = -1<<31 | 0x04 // Level 3 data cache misses
+	L3_DCR  Event = -1<<31 | 0x45 // L3 D Cache Read
+	L3_DCW  Event = -1<<31 | 0x48 // L3 D Cache Write
+	L3_ICA  Event = -1<<31 | 0x4e // L3 instruction cache accesses
+	L3_ICH  Event = -1<<31 | 0x4b // L3 instruction cache hits
+	L3_ICM  Event = -1<<31 | 0x05 // Level 3 instruction cache misses
+	L3_ICR  Event = -1<<31 | 0x51 // L3 instruction cache reads
+	L3_ICW  Event = -1<<31 | 0x54 // L3 instruction cache writes
+	L3_LDM  Event = -1<<31 | 0x0e // Level 3 load misses
+	L3_STM  Event = -1<<31 | 0x0f // Level 3 store misses
+	L3_TCA  Event = -1<<31 | 0x5a // L3 total cache accesses
+	L3_TCH  Event = -1<<31 | 0x57 // L3 total cache hits
+	L3_TCM  Event = -1<<31 | 0x08 // Level 3 total cache misses
+	L3_TCR  Event = -1<<31 | 0x5d // L3 total cache reads
+	L3_TCW  Event = -1<<31 | 0x60 // L3 total cache writes
+	LD_INS  Event = -1<<31 | 0x35 // Load instructions executed
+	LST_INS Event = -1<<31 | 0x3c // Total load/store inst. executed
+	LSU_IDL Event = -1<<31 | 0x13 // Cycles load/store units are idle
+	MEM_RCY Event = -1<<31 | 0x23 // Cycles Stalled Waiting for Memory Read
+	MEM_SCY Event = -1<<31 | 0x22 // Cycles Stalled Waiting for Memory Access
+	MEM_WCY Event = -1<<31 | 0x24 // Cycles Stalled Waiting for Memory Write
+	PRF_DM  Event = -1<<31 | 0x1c // Prefetch data instruction caused a miss
+	REF_CYC Event = -1<<31 | 0x6b // Reference clock cycles
+	RES_STL Event = -1<<31 | 0x39 // Cycles processor is stalled on resource
+	SP_OPS  Event = -1<<31 | 0x67 // Floating point operations executed; optimized to count scaled single precision vector operations
+	SR_INS  Event = -1<<31 | 0x36 // Store instructions executed
+	STL_CCY Event = -1<<31 | 0x27 // Cycles with No Instruction Completion
+	STL_ICY Event = -1<<31 | 0x25 // Cycles with No Instruction Issue
+	SYC_INS Event = -1<<31 | 0x3d // Sync. inst. executed
+	TLB_DM  Event = -1<<31 | 0x14 // Data translation lookaside buffer misses
+	TLB_IM  Event = -1<<31 | 0x15 // Instr translation lookaside buffer misses
+	TLB_SD  Event = -1<<31 | 0x1e // Xlation lookaside buffer shootdowns (SMP)
+	TLB_TL  Event = -1<<31 | 0x16 // Total translation lookaside buffer misses
+	TOT_CYC Event = -1<<31 | 0x3b // Total cycles executed
+	TOT_IIS Event = -1<<31 | 0x31 // Total instructions issued
+	TOT_INS Event = -1<<31 | 0x32 // Total instructions executed
+	VEC_DP  Event = -1<<31 | 0x6a // Double precision vector/SIMD instructions
+	VEC_INS Event = -1<<31 | 0x38 // Vector/SIMD instructions executed (could include integer)
+	VEC_SP  Event = -1<<31 | 0x69 // Single precision vector/SIMD instructions
+)
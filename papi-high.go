@@ -2,21 +2,81 @@
 
 package papi
 
-// #include <papi.h>
+/*
+#include <papi.h>
+
+// PAPI 6 removed the original high-level functions and replaced them
+// with a set of rate functions.  Wrap whichever set the installed PAPI
+// provides and stub out the other so that the Go code can refer to
+// both.
+#if PAPI_VERSION_MAJOR(PAPI_VERSION) < 6
+#define GO_PAPI_LEGACY_HL 1
+
+static int go_papi_num_counters(void) { return PAPI_num_counters(); }
+static int go_papi_flips(float *rtime, float *ptime, long long *flpins, float *mflips) { return PAPI_flips(rtime, ptime, flpins, mflips); }
+static int go_papi_flops(float *rtime, float *ptime, long long *flpops, float *mflops) { return PAPI_flops(rtime, ptime, flpops, mflops); }
+static int go_papi_ipc(float *rtime, float *ptime, long long *ins, float *ipc) { return PAPI_ipc(rtime, ptime, ins, ipc); }
+static int go_papi_start_counters(int *events, int len) { return PAPI_start_counters(events, len); }
+static int go_papi_read_counters(long long *values, int len) { return PAPI_read_counters(values, len); }
+static int go_papi_accum_counters(long long *values, int len) { return PAPI_accum_counters(values, len); }
+static int go_papi_stop_counters(long long *values, int len) { return PAPI_stop_counters(values, len); }
+
+static int go_papi_flips_rate(int event, float *rtime, float *ptime, long long *flpins, float *mflips) { return PAPI_ENOSUPP; }
+static int go_papi_flops_rate(int event, float *rtime, float *ptime, long long *flpops, float *mflops) { return PAPI_ENOSUPP; }
+static int go_papi_epc(int event, float *rtime, float *ptime, long long *ref, long long *core, long long *evt, float *epc) { return PAPI_ENOSUPP; }
+static int go_papi_ipc_rate(float *rtime, float *ptime, long long *ins, float *ipc) { return PAPI_ENOSUPP; }
+static int go_papi_rate_stop(void) { return PAPI_ENOSUPP; }
+#else
+#define GO_PAPI_LEGACY_HL 0
+
+static int go_papi_num_counters(void) { return PAPI_num_cmp_hwctrs(0); }
+static int go_papi_flips(float *rtime, float *ptime, long long *flpins, float *mflips) { return PAPI_ENOSUPP; }
+static int go_papi_flops(float *rtime, float *ptime, long long *flpops, float *mflops) { return PAPI_ENOSUPP; }
+static int go_papi_ipc(float *rtime, float *ptime, long long *ins, float *ipc) { return PAPI_ENOSUPP; }
+static int go_papi_start_counters(int *events, int len) { return PAPI_ENOSUPP; }
+static int go_papi_read_counters(long long *values, int len) { return PAPI_ENOSUPP; }
+static int go_papi_accum_counters(long long *values, int len) { return PAPI_ENOSUPP; }
+static int go_papi_stop_counters(long long *values, int len) { return PAPI_ENOSUPP; }
+
+static int go_papi_flips_rate(int event, float *rtime, float *ptime, long long *flpins, float *mflips) { return PAPI_flips_rate(event, rtime, ptime, flpins, mflips); }
+static int go_papi_flops_rate(int event, float *rtime, float *ptime, long long *flpops, float *mflops) { return PAPI_flops_rate(event, rtime, ptime, flpops, mflops); }
+static int go_papi_epc(int event, float *rtime, float *ptime, long long *ref, long long *core, long long *evt, float *epc) { return PAPI_epc(event, rtime, ptime, ref, core, evt, epc); }
+static int go_papi_ipc_rate(float *rtime, float *ptime, long long *ins, float *ipc) { return PAPI_ipc(rtime, ptime, ins, ipc); }
+static int go_papi_rate_stop(void) { return PAPI_rate_stop(); }
+#endif
+*/
 import "C"
 
+// legacyHL is true if the PAPI library provides the high-level
+// functions that were removed in PAPI 6.  If not, those functions are
+// emulated using event sets.
+const legacyHL = C.GO_PAPI_LEGACY_HL != 0
+
 // NumCounters is the number of hardware counters available on the
 // system.  Consequently, the slice passed to functions such as
 // StartCounters() should contain no more than NumCounters elements.
+// NumCounters is set when the PAPI library is initialized, by the
+// first call into it, and is 0 until then.
 var NumCounters int
 
+// Return the number of hardware counters available to the high-level
+// functions.  numHLCounters() is called while initializing PAPI and
+// therefore does not call initialize().
+func numHLCounters() int {
+	return int(C.go_papi_num_counters())
+}
+
 // Return the total real time, total process time, total
 // floating-point instructions, and average Mflip/s since the previous
 // call to PAPI.Flips().
 func Flips() (rtime, ptime float32, flpins int64, mflips float32, err error) {
+	if !legacyHL {
+		return emulateLegacyRate(hlFlips, FP_INS)
+	}
+	initialize()
 	var c_rtime, c_ptime, c_mflips C.float
 	var c_flpins C.longlong
-	errno := Errno(C.PAPI_flips(&c_rtime, &c_ptime, &c_flpins, &c_mflips))
+	errno := Errno(C.go_papi_flips(&c_rtime, &c_ptime, &c_flpins, &c_mflips))
 	if errno == papi_ok {
 		rtime, ptime, flpins, mflips = float32(c_rtime), float32(c_ptime), int64(c_flpins), float32(c_mflips)
 	} else {
@@ -29,9 +89,13 @@ func Flips() (rtime, ptime float32, flpins int64, mflips float32, err error) {
 // floating-point operations, and average Mflop/s since the previous
 // call to PAPI.Flops().
 func Flops() (rtime, ptime float32, flpops int64, mflops float32, err error) {
+	if !legacyHL {
+		return emulateLegacyRate(hlFlops, FP_OPS)
+	}
+	initialize()
 	var c_rtime, c_ptime, c_mflops C.float
 	var c_flpops C.longlong
-	errno := Errno(C.PAPI_flops(&c_rtime, &c_ptime, &c_flpops, &c_mflops))
+	errno := Errno(C.go_papi_flops(&c_rtime, &c_ptime, &c_flpops, &c_mflops))
 	if errno == papi_ok {
 		rtime, ptime, flpops, mflops = float32(c_rtime), float32(c_ptime), int64(c_flpops), float32(c_mflops)
 	} else {
@@ -42,11 +106,16 @@ func Flops() (rtime, ptime float32, flpops int64, mflops float32, err error) {
 
 // Return the total real time, total process time, total number of
 // instructions, and average instructions per cycle since the previous
-// call to PAPI.Ipc().
+// call to PAPI.Ipc().  Ipc() provides PAPI 5's semantics on every PAPI
+// version; see IpcRate() for PAPI 6's.
 func Ipc() (rtime, ptime float32, ins int64, ipc float32, err error) {
+	if !legacyHL {
+		return emulateLegacyIpc()
+	}
+	initialize()
 	var c_rtime, c_ptime, c_ipc C.float
 	var c_ins C.longlong
-	errno := Errno(C.PAPI_ipc(&c_rtime, &c_ptime, &c_ins, &c_ipc))
+	errno := Errno(C.go_papi_ipc(&c_rtime, &c_ptime, &c_ins, &c_ipc))
 	if errno == papi_ok {
 		rtime, ptime, ins, ipc = float32(c_rtime), float32(c_ptime), int64(c_ins), float32(c_ipc)
 	} else {
@@ -57,9 +126,13 @@ func Ipc() (rtime, ptime float32, ins int64, ipc float32, err error) {
 
 // Given a slice of event codes, start counting the corresponding events.
 func StartCounters(evcodes []Event) (err error) {
+	if !legacyHL {
+		return emulateStartCounters(evcodes)
+	}
+	initialize()
 	events := (*C.int)(&evcodes[0])
 	numEvents := C.int(len(evcodes))
-	if errno := Errno(C.PAPI_start_counters(events, numEvents)); errno != papi_ok {
+	if errno := Errno(C.go_papi_start_counters(events, numEvents)); errno != papi_ok {
 		err = errno
 	}
 	return
@@ -68,9 +141,13 @@ func StartCounters(evcodes []Event) (err error) {
 // Store the current event counts in a given slice and reset the
 // counters to zero.
 func ReadCounters(values []int64) (err error) {
+	if !legacyHL {
+		return emulateReadCounters(values, false)
+	}
+	initialize()
 	valuePtr := (*C.longlong)(&values[0])
 	numValues := C.int(len(values))
-	if errno := Errno(C.PAPI_read_counters(valuePtr, numValues)); errno != papi_ok {
+	if errno := Errno(C.go_papi_read_counters(valuePtr, numValues)); errno != papi_ok {
 		err = errno
 	}
 	return
@@ -79,9 +156,13 @@ func ReadCounters(values []int64) (err error) {
 // Add the current event counts to those in a given slice and reset
 // the counters to zero.
 func AccumCounters(values []int64) (err error) {
+	if !legacyHL {
+		return emulateReadCounters(values, true)
+	}
+	initialize()
 	valuePtr := (*C.longlong)(&values[0])
 	numValues := C.int(len(values))
-	if errno := Errno(C.PAPI_accum_counters(valuePtr, numValues)); errno != papi_ok {
+	if errno := Errno(C.go_papi_accum_counters(valuePtr, numValues)); errno != papi_ok {
 		err = errno
 	}
 	return
@@ -90,9 +171,111 @@ func AccumCounters(values []int64) (err error) {
 // Store the current event counts in a given slice, reset the
 // counters to zero, and stop counting the events.
 func StopCounters(values []int64) (err error) {
+	if !legacyHL {
+		return emulateStopCounters(values, false)
+	}
+	initialize()
 	valuePtr := (*C.longlong)(&values[0])
 	numValues := C.int(len(values))
-	if errno := Errno(C.PAPI_stop_counters(valuePtr, numValues)); errno != papi_ok {
+	if errno := Errno(C.go_papi_stop_counters(valuePtr, numValues)); errno != papi_ok {
+		err = errno
+	}
+	return
+}
+
+// ----------------------------------------------------------------------
+
+// Return the real time, process time, number of floating-point
+// instructions, and Mflip/s since the previous call to FlipsRate().
+// The event must be one of FP_INS, VEC_SP, or VEC_DP.  The first call
+// starts counting and returns zeroes; RateStop() stops counting.
+func FlipsRate(event Event) (rtime, ptime float32, flpins int64, mflips float32, err error) {
+	if legacyHL {
+		return emulateRate(hlFlipsRate, event)
+	}
+	initialize()
+	var c_rtime, c_ptime, c_mflips C.float
+	var c_flpins C.longlong
+	errno := Errno(C.go_papi_flips_rate(C.int(event), &c_rtime, &c_ptime, &c_flpins, &c_mflips))
+	if errno == papi_ok {
+		rtime, ptime, flpins, mflips = float32(c_rtime), float32(c_ptime), int64(c_flpins), float32(c_mflips)
+	} else {
+		err = errno
+	}
+	return
+}
+
+// Return the real time, process time, number of floating-point
+// operations, and Mflop/s since the previous call to FlopsRate().
+// The event must be one of FP_OPS, SP_OPS, or DP_OPS.  The first call
+// starts counting and returns zeroes; RateStop() stops counting.
+func FlopsRate(event Event) (rtime, ptime float32, flpops int64, mflops float32, err error) {
+	if legacyHL {
+		return emulateRate(hlFlopsRate, event)
+	}
+	initialize()
+	var c_rtime, c_ptime, c_mflops C.float
+	var c_flpops C.longlong
+	errno := Errno(C.go_papi_flops_rate(C.int(event), &c_rtime, &c_ptime, &c_flpops, &c_mflops))
+	if errno == papi_ok {
+		rtime, ptime, flpops, mflops = float32(c_rtime), float32(c_ptime), int64(c_flpops), float32(c_mflops)
+	} else {
+		err = errno
+	}
+	return
+}
+
+// Return the real time, process time, number of instructions, and
+// instructions per cycle since the previous call to IpcRate().  The
+// first call starts counting and returns zeroes; RateStop() stops
+// counting.  IpcRate() corresponds to PAPI 6's PAPI_ipc().
+func IpcRate() (rtime, ptime float32, ins int64, ipc float32, err error) {
+	if legacyHL {
+		return emulateIpcRate()
+	}
+	initialize()
+	var c_rtime, c_ptime, c_ipc C.float
+	var c_ins C.longlong
+	errno := Errno(C.go_papi_ipc_rate(&c_rtime, &c_ptime, &c_ins, &c_ipc))
+	if errno == papi_ok {
+		rtime, ptime, ins, ipc = float32(c_rtime), float32(c_ptime), int64(c_ins), float32(c_ipc)
+	} else {
+		err = errno
+	}
+	return
+}
+
+// Return the real time, process time, reference cycles, core cycles,
+// number of occurrences of an arbitrary event, and events per cycle
+// since the previous call to Epc().  An event of 0 counts TOT_INS.
+// Reference cycles are reported as 0 on systems that cannot count
+// REF_CYC.  The first call starts counting and returns zeroes;
+// RateStop() stops counting.
+func Epc(event Event) (rtime, ptime float32, ref, core, evt int64, epc float32, err error) {
+	if legacyHL {
+		return emulateEpc(event)
+	}
+	initialize()
+	var c_rtime, c_ptime, c_epc C.float
+	var c_ref, c_core, c_evt C.longlong
+	errno := Errno(C.go_papi_epc(C.int(event), &c_rtime, &c_ptime, &c_ref, &c_core, &c_evt, &c_epc))
+	if errno == papi_ok {
+		rtime, ptime, epc = float32(c_rtime), float32(c_ptime), float32(c_epc)
+		ref, core, evt = int64(c_ref), int64(c_core), int64(c_evt)
+	} else {
+		err = errno
+	}
+	return
+}
+
+// Stop the counters started by FlipsRate(), FlopsRate(), IpcRate(), or
+// Epc().
+func RateStop() (err error) {
+	if legacyHL {
+		return emulateStopCounters(nil, true)
+	}
+	initialize()
+	if errno := Errno(C.go_papi_rate_stop()); errno != papi_ok {
 		err = errno
 	}
 	return
@@ -0,0 +1,186 @@
+// This file parses and evaluates the postfix expressions with which
+// PAPI defines derived events.
+
+package papi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A postfixOp is a single operation in a postfix expression.
+type postfixOp struct {
+	kind  byte    // 'N' (push a term), 'c' (push a constant), or an arithmetic operator
+	term  int     // Index of the term to push for kind 'N'
+	value float64 // Value to push for kind 'c'
+}
+
+// A Postfix is a parsed PAPI postfix expression, such as "N0|N1|-|",
+// that computes a derived event's value from the values of its terms
+// (typically native events).  The language consists of the following
+// tokens, separated by "|":
+//
+//	Nk       the value of term k, numbered from 0
+//	number   a non-negative numeric constant
+//	#        the CPU's maximum clock rate in Hz
+//	+ - * /  arithmetic on the top two values on the stack
+//
+// The clock rate is looked up once, when the expression is parsed, so
+// that evaluating the expression never calls into PAPI.
+type Postfix struct {
+	expr     string      // Original expression
+	ops      []postfixOp // Parsed expression
+	numTerms int         // Number of terms referenced (one more than the largest k in Nk)
+	depth    int         // Maximum stack depth required
+}
+
+// A PostfixError describes an invalid postfix expression.
+type PostfixError struct {
+	Expr   string // Postfix expression
+	Token  string // Offending token, if any
+	Reason string // Description of the problem
+}
+
+func (e *PostfixError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("papi: postfix expression %q: %s", e.Expr, e.Reason)
+	}
+	return fmt.Sprintf("papi: postfix expression %q: token %q: %s", e.Expr, e.Token, e.Reason)
+}
+
+// Parse and validate a postfix expression.  An error is returned if
+// the expression contains an unknown token, would underflow the
+// stack, or would not leave exactly one value on the stack.
+func ParsePostfix(expr string) (*Postfix, error) {
+	p := &Postfix{expr: expr}
+	depth := 0
+	for _, tok := range strings.Split(expr, "|") {
+		tok = strings.TrimSpace(tok)
+		var op postfixOp
+		switch {
+		case tok == "":
+			// PAPI ignores leading and consecutive separators.
+			continue
+		case tok == "+", tok == "-", tok == "*", tok == "/":
+			if depth < 2 {
+				return nil, &PostfixError{expr, tok, "too few operands"}
+			}
+			op.kind = tok[0]
+			depth--
+		case tok == "#":
+			op.kind, op.value = 'c', float64(GetHardwareInfo().MaxMHz)*1e6
+			depth++
+		case tok[0] == 'N':
+			// Atoi() would also accept a sign, as in "N+1".
+			digits := tok[1:]
+			k, err := strconv.Atoi(digits)
+			if err != nil || strings.Trim(digits, "0123456789") != "" {
+				return nil, &PostfixError{expr, tok, "malformed term reference"}
+			}
+			op.kind, op.term = 'N', k
+			if k >= p.numTerms {
+				p.numTerms = k + 1
+			}
+			depth++
+		case tok[0] >= '0' && tok[0] <= '9' || tok[0] == '.':
+			v, err := strconv.ParseFloat(tok, 64)
+			if err != nil {
+				return nil, &PostfixError{expr, tok, "malformed constant"}
+			}
+			op.kind, op.value = 'c', v
+			depth++
+		default:
+			return nil, &PostfixError{expr, tok, "unknown token"}
+		}
+		if depth > p.depth {
+			p.depth = depth
+		}
+		p.ops = append(p.ops, op)
+	}
+	if depth != 1 {
+		return nil, &PostfixError{expr, "", fmt.Sprintf("leaves %d values on the stack instead of 1", depth)}
+	}
+	return p, nil
+}
+
+// Return the postfix expression as a string.
+func (p *Postfix) String() string {
+	return p.expr
+}
+
+// Return the number of terms the expression requires.  Eval() must be
+// passed at least this many values.
+func (p *Postfix) NumTerms() int {
+	return p.numTerms
+}
+
+// Compute the value of a postfix expression given the values of its
+// terms.  As in PAPI, arithmetic is performed in floating point and
+// the result is truncated to an integer.  Division by zero yields
+// zero rather than an error so that a derived event reads as zero
+// when its divisor has not yet counted.  Eval() panics if given fewer
+// than NumTerms() values.
+func (p *Postfix) Eval(terms []int64) int64 {
+	var stackBuf [8]float64
+	stack := stackBuf[:0]
+	if p.depth > len(stackBuf) {
+		stack = make([]float64, 0, p.depth)
+	}
+	for _, op := range p.ops {
+		switch op.kind {
+		case 'N':
+			stack = append(stack, float64(terms[op.term]))
+		case 'c':
+			stack = append(stack, op.value)
+		default:
+			x, y := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch op.kind {
+			case '+':
+				x += y
+			case '-':
+				x -= y
+			case '*':
+				x *= y
+			case '/':
+				if y == 0 {
+					x = 0
+				} else {
+					x /= y
+				}
+			}
+			stack[len(stack)-1] = x
+		}
+	}
+	return int64(stack[0])
+}
+
+// Return a postfix expression that computes an event's value from the
+// values of the native events listed in its EventInfo.Name field,
+// based on the event's derived type.
+func (info *EventInfo) DerivedPostfix() (*Postfix, error) {
+	terms := []string{"N0"}
+	for i := 1; i < len(info.Name); i++ {
+		terms = append(terms, fmt.Sprintf("N%d", i))
+	}
+	var expr string
+	switch info.Derived {
+	case "", "NOT_DERIVED", "DERIVED_CMPD":
+		expr = "N0|"
+	case "DERIVED_ADD":
+		expr = strings.Join(terms, "|") + strings.Repeat("|+", len(terms)-1) + "|"
+	case "DERIVED_SUB":
+		expr = "N0|"
+		for _, t := range terms[1:] {
+			expr += t + "|-|"
+		}
+	case "DERIVED_PS":
+		expr = "N1|#|*|N0|/|"
+	case "DERIVED_POSTFIX":
+		expr = info.Postfix
+	default:
+		return nil, &PostfixError{info.Postfix, info.Derived, "unsupported derived type"}
+	}
+	return ParsePostfix(expr)
+}
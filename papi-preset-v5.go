@@ -0,0 +1,118 @@
+// Code generated by genconsts from papiStdEventDefs.h (PAPI 5.7.0.0); DO NOT EDIT.
+
+//go:build papi5
+
+package papi
+
+// presets describes each preset event, indexed by the low bits of
+// its event code.
+var presets = [...]presetInfo{
+	0x00: {"PAPI_L1_DCM", "L1D cache misses", "Level 1 data cache misses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x01: {"PAPI_L1_ICM", "L1I cache misses", "Level 1 instruction cache misses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x02: {"PAPI_L2_DCM", "L2D cache misses", "Level 2 data cache misses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x03: {"PAPI_L2_ICM", "L2I cache misses", "Level 2 instruction cache misses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x04: {"PAPI_L3_DCM", "L3D cache misses", "Level 3 data cache misses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x05: {"PAPI_L3_ICM", "L3I cache misses", "Level 3 instruction cache misses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x06: {"PAPI_L1_TCM", "L1 cache misses", "Level 1 total cache misses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x07: {"PAPI_L2_TCM", "L2 cache misses", "Level 2 total cache misses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x08: {"PAPI_L3_TCM", "L3 cache misses", "Level 3 total cache misses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x09: {"PAPI_CA_SNP", "Snoop Requests", "Snoops", PRESET_BIT_CACH},
+	0x0a: {"PAPI_CA_SHR", "Ex Acces shared CL", "Request for shared cache line (SMP)", PRESET_BIT_CACH},
+	0x0b: {"PAPI_CA_CLN", "Ex Access clean CL", "Request for clean cache line (SMP)", PRESET_BIT_CACH},
+	0x0c: {"PAPI_CA_INV", "Cache ln invalid", "Request for cache line Invalidation (SMP)", PRESET_BIT_CACH},
+	0x0d: {"PAPI_CA_ITV", "Cache ln intervene", "Request for cache line Intervention (SMP)", PRESET_BIT_CACH},
+	0x0e: {"PAPI_L3_LDM", "L3 load misses", "Level 3 load misses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x0f: {"PAPI_L3_STM", "L3 store misses", "Level 3 store misses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x10: {"PAPI_BRU_IDL", "Branch idle cycles", "Cycles branch units are idle", PRESET_BIT_IDL | PRESET_BIT_BR},
+	0x11: {"PAPI_FXU_IDL", "IU idle cycles", "Cycles integer units are idle", PRESET_BIT_IDL},
+	0x12: {"PAPI_FPU_IDL", "FPU idle cycles", "Cycles floating point units are idle", PRESET_BIT_IDL | PRESET_BIT_FP},
+	0x13: {"PAPI_LSU_IDL", "L/SU idle cycles", "Cycles load/store units are idle", PRESET_BIT_IDL | PRESET_BIT_MEM},
+	0x14: {"PAPI_TLB_DM", "Data TLB misses", "Data translation lookaside buffer misses", PRESET_BIT_TLB},
+	0x15: {"PAPI_TLB_IM", "Instr TLB misses", "Instr translation lookaside buffer misses", PRESET_BIT_TLB},
+	0x16: {"PAPI_TLB_TL", "Total TLB misses", "Total translation lookaside buffer misses", PRESET_BIT_TLB},
+	0x17: {"PAPI_L1_LDM", "L1 load misses", "Level 1 load misses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x18: {"PAPI_L1_STM", "L1 store misses", "Level 1 store misses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x19: {"PAPI_L2_LDM", "L2 load misses", "Level 2 load misses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x1a: {"PAPI_L2_STM", "L2 store misses", "Level 2 store misses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x1b: {"PAPI_BTAC_M", "Br targt addr miss", "BTAC miss", PRESET_BIT_BR},
+	0x1c: {"PAPI_PRF_DM", "Data prefetch miss", "Prefetch data instruction caused a miss", PRESET_BIT_CACH},
+	0x1d: {"PAPI_L3_DCH", "L3D cache hits", "Level 3 Data Cache Hit", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x1e: {"PAPI_TLB_SD", "TLB shootdowns", "Xlation lookaside buffer shootdowns (SMP)", PRESET_BIT_TLB},
+	0x1f: {"PAPI_CSR_FAL", "Failed store cond", "Failed store conditional instructions", PRESET_BIT_CND | PRESET_BIT_MEM},
+	0x20: {"PAPI_CSR_SUC", "Good store cond", "Successful store conditional instructions", PRESET_BIT_CND | PRESET_BIT_MEM},
+	0x21: {"PAPI_CSR_TOT", "Total store cond", "Total store conditional instructions", PRESET_BIT_CND | PRESET_BIT_MEM},
+	0x22: {"PAPI_MEM_SCY", "Stalled mem cycles", "Cycles Stalled Waiting for Memory Access", PRESET_BIT_IDL | PRESET_BIT_MEM},
+	0x23: {"PAPI_MEM_RCY", "Stalled rd cycles", "Cycles Stalled Waiting for Memory Read", PRESET_BIT_IDL | PRESET_BIT_MEM},
+	0x24: {"PAPI_MEM_WCY", "Stalled wr cycles", "Cycles Stalled Waiting for Memory Write", PRESET_BIT_IDL | PRESET_BIT_MEM},
+	0x25: {"PAPI_STL_ICY", "No instr issue", "Cycles with No Instruction Issue", PRESET_BIT_INS | PRESET_BIT_IDL},
+	0x26: {"PAPI_FUL_ICY", "Max instr issue", "Cycles with Maximum Instruction Issue", PRESET_BIT_INS},
+	0x27: {"PAPI_STL_CCY", "No instr done", "Cycles with No Instruction Completion", PRESET_BIT_INS | PRESET_BIT_IDL},
+	0x28: {"PAPI_FUL_CCY", "Max instr done", "Cycles with Maximum Instruction Completion", PRESET_BIT_INS},
+	0x29: {"PAPI_HW_INT", "Hdw interrupts", "Hardware interrupts", PRESET_BIT_MSC},
+	0x2a: {"PAPI_BR_UCN", "Uncond branch", "Unconditional branch instructions executed", PRESET_BIT_BR},
+	0x2b: {"PAPI_BR_CN", "Cond branch", "Conditional branch instructions executed", PRESET_BIT_BR | PRESET_BIT_CND},
+	0x2c: {"PAPI_BR_TKN", "Cond branch taken", "Conditional branch instructions taken", PRESET_BIT_BR | PRESET_BIT_CND},
+	0x2d: {"PAPI_BR_NTK", "Cond br not taken", "Conditional branch instructions not taken", PRESET_BIT_BR | PRESET_BIT_CND},
+	0x2e: {"PAPI_BR_MSP", "Cond br mspredictd", "Conditional branch instructions mispred", PRESET_BIT_BR | PRESET_BIT_CND},
+	0x2f: {"PAPI_BR_PRC", "Cond br predicted", "Conditional branch instructions corr. pred", PRESET_BIT_BR | PRESET_BIT_CND},
+	0x30: {"PAPI_FMA_INS", "FMAs completed", "FMA instructions completed", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x31: {"PAPI_TOT_IIS", "Instr issued", "Total instructions issued", PRESET_BIT_INS},
+	0x32: {"PAPI_TOT_INS", "Instr completed", "Total instructions executed", PRESET_BIT_INS},
+	0x33: {"PAPI_INT_INS", "Int instructions", "Integer instructions executed", PRESET_BIT_INS},
+	0x34: {"PAPI_FP_INS", "FP instructions", "Floating point instructions executed", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x35: {"PAPI_LD_INS", "Loads", "Load instructions executed", PRESET_BIT_INS | PRESET_BIT_MEM},
+	0x36: {"PAPI_SR_INS", "Stores", "Store instructions executed", PRESET_BIT_INS | PRESET_BIT_MEM},
+	0x37: {"PAPI_BR_INS", "Branches", "Total branch instructions executed", PRESET_BIT_INS | PRESET_BIT_BR},
+	0x38: {"PAPI_VEC_INS", "Vector/SIMD instr", "Vector/SIMD instructions executed (could include integer)", PRESET_BIT_INS},
+	0x39: {"PAPI_RES_STL", "Stalled res cycles", "Cycles processor is stalled on resource", PRESET_BIT_IDL},
+	0x3a: {"PAPI_FP_STAL", "Stalled FPU cycles", "Cycles any FP units are stalled", PRESET_BIT_IDL | PRESET_BIT_FP},
+	0x3b: {"PAPI_TOT_CYC", "Total cycles", "Total cycles executed", PRESET_BIT_MSC},
+	0x3c: {"PAPI_LST_INS", "L/S completed", "Total load/store inst. executed", PRESET_BIT_INS | PRESET_BIT_MEM},
+	0x3d: {"PAPI_SYC_INS", "Syncs completed", "Sync. inst. executed", PRESET_BIT_INS | PRESET_BIT_MEM},
+	0x3e: {"PAPI_L1_DCH", "L1D cache hits", "L1 D Cache Hit", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x3f: {"PAPI_L2_DCH", "L2D cache hits", "L2 D Cache Hit", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x40: {"PAPI_L1_DCA", "L1D cache accesses", "L1 D Cache Access", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x41: {"PAPI_L2_DCA", "L2D cache accesses", "L2 D Cache Access", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x42: {"PAPI_L3_DCA", "L3D cache accesses", "L3 D Cache Access", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x43: {"PAPI_L1_DCR", "L1D cache reads", "L1 D Cache Read", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x44: {"PAPI_L2_DCR", "L2D cache reads", "L2 D Cache Read", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x45: {"PAPI_L3_DCR", "L3D cache reads", "L3 D Cache Read", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x46: {"PAPI_L1_DCW", "L1D cache writes", "L1 D Cache Write", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x47: {"PAPI_L2_DCW", "L2D cache writes", "L2 D Cache Write", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x48: {"PAPI_L3_DCW", "L3D cache writes", "L3 D Cache Write", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x49: {"PAPI_L1_ICH", "L1I cache hits", "L1 instruction cache hits", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x4a: {"PAPI_L2_ICH", "L2I cache hits", "L2 instruction cache hits", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x4b: {"PAPI_L3_ICH", "L3I cache hits", "L3 instruction cache hits", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x4c: {"PAPI_L1_ICA", "L1I cache accesses", "L1 instruction cache accesses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x4d: {"PAPI_L2_ICA", "L2I cache accesses", "L2 instruction cache accesses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x4e: {"PAPI_L3_ICA", "L3I cache accesses", "L3 instruction cache accesses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x4f: {"PAPI_L1_ICR", "L1I cache reads", "L1 instruction cache reads", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x50: {"PAPI_L2_ICR", "L2I cache reads", "L2 instruction cache reads", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x51: {"PAPI_L3_ICR", "L3I cache reads", "L3 instruction cache reads", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x52: {"PAPI_L1_ICW", "L1I cache writes", "L1 instruction cache writes", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x53: {"PAPI_L2_ICW", "L2I cache writes", "L2 instruction cache writes", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x54: {"PAPI_L3_ICW", "L3I cache writes", "L3 instruction cache writes", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x55: {"PAPI_L1_TCH", "L1 cache hits", "L1 total cache hits", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x56: {"PAPI_L2_TCH", "L2 cache hits", "L2 total cache hits", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x57: {"PAPI_L3_TCH", "L3 cache hits", "L3 total cache hits", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x58: {"PAPI_L1_TCA", "L1 cache accesses", "L1 total cache accesses", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x59: {"PAPI_L2_TCA", "L2 cache accesses", "L2 total cache accesses", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x5a: {"PAPI_L3_TCA", "L3 cache accesses", "L3 total cache accesses", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x5b: {"PAPI_L1_TCR", "L1 cache reads", "L1 total cache reads", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x5c: {"PAPI_L2_TCR", "L2 cache reads", "L2 total cache reads", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x5d: {"PAPI_L3_TCR", "L3 cache reads", "L3 total cache reads", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x5e: {"PAPI_L1_TCW", "L1 cache writes", "L1 total cache writes", PRESET_BIT_CACH | PRESET_BIT_L1},
+	0x5f: {"PAPI_L2_TCW", "L2 cache writes", "L2 total cache writes", PRESET_BIT_CACH | PRESET_BIT_L2},
+	0x60: {"PAPI_L3_TCW", "L3 cache writes", "L3 total cache writes", PRESET_BIT_CACH | PRESET_BIT_L3},
+	0x61: {"PAPI_FML_INS", "FPU multiply", "FM ins", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x62: {"PAPI_FAD_INS", "FPU add", "FA ins", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x63: {"PAPI_FDV_INS", "FPU divide", "FD ins", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x64: {"PAPI_FSQ_INS", "FPU square root", "FSq ins", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x65: {"PAPI_FNV_INS", "FPU inverse", "Finv ins", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x66: {"PAPI_FP_OPS", "FP operations", "Floating point operations executed", PRESET_BIT_FP},
+	0x67: {"PAPI_SP_OPS", "SP operations", "Floating point operations executed; optimized to count scaled single precision vector operations", PRESET_BIT_FP},
+	0x68: {"PAPI_DP_OPS", "DP operations", "Floating point operations executed; optimized to count scaled double precision vector operations", PRESET_BIT_FP},
+	0x69: {"PAPI_VEC_SP", "SP Vector/SIMD instr", "Single precision vector/SIMD instructions", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x6a: {"PAPI_VEC_DP", "DP Vector/SIMD instr", "Double precision vector/SIMD instructions", PRESET_BIT_INS | PRESET_BIT_FP},
+	0x6b: {"PAPI_REF_CYC", "Reference cycles", "Reference clock cycles", PRESET_BIT_MSC},
+}
@@ -0,0 +1,97 @@
+// This file describes PAPI's preset events without calling into the
+// PAPI library.  The descriptions themselves are generated from
+// papiStdEventDefs.h into papi-preset-v*.go.
+
+package papi
+
+// A presetInfo statically describes a single preset event.
+type presetInfo struct {
+	symbol     string        // Name of the event, including the "PAPI_" prefix
+	short      string        // Description suitable for use as a label
+	long       string        // Longer description of the event
+	categories EventModifier // Bitwise-or of PRESET_BIT_* values
+}
+
+// Map each preset event name to its event code.  The map is built by
+// its initializer rather than by init() so that other package-level
+// initializers can parse event names.
+var presetsByName = func() map[string]Event {
+	byName := make(map[string]Event, len(presets))
+	for i, p := range presets {
+		if p.symbol != "" {
+			byName[p.symbol] = Event(PRESET_MASK) | Event(i)
+		}
+	}
+	return byName
+}()
+
+// Return true if an event code denotes a preset event.  Native events
+// and user-defined events, whether defined by PAPI or with
+// DefineUserEvent(), are not presets.
+func (ecode Event) IsPreset() bool {
+	return uint32(ecode)&0xc0000000 == 0x80000000
+}
+
+// Return the static description of a preset event or nil if the event
+// is not a preset known to this package.
+func lookupPreset(ecode Event) *presetInfo {
+	if !ecode.IsPreset() {
+		return nil
+	}
+	idx := int(ecode & 0x3fffffff)
+	if idx >= len(presets) || presets[idx].symbol == "" {
+		return nil
+	}
+	return &presets[idx]
+}
+
+// Convert an event name to a PAPI event code.  Preset event names
+// (e.g., "PAPI_TOT_INS") and the names of user-defined events are
+// resolved without calling PAPI; all other names, including those of
+// native events, are looked up by PAPI.
+func ParseEvent(ename string) (Event, error) {
+	if ecode, ok := presetsByName[ename]; ok {
+		return ecode, nil
+	}
+	if ecode, ok := lookupUserEventName(ename); ok {
+		return ecode, nil
+	}
+	return current().eventNameToCode(ename)
+}
+
+// Return a description of an event, typically a sentence in length.
+// Descriptions of preset events are available without calling PAPI.
+// An empty string is returned if PAPI cannot describe the event.
+func (ecode Event) Description() string {
+	if p := lookupPreset(ecode); p != nil {
+		return p.long
+	}
+	info, err := GetEventInfo(ecode)
+	if err != nil {
+		return ""
+	}
+	return info.LongDescr
+}
+
+// Return a description of an event that is suitable for use as a
+// label.  PAPI typically provides such descriptions only for preset
+// events, which are available without calling PAPI.
+func (ecode Event) ShortDescription() string {
+	if p := lookupPreset(ecode); p != nil {
+		return p.short
+	}
+	info, err := GetEventInfo(ecode)
+	if err != nil {
+		return ""
+	}
+	return info.ShortDescr
+}
+
+// Return the bitwise-or of the PRESET_BIT_* categories to which a
+// preset event belongs or 0 for a native event.
+func (ecode Event) Categories() EventModifier {
+	if p := lookupPreset(ecode); p != nil {
+		return p.categories
+	}
+	return 0
+}
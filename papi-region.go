@@ -0,0 +1,351 @@
+// This file provides a region-based measurement interface similar to
+// the PAPI_hl_region_begin()/PAPI_hl_region_end() high-level
+// functions introduced in PAPI 6.
+
+package papi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// RegionEventsEnv names the environment variable that selects the
+// events counted by regions.  Its value is a comma-separated list of
+// preset or native event names, as in PAPI's own PAPI_EVENTS.
+const RegionEventsEnv = "PAPI_EVENTS"
+
+// DefaultRegionEvents lists the events counted by regions if neither
+// SetRegionEvents() nor the RegionEventsEnv environment variable
+// specifies otherwise.
+var DefaultRegionEvents = []Event{TOT_INS, TOT_CYC}
+
+// A Region is a single, dynamic instance of a named section of code
+// being measured.  Regions are created by Begin() and completed by
+// End().
+type Region struct {
+	name      string        // Name of the region
+	thread    *regionThread // Per-thread state of the thread that began the region
+	events    []Event       // Events counted
+	start     []int64       // Counter values at Begin()
+	inclusive []int64       // Inclusive counts, once ended
+	startUsec int64         // Real time at Begin()
+	depth     int           // Number of enclosing regions at Begin()
+	nested    []int64       // Inclusive counts of directly nested regions
+	nestUsec  int64         // Inclusive real time of directly nested regions
+	ended     bool          // true once the region has been ended
+	err       error         // Error encountered in Begin()
+}
+
+// RegionStats aggregates the measurements of every instance of a
+// region with a given name.
+type RegionStats struct {
+	Name          string  // Name of the region
+	Events        []Event // Events counted
+	Count         int     // Number of times the region completed
+	Inclusive     []int64 // Counts of each event, including nested regions
+	Exclusive     []int64 // Counts of each event, excluding nested regions
+	InclusiveUsec int64   // Real time in microseconds, including nested regions
+	ExclusiveUsec int64   // Real time in microseconds, excluding nested regions
+}
+
+// A RegionSpan records a single completed instance of a region, as
+// retained by KeepRegionSpans().
+type RegionSpan struct {
+	Name      string  // Name of the region
+	Thread    uint64  // PAPI identifier of the thread on which the region ran
+	Depth     int     // Number of enclosing regions
+	StartUsec int64   // Real time in microseconds at Begin()
+	EndUsec   int64   // Real time in microseconds at End()
+	Events    []Event // Events counted
+	Inclusive []int64 // Counts of each event, including nested regions
+}
+
+// A regionThread is the region state associated with a single OS
+// thread.
+type regionThread struct {
+	id     uint64    // OS thread identifier
+	events EventSet  // Event set counting on this thread
+	stack  []*Region // Currently open regions, innermost last
+}
+
+// All state shared across regions.
+var regions struct {
+	sync.Mutex
+	events  []Event                  // Events counted in every region
+	err     error                    // Error encountered selecting events
+	threads map[uint64]*regionThread // Per-thread state
+	stats   map[string]*RegionStats  // Aggregate statistics per region name
+	order   []string                 // Region names in order of first completion
+	spans   []RegionSpan             // Most recently completed region instances
+	keep    int                      // Maximum number of spans to retain
+}
+
+// errRegionOrder is returned when regions are ended out of order or
+// from the wrong goroutine.
+var errRegionOrder = errors.New("papi: regions must be ended by the same goroutine, in the reverse order in which they began")
+
+// Specify the events to count in every region.  SetRegionEvents()
+// takes precedence over the RegionEventsEnv environment variable but
+// must be called before the first call to Begin().
+func SetRegionEvents(ecodes []Event) error {
+	regions.Lock()
+	defer regions.Unlock()
+	if regions.threads != nil {
+		return errors.New("papi: SetRegionEvents() called after Begin()")
+	}
+	regions.events = append([]Event(nil), ecodes...)
+	return nil
+}
+
+// Determine the set of events to count if that hasn't already been
+// done.  The caller must hold the regions lock.
+func initRegionsLocked() {
+	if regions.threads != nil {
+		return
+	}
+	regions.threads = make(map[uint64]*regionThread)
+	regions.stats = make(map[string]*RegionStats)
+	if regions.events != nil {
+		return
+	}
+	envEvents := os.Getenv(RegionEventsEnv)
+	if envEvents == "" {
+		regions.events = DefaultRegionEvents
+		return
+	}
+	for _, ename := range strings.Split(envEvents, ",") {
+		ename = strings.TrimSpace(ename)
+		if ename == "" {
+			continue
+		}
+		ecode, err := StringToEvent(ename)
+		if err != nil {
+			regions.err = fmt.Errorf("papi: %s: %s: %v", RegionEventsEnv, ename, err)
+			return
+		}
+		regions.events = append(regions.events, ecode)
+	}
+}
+
+// Return the region state for the calling thread, creating it and
+// starting its event set if necessary.
+func currentRegionThread() (*regionThread, error) {
+	regions.Lock()
+	defer regions.Unlock()
+	initRegionsLocked()
+	if regions.err != nil {
+		return nil, regions.err
+	}
+	id := threadID()
+	if t, ok := regions.threads[id]; ok {
+		return t, nil
+	}
+	events, err := CreateEventSet()
+	if err != nil {
+		return nil, err
+	}
+	if err = events.AddEvents(regions.events); err != nil {
+		return nil, err
+	}
+	if err = events.Start(); err != nil {
+		return nil, err
+	}
+	t := &regionThread{id: id, events: events}
+	regions.threads[id] = t
+	return t, nil
+}
+
+// Begin measuring a named region of code.  Regions can be nested, and
+// each region is attributed only to the goroutine that began it; the
+// goroutine is locked to its OS thread until the region ends.  Work
+// performed by other goroutines, including those started within the
+// region, is not counted.  Errors are reported by Region.End().
+func Begin(name string) *Region {
+	runtime.LockOSThread()
+	r := &Region{name: name}
+	if r.thread, r.err = currentRegionThread(); r.err != nil {
+		return r
+	}
+	r.events = regions.events
+	numEvents := len(r.events)
+	r.start = make([]int64, numEvents)
+	r.nested = make([]int64, numEvents)
+	if r.err = r.thread.events.Read(r.start); r.err != nil {
+		return r
+	}
+	r.startUsec = GetRealUsec()
+	r.depth = len(r.thread.stack)
+	r.thread.stack = append(r.thread.stack, r)
+	return r
+}
+
+// End measuring a region and add its counts to the statistics for
+// all regions of the same name.  The innermost region must be ended
+// first; attempting to end any other region returns an error and
+// leaves that region open.
+func (r *Region) End() (err error) {
+	if r.ended {
+		return errRegionOrder
+	}
+	if r.err != nil {
+		r.ended = true
+		runtime.UnlockOSThread()
+		return r.err
+	}
+
+	// Ensure that the region is the innermost one on this thread.
+	t := r.thread
+	if t.id != threadID() || t.stack[len(t.stack)-1] != r {
+		return errRegionOrder
+	}
+	t.stack = t.stack[:len(t.stack)-1]
+	r.ended = true
+	defer runtime.UnlockOSThread()
+
+	// Compute inclusive and exclusive counts.
+	now := make([]int64, len(r.start))
+	if err = t.events.Read(now); err != nil {
+		return
+	}
+	endUsec := GetRealUsec()
+	usec := endUsec - r.startUsec
+	incl := make([]int64, len(now))
+	excl := make([]int64, len(now))
+	for i := range now {
+		incl[i] = now[i] - r.start[i]
+		excl[i] = incl[i] - r.nested[i]
+	}
+	r.inclusive = incl
+	if len(t.stack) > 0 {
+		parent := t.stack[len(t.stack)-1]
+		for i := range incl {
+			parent.nested[i] += incl[i]
+		}
+		parent.nestUsec += usec
+	}
+
+	// Aggregate the counts by region name.
+	regions.Lock()
+	defer regions.Unlock()
+	stats, ok := regions.stats[r.name]
+	if !ok {
+		stats = &RegionStats{
+			Name:      r.name,
+			Events:    regions.events,
+			Inclusive: make([]int64, len(incl)),
+			Exclusive: make([]int64, len(excl)),
+		}
+		regions.stats[r.name] = stats
+		regions.order = append(regions.order, r.name)
+	}
+	stats.Count++
+	for i := range incl {
+		stats.Inclusive[i] += incl[i]
+		stats.Exclusive[i] += excl[i]
+	}
+	stats.InclusiveUsec += usec
+	stats.ExclusiveUsec += usec - r.nestUsec
+	if regions.keep > 0 {
+		if len(regions.spans) == regions.keep {
+			regions.spans = regions.spans[1:]
+		}
+		regions.spans = append(regions.spans, RegionSpan{
+			Name:      r.name,
+			Thread:    t.id,
+			Depth:     r.depth,
+			StartUsec: r.startUsec,
+			EndUsec:   endUsec,
+			Events:    r.events,
+			Inclusive: incl,
+		})
+	}
+	return
+}
+
+// Return the events counted by a region that has ended successfully
+// and their counts, including those of nested regions.  Counts()
+// returns nil slices if the region has not ended or could not be
+// measured.
+func (r *Region) Counts() (events []Event, counts []int64) {
+	if r.inclusive == nil {
+		return nil, nil
+	}
+	return append([]Event(nil), r.events...), append([]int64(nil), r.inclusive...)
+}
+
+// Return aggregate statistics for every region that has completed,
+// in the order in which each region name first completed.
+func RegionReport() []RegionStats {
+	regions.Lock()
+	defer regions.Unlock()
+	report := make([]RegionStats, len(regions.order))
+	for i, name := range regions.order {
+		stats := *regions.stats[name]
+		stats.Events = append([]Event(nil), stats.Events...)
+		stats.Inclusive = append([]int64(nil), stats.Inclusive...)
+		stats.Exclusive = append([]int64(nil), stats.Exclusive...)
+		report[i] = stats
+	}
+	return report
+}
+
+// Retain the n most recently completed region instances so that
+// RegionSpans() can return them, as when exporting a timeline.  Calling
+// KeepRegionSpans() with n <= 0 discards any retained instances and
+// stops retaining them.
+func KeepRegionSpans(n int) {
+	regions.Lock()
+	defer regions.Unlock()
+	if n <= 0 {
+		regions.spans = nil
+		n = 0
+	} else if len(regions.spans) > n {
+		regions.spans = regions.spans[len(regions.spans)-n:]
+	}
+	regions.keep = n
+}
+
+// Return the retained region instances in the order in which they
+// completed.
+func RegionSpans() []RegionSpan {
+	regions.Lock()
+	defer regions.Unlock()
+	spans := make([]RegionSpan, len(regions.spans))
+	for i, sp := range regions.spans {
+		sp.Events = append([]Event(nil), sp.Events...)
+		sp.Inclusive = append([]int64(nil), sp.Inclusive...)
+		spans[i] = sp
+	}
+	return spans
+}
+
+// Write a table of RegionReport()'s results to a stream.  Because Go
+// provides no hook for running code at program exit, programs that
+// want a report at exit should defer a call to WriteRegionReport()
+// from main().
+func WriteRegionReport(w io.Writer) error {
+	report := RegionReport()
+	if len(report) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "Region\tCount\tIncl. usec\tExcl. usec\t")
+	for _, ev := range report[0].Events {
+		fmt.Fprintf(tw, "Incl. %s\tExcl. %s\t", ev, ev)
+	}
+	fmt.Fprintln(tw)
+	for _, stats := range report {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t", stats.Name, stats.Count, stats.InclusiveUsec, stats.ExclusiveUsec)
+		for i := range stats.Events {
+			fmt.Fprintf(tw, "%d\t%d\t", stats.Inclusive[i], stats.Exclusive[i])
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
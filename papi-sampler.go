@@ -0,0 +1,288 @@
+// This file provides a sampler that reads counters at a fixed interval
+// to produce a time series of counts rather than a single total.
+
+package papi
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SamplerConfig specifies what a Sampler counts and how it delivers
+// samples.  Either CPUs or PID must be specified.
+//
+// PID names a single thread, as PAPI attaches to one kernel task.  A
+// process ID therefore counts only the process's main thread, and
+// Inherit extends counting only to the threads and processes created
+// after sampling starts.  Because a running Go program already has
+// several threads, counting all of a Go program's work requires
+// either sampling CPUs or attaching to a process that has not yet
+// started running (e.g., one stopped under ptrace).
+type SamplerConfig struct {
+	Interval time.Duration // Time between samples (default 100ms)
+	CPUs     []int         // CPUs to count, each with its own event set
+	PID      int           // Thread to count if CPUs is empty (see above)
+	Inherit  bool          // Also count the threads and processes that PID subsequently creates
+	Capacity int           // Number of samples the channel or ring buffer holds (default 1024)
+	Ring     bool          // Retain the most recent samples in a ring buffer instead of sending them on a channel
+}
+
+// A Sample holds the counts read from a single event set at the end of
+// a sampling interval.
+type Sample struct {
+	Seq       int       // Number of the interval, starting from 1
+	CPU       int       // CPU counted, or -1 for a process or thread
+	Cyc       int64     // Real-time cycle count at which the counters were read
+	Usec      int64     // Real time in microseconds at which the counters were read
+	StartUsec int64     // Real time in microseconds at which the interval began
+	Values    []int64   // Cumulative count of each event since sampling began
+	Deltas    []int64   // Change in each count over the interval
+	Rates     []float64 // Change in each count per second over the interval
+	Err       error     // Error encountered reading the counters, in which case Values, Deltas, and Rates are nil
+}
+
+// A Sampler reads a set of counters at a fixed interval from a
+// dedicated, locked OS thread.  Samples are delivered either on a
+// channel or into a ring buffer of bounded size.  In both cases,
+// samples that cannot be delivered are dropped rather than delaying
+// subsequent samples.
+type Sampler struct {
+	C <-chan Sample // Samples, in order, unless SamplerConfig.Ring is set; closed by Stop()
+
+	events []Event       // Events counted
+	cfg    SamplerConfig // Configuration with defaults filled in
+	c      chan Sample   // Bidirectional version of C
+	quit   chan struct{} // Closed to request that sampling stop
+	done   chan error    // Receives the result of stopping the event sets
+	once   sync.Once     // Ensures that Stop() acts only once
+	err    error         // Result of Stop()
+	mu     sync.Mutex    // Protects the fields below
+	ring   []Sample      // Ring buffer of samples, if SamplerConfig.Ring is set
+	next   int           // Index into ring at which to store the next sample
+	count  int           // Number of samples stored in ring
+	drops  int           // Number of samples dropped or overwritten
+}
+
+// A samplerTarget is the event set that counts a single CPU or process.
+type samplerTarget struct {
+	es   EventSet // Event set, which is running
+	cpu  int      // CPU counted, or -1 for a process or thread
+	prev []int64  // Values at the end of the previous interval
+	usec int64    // Real time at the end of the previous interval
+}
+
+// Begin sampling a set of events, all of which must be provided by the
+// same component, on each of the CPUs or in the process given by a
+// configuration.  Counting starts before NewSampler() returns.
+func NewSampler(events []Event, config *SamplerConfig) (*Sampler, error) {
+	var cfg SamplerConfig
+	if config != nil {
+		cfg = *config
+	}
+	if len(events) == 0 {
+		return nil, errors.New("papi: a sampler requires at least one event")
+	}
+	if len(cfg.CPUs) == 0 && cfg.PID <= 0 {
+		return nil, errors.New("papi: a sampler requires CPUs or a PID to count")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 1024
+	}
+	s := &Sampler{
+		events: append([]Event(nil), events...),
+		cfg:    cfg,
+		quit:   make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+	if cfg.Ring {
+		s.ring = make([]Sample, cfg.Capacity)
+	} else {
+		s.c = make(chan Sample, cfg.Capacity)
+		s.C = s.c
+	}
+	ready := make(chan error)
+	go s.run(ready)
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create and start an event set for each CPU or for the process.  The
+// caller must be locked to its OS thread.
+func (s *Sampler) open() ([]*samplerTarget, error) {
+	cidx, err := GetEventComponent(s.events[0])
+	if err != nil {
+		return nil, err
+	}
+	cpus := s.cfg.CPUs
+	if len(cpus) == 0 {
+		cpus = []int{-1}
+	}
+	var targets []*samplerTarget
+	for _, cpu := range cpus {
+		es, err := CreateEventSet()
+		if err != nil {
+			closeTargets(targets)
+			return nil, err
+		}
+		targets = append(targets, &samplerTarget{es: es, cpu: cpu, prev: make([]int64, len(s.events))})
+		if err = es.AssignComponent(cidx); err == nil {
+			if cpu >= 0 {
+				err = es.AttachCPU(cpu)
+			} else if err = es.Attach(s.cfg.PID); err == nil && s.cfg.Inherit {
+				err = es.SetInherit()
+			}
+		}
+		if err == nil {
+			err = es.AddEvents(s.events)
+		}
+		if err != nil {
+			closeTargets(targets)
+			return nil, err
+		}
+	}
+	for _, t := range targets {
+		if err := t.es.Start(); err != nil {
+			closeTargets(targets)
+			return nil, err
+		}
+		t.usec = GetRealUsec()
+	}
+	return targets, nil
+}
+
+// Stop and release every target's event set and return the first
+// error encountered, ignoring event sets that were never started.
+func closeTargets(targets []*samplerTarget) error {
+	var firstErr error
+	for _, t := range targets {
+		values := make([]int64, len(t.prev))
+		if err := t.es.Stop(values); err != nil && firstErr == nil && err != ENOTRUN {
+			firstErr = err
+		}
+		t.es.CleanupEventSet()
+		t.es.DestroyEventSet()
+	}
+	return firstErr
+}
+
+// Sample at every interval until asked to stop, then take a final
+// sample covering the partial interval and stop counting.
+func (s *Sampler) run(ready chan<- error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	targets, err := s.open()
+	ready <- err
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for seq := 1; ; seq++ {
+		select {
+		case <-ticker.C:
+			s.sample(targets, seq)
+		case <-s.quit:
+			s.sample(targets, seq)
+			s.done <- closeTargets(targets)
+			return
+		}
+	}
+}
+
+// Read every target and deliver the resulting samples.
+func (s *Sampler) sample(targets []*samplerTarget, seq int) {
+	for _, t := range targets {
+		smp := Sample{Seq: seq, CPU: t.cpu}
+		values := make([]int64, len(s.events))
+		smp.Cyc, smp.Err = t.es.ReadTS(values)
+		smp.Usec = GetRealUsec()
+		smp.StartUsec = t.usec
+		if smp.Err == nil {
+			smp.Values = values
+			smp.Deltas = make([]int64, len(values))
+			smp.Rates = make([]float64, len(values))
+			secs := float64(smp.Usec-t.usec) / 1e6
+			for i, v := range values {
+				smp.Deltas[i] = v - t.prev[i]
+				if secs > 0 {
+					smp.Rates[i] = float64(smp.Deltas[i]) / secs
+				}
+			}
+			t.prev = values
+			t.usec = smp.Usec
+		}
+		s.deliver(smp)
+	}
+}
+
+// Send a sample on the channel or store it in the ring buffer.
+func (s *Sampler) deliver(smp Sample) {
+	if s.ring == nil {
+		select {
+		case s.c <- smp:
+		default:
+			s.mu.Lock()
+			s.drops++
+			s.mu.Unlock()
+		}
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == len(s.ring) {
+		s.drops++
+	} else {
+		s.count++
+	}
+	s.ring[s.next] = smp
+	s.next = (s.next + 1) % len(s.ring)
+}
+
+// Return the events being sampled.
+func (s *Sampler) Events() []Event {
+	return append([]Event(nil), s.events...)
+}
+
+// Return the samples currently held in the ring buffer, oldest first,
+// or nil if the sampler delivers samples on a channel.
+func (s *Sampler) Samples() []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ring == nil {
+		return nil
+	}
+	smps := make([]Sample, 0, s.count)
+	for i := 0; i < s.count; i++ {
+		smps = append(smps, s.ring[(s.next-s.count+i+len(s.ring))%len(s.ring)])
+	}
+	return smps
+}
+
+// Return the number of samples that were dropped because the channel
+// was full or that were overwritten in the ring buffer.
+func (s *Sampler) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drops
+}
+
+// Take a final sample, stop counting, and close the channel, if any.
+// Stop() returns the first error encountered stopping the event sets;
+// subsequent calls return the same error.
+func (s *Sampler) Stop() error {
+	s.once.Do(func() {
+		close(s.quit)
+		s.err = <-s.done
+		if s.c != nil {
+			close(s.c)
+		}
+	})
+	return s.err
+}
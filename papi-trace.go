@@ -0,0 +1,754 @@
+// This file supports recording PAPI calls to a trace and later
+// replaying that trace in place of the PAPI library.  A trace
+// captured on a real system can then drive deterministic tests of
+// code that consumes PAPI measurements.
+
+package papi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// traceVersion is the trace format written by Trace.Write().
+const traceVersion = 1
+
+// A Trace is a recorded sequence of PAPI calls.
+type Trace struct {
+	Version int    `json:"version"` // Trace format version
+	Calls   []Call `json:"calls"`   // Every call, in the order it was made
+}
+
+// A Call records the arguments and results of a single PAPI call.
+// Only the fields relevant to the given operation are populated.
+type Call struct {
+	Op         string         `json:"op"`                   // Name of the corresponding PAPI C function
+	EventSet   EventSet       `json:"eventset,omitempty"`   // Event set operated upon
+	Event      Event          `json:"event,omitempty"`      // Event passed in or returned
+	Events     []Event        `json:"events,omitempty"`     // Events passed in or returned
+	Name       string         `json:"name,omitempty"`       // Event or option name passed in or returned
+	Arg        int            `json:"arg,omitempty"`        // Other integer argument (component index, event mask, etc.)
+	Modifier   EventModifier  `json:"modifier,omitempty"`   // Event modifier passed to PAPI_enum_event()
+	Result     int64          `json:"result,omitempty"`     // Scalar result (handle, count, or timer value)
+	Values     []int64        `json:"values,omitempty"`     // Counter values
+	Usec       int64          `json:"usec,omitempty"`       // Real time in microseconds at which counters were accessed
+	Info       *EventInfo     `json:"info,omitempty"`       // Result of PAPI_get_event_info()
+	Hardware   *HardwareInfo  `json:"hardware,omitempty"`   // Result of PAPI_get_hardware_info()
+	Executable *ProgramInfo   `json:"executable,omitempty"` // Result of PAPI_get_executable_info()
+	DynMem     *DynMemInfo    `json:"dynmem,omitempty"`     // Result of PAPI_get_dmem_info()
+	Component  *ComponentInfo `json:"component,omitempty"`  // Result of PAPI_get_component_info()
+	Errno      Errno          `json:"errno,omitempty"`      // PAPI return code
+}
+
+// Write a trace to a stream in JSON format.
+func (t *Trace) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// Read a trace written by Trace.Write().
+func ReadTrace(r io.Reader) (*Trace, error) {
+	t := new(Trace)
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, err
+	}
+	if t.Version != traceVersion {
+		return nil, fmt.Errorf("papi: unsupported trace version %d", t.Version)
+	}
+	return t, nil
+}
+
+// Map an error returned by a backend to the Errno to record.
+func errnoOf(err error) Errno {
+	if err == nil {
+		return papi_ok
+	}
+	if errno, ok := err.(Errno); ok {
+		return errno
+	}
+	return EMISC.(Errno)
+}
+
+// Map a recorded Errno back to an error.
+func errorOf(errno Errno) error {
+	if errno == papi_ok {
+		return nil
+	}
+	return errno
+}
+
+// ----------------------------------------------------------------------
+
+// A recorder passes every request through to another backend and
+// logs it.
+type recorder struct {
+	inner backend // Backend that does the real work
+	lock  sync.Mutex
+	trace Trace
+}
+
+// Begin recording every PAPI call made through this package.
+// Recording continues until StopRecording() is called.
+func StartRecording() {
+	libLock.Lock()
+	defer libLock.Unlock()
+	lib = &recorder{inner: lib, trace: Trace{Version: traceVersion}}
+}
+
+// Stop recording PAPI calls and return everything recorded since
+// StartRecording().  StopRecording() returns nil if recording was not
+// in progress.
+func StopRecording() *Trace {
+	libLock.Lock()
+	defer libLock.Unlock()
+	rec, ok := lib.(*recorder)
+	if !ok {
+		return nil
+	}
+	lib = rec.inner
+
+	// Calls that obtained the recorder before it was uninstalled may
+	// still log to it, so return a copy of the trace.
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	return rec.trace.clone()
+}
+
+// Return a deep copy of a trace's calls and their slices.
+func (t *Trace) clone() *Trace {
+	c := &Trace{Version: t.Version, Calls: make([]Call, len(t.Calls))}
+	for i, call := range t.Calls {
+		if call.Events != nil {
+			call.Events = append([]Event(nil), call.Events...)
+		}
+		if call.Values != nil {
+			call.Values = copyValues(call.Values)
+		}
+		c.Calls[i] = call
+	}
+	return c
+}
+
+// Append a call to the trace.
+func (r *recorder) log(c Call) {
+	r.lock.Lock()
+	r.trace.Calls = append(r.trace.Calls, c)
+	r.lock.Unlock()
+}
+
+// Return a copy of a slice of counter values.
+func copyValues(values []int64) []int64 {
+	return append([]int64(nil), values...)
+}
+
+func (r *recorder) eventCodeToName(ecode Event) (string, error) {
+	ename, err := r.inner.eventCodeToName(ecode)
+	r.log(Call{Op: "PAPI_event_code_to_name", Event: ecode, Name: ename, Errno: errnoOf(err)})
+	return ename, err
+}
+
+func (r *recorder) eventNameToCode(ename string) (Event, error) {
+	ecode, err := r.inner.eventNameToCode(ename)
+	r.log(Call{Op: "PAPI_event_name_to_code", Name: ename, Event: ecode, Errno: errnoOf(err)})
+	return ecode, err
+}
+
+func (r *recorder) getEventInfo(ecode Event) (EventInfo, error) {
+	info, err := r.inner.getEventInfo(ecode)
+	c := Call{Op: "PAPI_get_event_info", Event: ecode, Errno: errnoOf(err)}
+	if err == nil {
+		c.Info = &info
+	}
+	r.log(c)
+	return info, err
+}
+
+func (r *recorder) getEventComponent(ecode Event) (int, error) {
+	idx, err := r.inner.getEventComponent(ecode)
+	r.log(Call{Op: "PAPI_get_event_component", Event: ecode, Result: int64(idx), Errno: errnoOf(err)})
+	return idx, err
+}
+
+func (r *recorder) enumEvents(emask EventMask, modifier EventModifier) ([]Event, error) {
+	matches, err := r.inner.enumEvents(emask, modifier)
+	r.log(Call{Op: "PAPI_enum_event", Arg: int(emask), Modifier: modifier, Events: matches, Errno: errnoOf(err)})
+	return matches, err
+}
+
+func (r *recorder) enumUmasks(ecode Event) ([]Event, error) {
+	matches, err := r.inner.enumUmasks(ecode)
+	r.log(Call{Op: "PAPI_enum_event", Event: ecode, Modifier: NTV_ENUM_UMASKS, Events: matches, Errno: errnoOf(err)})
+	return matches, err
+}
+
+func (r *recorder) getExecutableInfo() ProgramInfo {
+	info := r.inner.getExecutableInfo()
+	r.log(Call{Op: "PAPI_get_executable_info", Executable: &info})
+	return info
+}
+
+func (r *recorder) getHardwareInfo() HardwareInfo {
+	hw := r.inner.getHardwareInfo()
+	r.log(Call{Op: "PAPI_get_hardware_info", Hardware: &hw})
+	return hw
+}
+
+func (r *recorder) getDynMemInfo() (DynMemInfo, error) {
+	dmem, err := r.inner.getDynMemInfo()
+	r.log(Call{Op: "PAPI_get_dmem_info", DynMem: &dmem, Errno: errnoOf(err)})
+	return dmem, err
+}
+
+func (r *recorder) numComponents() int {
+	n := r.inner.numComponents()
+	r.log(Call{Op: "PAPI_num_components", Result: int64(n)})
+	return n
+}
+
+func (r *recorder) numCmpHwctrs(idx int) int {
+	n := r.inner.numCmpHwctrs(idx)
+	r.log(Call{Op: "PAPI_num_cmp_hwctrs", Arg: idx, Result: int64(n)})
+	return n
+}
+
+func (r *recorder) getComponentInfo(idx int) (ComponentInfo, error) {
+	info, err := r.inner.getComponentInfo(idx)
+	c := Call{Op: "PAPI_get_component_info", Arg: idx, Errno: errnoOf(err)}
+	if err == nil {
+		c.Component = &info
+	}
+	r.log(c)
+	return info, err
+}
+
+func (r *recorder) getRealCyc() int64 {
+	t := r.inner.getRealCyc()
+	r.log(Call{Op: "PAPI_get_real_cyc", Result: t})
+	return t
+}
+
+func (r *recorder) getRealUsec() int64 {
+	t := r.inner.getRealUsec()
+	r.log(Call{Op: "PAPI_get_real_usec", Result: t})
+	return t
+}
+
+func (r *recorder) getVirtCyc() int64 {
+	t := r.inner.getVirtCyc()
+	r.log(Call{Op: "PAPI_get_virt_cyc", Result: t})
+	return t
+}
+
+func (r *recorder) getVirtUsec() int64 {
+	t := r.inner.getVirtUsec()
+	r.log(Call{Op: "PAPI_get_virt_usec", Result: t})
+	return t
+}
+
+func (r *recorder) createEventSet() (EventSet, error) {
+	es, err := r.inner.createEventSet()
+	r.log(Call{Op: "PAPI_create_eventset", Result: int64(es), Errno: errnoOf(err)})
+	return es, err
+}
+
+func (r *recorder) addEvent(es EventSet, ecode Event) error {
+	err := r.inner.addEvent(es, ecode)
+	r.log(Call{Op: "PAPI_add_event", EventSet: es, Event: ecode, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) addEvents(es EventSet, ecodes []Event) error {
+	err := r.inner.addEvents(es, ecodes)
+	r.log(Call{Op: "PAPI_add_events", EventSet: es, Events: append([]Event(nil), ecodes...), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) removeEvent(es EventSet, ecode Event) error {
+	err := r.inner.removeEvent(es, ecode)
+	r.log(Call{Op: "PAPI_remove_event", EventSet: es, Event: ecode, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) removeEvents(es EventSet, ecodes []Event) error {
+	err := r.inner.removeEvents(es, ecodes)
+	r.log(Call{Op: "PAPI_remove_events", EventSet: es, Events: append([]Event(nil), ecodes...), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) numEvents(es EventSet) (int, error) {
+	n, err := r.inner.numEvents(es)
+	r.log(Call{Op: "PAPI_num_events", EventSet: es, Result: int64(n), Errno: errnoOf(err)})
+	return n, err
+}
+
+func (r *recorder) listEvents(es EventSet, numEvents int) ([]Event, error) {
+	ecodes, err := r.inner.listEvents(es, numEvents)
+	r.log(Call{Op: "PAPI_list_events", EventSet: es, Arg: numEvents, Events: ecodes, Errno: errnoOf(err)})
+	return ecodes, err
+}
+
+func (r *recorder) cleanupEventSet(es EventSet) error {
+	err := r.inner.cleanupEventSet(es)
+	r.log(Call{Op: "PAPI_cleanup_eventset", EventSet: es, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) destroyEventSet(es *EventSet) error {
+	before := *es
+	err := r.inner.destroyEventSet(es)
+	r.log(Call{Op: "PAPI_destroy_eventset", EventSet: before, Result: int64(*es), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) getMultiplex(es EventSet) (bool, error) {
+	isMplexed, err := r.inner.getMultiplex(es)
+	c := Call{Op: "PAPI_get_multiplex", EventSet: es, Errno: errnoOf(err)}
+	if isMplexed {
+		c.Result = 1
+	}
+	r.log(c)
+	return isMplexed, err
+}
+
+func (r *recorder) setMultiplex(es EventSet) error {
+	err := r.inner.setMultiplex(es)
+	r.log(Call{Op: "PAPI_set_multiplex", EventSet: es, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) assignComponent(es EventSet, idx int) error {
+	err := r.inner.assignComponent(es, idx)
+	r.log(Call{Op: "PAPI_assign_eventset_component", EventSet: es, Arg: idx, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) attachCPU(es EventSet, cpu int) error {
+	err := r.inner.attachCPU(es, cpu)
+	r.log(Call{Op: "PAPI_set_opt", EventSet: es, Arg: cpu, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) attach(es EventSet, tid int) error {
+	err := r.inner.attach(es, tid)
+	r.log(Call{Op: "PAPI_attach", EventSet: es, Arg: tid, Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) setInherit(es EventSet) error {
+	err := r.inner.setInherit(es)
+	r.log(Call{Op: "PAPI_set_opt", EventSet: es, Name: "PAPI_INHERIT", Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) start(es EventSet) error {
+	err := r.inner.start(es)
+	r.log(Call{Op: "PAPI_start", EventSet: es, Usec: r.inner.getRealUsec(), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) stop(es EventSet, values []int64) error {
+	err := r.inner.stop(es, values)
+	r.log(Call{Op: "PAPI_stop", EventSet: es, Values: copyValues(values), Usec: r.inner.getRealUsec(), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) read(es EventSet, values []int64) error {
+	err := r.inner.read(es, values)
+	r.log(Call{Op: "PAPI_read", EventSet: es, Values: copyValues(values), Usec: r.inner.getRealUsec(), Errno: errnoOf(err)})
+	return err
+}
+
+func (r *recorder) readTS(es EventSet, values []int64) (int64, error) {
+	cyc, err := r.inner.readTS(es, values)
+	r.log(Call{Op: "PAPI_read_ts", EventSet: es, Values: copyValues(values), Result: cyc, Usec: r.inner.getRealUsec(), Errno: errnoOf(err)})
+	return cyc, err
+}
+
+func (r *recorder) reset(es EventSet) error {
+	err := r.inner.reset(es)
+	r.log(Call{Op: "PAPI_reset", EventSet: es, Usec: r.inner.getRealUsec(), Errno: errnoOf(err)})
+	return err
+}
+
+// ----------------------------------------------------------------------
+
+// A ReplayError indicates that a call made while replaying a trace
+// does not correspond to any call recorded in the trace.
+type ReplayError struct {
+	Op     string // Name of the corresponding PAPI C function
+	Reason string // Description of the discrepancy
+}
+
+func (e *ReplayError) Error() string {
+	return "papi replay: " + e.Op + ": " + e.Reason
+}
+
+// The following calls merely look up static information.  They are
+// replayed by matching arguments, regardless of order.  All other
+// calls are replayed in the order in which they were recorded.
+var lookupOps = map[string]bool{
+	"PAPI_event_code_to_name":  true,
+	"PAPI_event_name_to_code":  true,
+	"PAPI_get_event_info":      true,
+	"PAPI_get_event_component": true,
+	"PAPI_enum_event":          true,
+	"PAPI_get_executable_info": true,
+	"PAPI_get_hardware_info":   true,
+	"PAPI_num_components":      true,
+	"PAPI_num_cmp_hwctrs":      true,
+	"PAPI_get_component_info":  true,
+}
+
+// A replayer answers every request from a trace instead of from the
+// PAPI library.
+type replayer struct {
+	prev    backend // Backend to restore when replay ends
+	lock    sync.Mutex
+	lookups map[string]*Call   // Lookup calls, keyed by operation and arguments
+	queues  map[string][]*Call // All other calls, in order, keyed by operation
+}
+
+// Serve every subsequent PAPI call from a trace instead of from the
+// PAPI library.  Calls that look up static information (event names,
+// event information, hardware information, etc.) may be made in any
+// order.  For each other type of call (creating an event set, reading
+// counters, etc.) the calls must be made in the same order and with
+// the same arguments as were recorded; otherwise, the call returns
+// (or, if it cannot return an error, panics with) a *ReplayError.
+// Replay continues until StopReplay() is called.  A program that only
+// replays traces never initializes or otherwise calls the PAPI library,
+// although the library must still be installed for the program to load.
+func StartReplay(t *Trace) {
+	rep := &replayer{
+		lookups: make(map[string]*Call),
+		queues:  make(map[string][]*Call),
+	}
+	for i := range t.Calls {
+		c := &t.Calls[i]
+		if lookupOps[c.Op] {
+			if _, seen := rep.lookups[c.lookupKey()]; !seen {
+				rep.lookups[c.lookupKey()] = c
+			}
+		} else {
+			rep.queues[c.Op] = append(rep.queues[c.Op], c)
+		}
+	}
+	libLock.Lock()
+	defer libLock.Unlock()
+	rep.prev = lib
+	lib = rep
+}
+
+// Stop replaying a trace and resume calling the PAPI library.
+func StopReplay() {
+	libLock.Lock()
+	defer libLock.Unlock()
+	if rep, ok := lib.(*replayer); ok {
+		lib = rep.prev
+	}
+}
+
+// Return a string that identifies a lookup call by its arguments.
+func (c *Call) lookupKey() string {
+	switch c.Op {
+	case "PAPI_event_name_to_code":
+		return fmt.Sprintf("%s %q", c.Op, c.Name)
+	case "PAPI_enum_event":
+		return fmt.Sprintf("%s %d %d %d", c.Op, c.Arg, c.Event, c.Modifier)
+	default:
+		return fmt.Sprintf("%s %d %d", c.Op, c.Event, c.Arg)
+	}
+}
+
+// Find the recorded result of a lookup call.
+func (r *replayer) lookup(want Call) (*Call, error) {
+	if c, ok := r.lookups[want.lookupKey()]; ok {
+		return c, nil
+	}
+	return nil, &ReplayError{Op: want.Op, Reason: "no matching call in trace"}
+}
+
+// Find the recorded result of a lookup call that cannot return an
+// error.
+func (r *replayer) mustLookup(want Call) *Call {
+	c, err := r.lookup(want)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Dequeue the next recorded call of the same type as a given call and
+// ensure that the arguments match.
+func (r *replayer) next(want Call) (*Call, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	queue := r.queues[want.Op]
+	if len(queue) == 0 {
+		return nil, &ReplayError{Op: want.Op, Reason: "no more calls in trace"}
+	}
+	c := queue[0]
+	r.queues[want.Op] = queue[1:]
+	switch {
+	case c.EventSet != want.EventSet:
+		return nil, &ReplayError{Op: want.Op, Reason: fmt.Sprintf("expected event set %d but saw %d", c.EventSet, want.EventSet)}
+	case c.Event != want.Event:
+		return nil, &ReplayError{Op: want.Op, Reason: fmt.Sprintf("expected event %#x but saw %#x", uint32(c.Event), uint32(want.Event))}
+	case want.Events != nil && !reflect.DeepEqual(c.Events, want.Events):
+		return nil, &ReplayError{Op: want.Op, Reason: fmt.Sprintf("expected events %v but saw %v", c.Events, want.Events)}
+	case c.Arg != want.Arg:
+		return nil, &ReplayError{Op: want.Op, Reason: fmt.Sprintf("expected argument %d but saw %d", c.Arg, want.Arg)}
+	case c.Name != want.Name:
+		return nil, &ReplayError{Op: want.Op, Reason: fmt.Sprintf("expected %q but saw %q", c.Name, want.Name)}
+	}
+	return c, nil
+}
+
+// Dequeue the next recorded call that cannot return an error.
+func (r *replayer) mustNext(want Call) *Call {
+	c, err := r.next(want)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (r *replayer) eventCodeToName(ecode Event) (string, error) {
+	c, err := r.lookup(Call{Op: "PAPI_event_code_to_name", Event: ecode})
+	if err != nil {
+		return "", err
+	}
+	return c.Name, errorOf(c.Errno)
+}
+
+func (r *replayer) eventNameToCode(ename string) (Event, error) {
+	c, err := r.lookup(Call{Op: "PAPI_event_name_to_code", Name: ename})
+	if err != nil {
+		return 0, err
+	}
+	return c.Event, errorOf(c.Errno)
+}
+
+func (r *replayer) getEventInfo(ecode Event) (info EventInfo, err error) {
+	c, err := r.lookup(Call{Op: "PAPI_get_event_info", Event: ecode})
+	if err != nil {
+		return
+	}
+	if c.Info != nil {
+		info = *c.Info
+	}
+	return info, errorOf(c.Errno)
+}
+
+func (r *replayer) getEventComponent(ecode Event) (int, error) {
+	c, err := r.lookup(Call{Op: "PAPI_get_event_component", Event: ecode})
+	if err != nil {
+		return 0, err
+	}
+	return int(c.Result), errorOf(c.Errno)
+}
+
+func (r *replayer) enumEvents(emask EventMask, modifier EventModifier) ([]Event, error) {
+	c, err := r.lookup(Call{Op: "PAPI_enum_event", Arg: int(emask), Modifier: modifier})
+	if err != nil {
+		return nil, err
+	}
+	if c.Errno != papi_ok {
+		return nil, c.Errno
+	}
+	return append(make([]Event, 0, len(c.Events)), c.Events...), nil
+}
+
+func (r *replayer) enumUmasks(ecode Event) ([]Event, error) {
+	c, err := r.lookup(Call{Op: "PAPI_enum_event", Event: ecode, Modifier: NTV_ENUM_UMASKS})
+	if err != nil {
+		return nil, err
+	}
+	if c.Errno != papi_ok {
+		return nil, c.Errno
+	}
+	return append(make([]Event, 0, len(c.Events)), c.Events...), nil
+}
+
+func (r *replayer) getExecutableInfo() ProgramInfo {
+	return *r.mustLookup(Call{Op: "PAPI_get_executable_info"}).Executable
+}
+
+func (r *replayer) getHardwareInfo() HardwareInfo {
+	return *r.mustLookup(Call{Op: "PAPI_get_hardware_info"}).Hardware
+}
+
+func (r *replayer) getDynMemInfo() (DynMemInfo, error) {
+	c, err := r.next(Call{Op: "PAPI_get_dmem_info"})
+	if err != nil {
+		return DynMemInfo{}, err
+	}
+	return *c.DynMem, errorOf(c.Errno)
+}
+
+func (r *replayer) numComponents() int {
+	return int(r.mustLookup(Call{Op: "PAPI_num_components"}).Result)
+}
+
+func (r *replayer) numCmpHwctrs(idx int) int {
+	return int(r.mustLookup(Call{Op: "PAPI_num_cmp_hwctrs", Arg: idx}).Result)
+}
+
+func (r *replayer) getComponentInfo(idx int) (info ComponentInfo, err error) {
+	c, err := r.lookup(Call{Op: "PAPI_get_component_info", Arg: idx})
+	if err != nil {
+		return
+	}
+	if c.Component != nil {
+		info = *c.Component
+	}
+	return info, errorOf(c.Errno)
+}
+
+func (r *replayer) getRealCyc() int64 {
+	return r.mustNext(Call{Op: "PAPI_get_real_cyc"}).Result
+}
+
+func (r *replayer) getRealUsec() int64 {
+	return r.mustNext(Call{Op: "PAPI_get_real_usec"}).Result
+}
+
+func (r *replayer) getVirtCyc() int64 {
+	return r.mustNext(Call{Op: "PAPI_get_virt_cyc"}).Result
+}
+
+func (r *replayer) getVirtUsec() int64 {
+	return r.mustNext(Call{Op: "PAPI_get_virt_usec"}).Result
+}
+
+func (r *replayer) createEventSet() (EventSet, error) {
+	c, err := r.next(Call{Op: "PAPI_create_eventset"})
+	if err != nil {
+		return EventSet(-1), err
+	}
+	return EventSet(c.Result), errorOf(c.Errno)
+}
+
+// Replay a call whose only result is an error code.
+func (r *replayer) simple(want Call) error {
+	c, err := r.next(want)
+	if err != nil {
+		return err
+	}
+	return errorOf(c.Errno)
+}
+
+func (r *replayer) addEvent(es EventSet, ecode Event) error {
+	return r.simple(Call{Op: "PAPI_add_event", EventSet: es, Event: ecode})
+}
+
+func (r *replayer) addEvents(es EventSet, ecodes []Event) error {
+	return r.simple(Call{Op: "PAPI_add_events", EventSet: es, Events: ecodes})
+}
+
+func (r *replayer) removeEvent(es EventSet, ecode Event) error {
+	return r.simple(Call{Op: "PAPI_remove_event", EventSet: es, Event: ecode})
+}
+
+func (r *replayer) removeEvents(es EventSet, ecodes []Event) error {
+	return r.simple(Call{Op: "PAPI_remove_events", EventSet: es, Events: ecodes})
+}
+
+func (r *replayer) numEvents(es EventSet) (int, error) {
+	c, err := r.next(Call{Op: "PAPI_num_events", EventSet: es})
+	if err != nil {
+		return 0, err
+	}
+	return int(c.Result), errorOf(c.Errno)
+}
+
+func (r *replayer) listEvents(es EventSet, numEvents int) ([]Event, error) {
+	c, err := r.next(Call{Op: "PAPI_list_events", EventSet: es, Arg: numEvents})
+	if err != nil {
+		return nil, err
+	}
+	if c.Errno != papi_ok {
+		return nil, c.Errno
+	}
+	return append(make([]Event, 0, len(c.Events)), c.Events...), nil
+}
+
+func (r *replayer) cleanupEventSet(es EventSet) error {
+	return r.simple(Call{Op: "PAPI_cleanup_eventset", EventSet: es})
+}
+
+func (r *replayer) destroyEventSet(es *EventSet) error {
+	c, err := r.next(Call{Op: "PAPI_destroy_eventset", EventSet: *es})
+	if err != nil {
+		return err
+	}
+	*es = EventSet(c.Result)
+	return errorOf(c.Errno)
+}
+
+func (r *replayer) getMultiplex(es EventSet) (bool, error) {
+	c, err := r.next(Call{Op: "PAPI_get_multiplex", EventSet: es})
+	if err != nil {
+		return false, err
+	}
+	return c.Result != 0, errorOf(c.Errno)
+}
+
+func (r *replayer) setMultiplex(es EventSet) error {
+	return r.simple(Call{Op: "PAPI_set_multiplex", EventSet: es})
+}
+
+func (r *replayer) assignComponent(es EventSet, idx int) error {
+	return r.simple(Call{Op: "PAPI_assign_eventset_component", EventSet: es, Arg: idx})
+}
+
+func (r *replayer) attachCPU(es EventSet, cpu int) error {
+	return r.simple(Call{Op: "PAPI_set_opt", EventSet: es, Arg: cpu})
+}
+
+func (r *replayer) attach(es EventSet, tid int) error {
+	return r.simple(Call{Op: "PAPI_attach", EventSet: es, Arg: tid})
+}
+
+func (r *replayer) setInherit(es EventSet) error {
+	return r.simple(Call{Op: "PAPI_set_opt", EventSet: es, Name: "PAPI_INHERIT"})
+}
+
+func (r *replayer) start(es EventSet) error {
+	return r.simple(Call{Op: "PAPI_start", EventSet: es})
+}
+
+// Replay a call that returns counter values.
+func (r *replayer) counters(want Call, values []int64) error {
+	c, err := r.next(want)
+	if err != nil {
+		return err
+	}
+	copy(values, c.Values)
+	return errorOf(c.Errno)
+}
+
+func (r *replayer) stop(es EventSet, values []int64) error {
+	return r.counters(Call{Op: "PAPI_stop", EventSet: es}, values)
+}
+
+func (r *replayer) read(es EventSet, values []int64) error {
+	return r.counters(Call{Op: "PAPI_read", EventSet: es}, values)
+}
+
+func (r *replayer) readTS(es EventSet, values []int64) (int64, error) {
+	c, err := r.next(Call{Op: "PAPI_read_ts", EventSet: es})
+	if err != nil {
+		return 0, err
+	}
+	copy(values, c.Values)
+	return c.Result, errorOf(c.Errno)
+}
+
+func (r *replayer) reset(es EventSet) error {
+	return r.simple(Call{Op: "PAPI_reset", EventSet: es})
+}
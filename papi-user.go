@@ -0,0 +1,382 @@
+// This file supports user-defined events, which are computed from the
+// values of other events by a postfix expression.  Event sets that
+// contain user-defined events are counted by adding the events' terms
+// to the underlying PAPI event set and evaluating each user-defined
+// event's expression whenever the event set is read.
+
+package papi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A UserEvent defines an event in terms of other events.
+type UserEvent struct {
+	Name        string   // Name by which the event is known (e.g., "MY_MEM_BW")
+	Description string   // Textual description of the event
+	Formula     string   // Postfix expression over the terms (see Postfix)
+	Terms       []string // Names of the preset or native events N0, N1, ... in Formula
+}
+
+// A userEvent is a registered, validated UserEvent.
+type userEvent struct {
+	UserEvent
+	terms   []Event  // Event codes corresponding to Terms
+	formula *Postfix // Parsed form of Formula
+}
+
+// User-defined event codes are distinguished by their three most
+// significant bits.  PAPI sets the two most significant bits to 01 for
+// native events, to 10 for preset events, and to 11 (PAPI_UE_MASK) for
+// its own user-defined events, so setting the three bits to 001 keeps
+// this package's codes distinct from any that PAPI returns.
+const (
+	userEventTypeMask = -1 << 29 // Bits that identify a user-defined event
+	userEventMask     = 1 << 29  // Value of those bits in a user-defined event
+)
+
+// All registered user-defined events
+var userEvents struct {
+	sync.RWMutex
+	list   []*userEvent     // Events indexed by the low bits of their event code
+	byName map[string]Event // Map from event name to event code
+}
+
+// Return a user-defined event or nil if the event code does not
+// correspond to a registered user-defined event.
+func lookupUserEvent(ecode Event) *userEvent {
+	if ecode&userEventTypeMask != userEventMask {
+		return nil
+	}
+	userEvents.RLock()
+	defer userEvents.RUnlock()
+	idx := int(ecode &^ userEventTypeMask)
+	if idx >= len(userEvents.list) {
+		return nil
+	}
+	return userEvents.list[idx]
+}
+
+// Return the event code of a user-defined event given its name.
+func lookupUserEventName(ename string) (Event, bool) {
+	userEvents.RLock()
+	defer userEvents.RUnlock()
+	ecode, ok := userEvents.byName[ename]
+	return ecode, ok
+}
+
+// Register a user-defined event and return its event code.  Once
+// registered, the event can be named in ParseEvent(), added to an
+// event set, and described by GetEventInfo() like any other event.
+// Each term must be a preset or native event; user-defined events
+// cannot be defined in terms of other user-defined events.
+func DefineUserEvent(def UserEvent) (ecode Event, err error) {
+	if def.Name == "" {
+		return 0, fmt.Errorf("papi: user-defined event has no name")
+	}
+	if _, isPreset := presetsByName[def.Name]; isPreset {
+		return 0, fmt.Errorf("papi: user-defined event %s conflicts with a preset event", def.Name)
+	}
+	ue := &userEvent{UserEvent: def}
+	ue.Terms = append([]string(nil), def.Terms...)
+	if ue.formula, err = ParsePostfix(def.Formula); err != nil {
+		return 0, fmt.Errorf("papi: user-defined event %s: %w", def.Name, err)
+	}
+	if ue.formula.NumTerms() > len(def.Terms) {
+		return 0, fmt.Errorf("papi: user-defined event %s: formula %q requires %d terms but only %d are given",
+			def.Name, def.Formula, ue.formula.NumTerms(), len(def.Terms))
+	}
+	for _, tname := range def.Terms {
+		if _, isUser := lookupUserEventName(tname); isUser {
+			return 0, fmt.Errorf("papi: user-defined event %s: term %s is itself user-defined", def.Name, tname)
+		}
+		tcode, err := ParseEvent(tname)
+		if err != nil {
+			return 0, fmt.Errorf("papi: user-defined event %s: term %s: %w", def.Name, tname, err)
+		}
+		ue.terms = append(ue.terms, tcode)
+	}
+
+	userEvents.Lock()
+	defer userEvents.Unlock()
+	if userEvents.byName == nil {
+		userEvents.byName = make(map[string]Event)
+	}
+	if _, exists := userEvents.byName[def.Name]; exists {
+		return 0, fmt.Errorf("papi: user-defined event %s is already defined", def.Name)
+	}
+	ecode = Event(userEventMask) | Event(len(userEvents.list))
+	userEvents.list = append(userEvents.list, ue)
+	userEvents.byName[def.Name] = ecode
+	return ecode, nil
+}
+
+// Read user-defined events from a CSV file and register each of them
+// with DefineUserEvent().  Each record has the form
+//
+//	name,description,formula,term0,term1,...
+//
+// which parallels the PRESET records of PAPI's papi_events.csv.  Blank
+// lines and lines beginning with "#" are ignored.  Return the event
+// codes of the events that were registered.
+//
+// Only CSV is supported because the papi package depends on nothing
+// beyond the standard library, which has no YAML parser.  A program
+// that keeps its definitions in YAML can decode them into UserEvent
+// values with the YAML package of its choice and pass each one to
+// DefineUserEvent().
+func LoadUserEvents(r io.Reader) (ecodes []Event, err error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return ecodes, nil
+		}
+		if err != nil {
+			return ecodes, err
+		}
+		if len(rec) < 4 {
+			line, _ := cr.FieldPos(0)
+			return ecodes, fmt.Errorf("papi: line %d: expected name, description, formula, and at least one term", line)
+		}
+		def := UserEvent{
+			Name:        strings.TrimSpace(rec[0]),
+			Description: strings.TrimSpace(rec[1]),
+			Formula:     strings.TrimSpace(rec[2]),
+		}
+		for _, t := range rec[3:] {
+			if t = strings.TrimSpace(t); t != "" {
+				def.Terms = append(def.Terms, t)
+			}
+		}
+		ecode, err := DefineUserEvent(def)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return ecodes, fmt.Errorf("line %d: %w", line, err)
+		}
+		ecodes = append(ecodes, ecode)
+	}
+}
+
+// Read user-defined events from a named CSV file.  See
+// LoadUserEvents() for the file format.
+func LoadUserEventsFile(filename string) ([]Event, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ecodes, err := LoadUserEvents(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", filename, err)
+	}
+	return ecodes, err
+}
+
+// Describe a user-defined event in the same form as GetEventInfo()
+// describes a PAPI event.
+func (ue *userEvent) info(ecode Event) EventInfo {
+	info := EventInfo{
+		EventCode:  ecode,
+		Symbol:     ue.Name,
+		ShortDescr: ue.Name,
+		LongDescr:  ue.Description,
+		Derived:    "DERIVED_POSTFIX",
+		Postfix:    ue.Formula,
+		Name:       append([]string(nil), ue.Terms...),
+	}
+	for _, t := range ue.terms {
+		info.Code = append(info.Code, uint32(t))
+	}
+	return info
+}
+
+// ----------------------------------------------------------------------
+
+// A userLayout maps the events an event set appears to contain, some
+// of which are user-defined, to the events PAPI actually counts.
+type userLayout struct {
+	events   []Event // Events as added by the caller
+	physical []Event // Events added to the PAPI event set
+	terms    [][]int // Indexes into physical of each event's terms
+}
+
+// Layouts of all event sets that contain user-defined events
+var userLayouts = struct {
+	sync.Mutex
+	m map[EventSet]*userLayout
+}{m: make(map[EventSet]*userLayout)}
+
+// Return an event set's layout or nil if it has none.
+func layoutOf(es EventSet) *userLayout {
+	userLayouts.Lock()
+	defer userLayouts.Unlock()
+	return userLayouts.m[es]
+}
+
+// Forget an event set's layout.
+func forgetLayout(es EventSet) {
+	userLayouts.Lock()
+	delete(userLayouts.m, es)
+	userLayouts.Unlock()
+}
+
+// Return the events PAPI must count to produce a given event's value.
+func termsOf(ecode Event) []Event {
+	if ue := lookupUserEvent(ecode); ue != nil {
+		return ue.terms
+	}
+	return []Event{ecode}
+}
+
+// Return an event set's layout, creating it from the event set's
+// current contents if it has none.
+func needLayout(es EventSet) (*userLayout, error) {
+	if l := layoutOf(es); l != nil {
+		return l, nil
+	}
+	numEvents, err := current().numEvents(es)
+	if err != nil {
+		return nil, err
+	}
+	l := &userLayout{}
+	if numEvents > 0 {
+		if l.physical, err = current().listEvents(es, numEvents); err != nil {
+			return nil, err
+		}
+	}
+	for i, e := range l.physical {
+		l.events = append(l.events, e)
+		l.terms = append(l.terms, []int{i})
+	}
+	userLayouts.Lock()
+	userLayouts.m[es] = l
+	userLayouts.Unlock()
+	return l, nil
+}
+
+// Add an event, which may be user-defined, to an event set with a
+// layout, adding to PAPI any terms the event set doesn't yet count.
+func (l *userLayout) add(es EventSet, ecode Event) error {
+	for _, e := range l.events {
+		if e == ecode {
+			return ECNFLCT
+		}
+	}
+	var idxs []int
+	added := 0
+	for _, t := range termsOf(ecode) {
+		idx := -1
+		for i, p := range l.physical {
+			if p == t {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			if err := current().addEvent(es, t); err != nil {
+				// Back out the terms added so far.
+				for _, p := range l.physical[len(l.physical)-added:] {
+					current().removeEvent(es, p)
+				}
+				l.physical = l.physical[:len(l.physical)-added]
+				return err
+			}
+			idx = len(l.physical)
+			l.physical = append(l.physical, t)
+			added++
+		}
+		idxs = append(idxs, idx)
+	}
+	l.events = append(l.events, ecode)
+	l.terms = append(l.terms, idxs)
+	return nil
+}
+
+// Remove an event, which may be user-defined, from an event set with a
+// layout, removing from PAPI any terms no other event needs.
+func (l *userLayout) remove(es EventSet, ecode Event) error {
+	pos := -1
+	for i, e := range l.events {
+		if e == ecode {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return EINVAL
+	}
+	l.events = append(l.events[:pos], l.events[pos+1:]...)
+	l.terms = append(l.terms[:pos], l.terms[pos+1:]...)
+
+	// Determine which terms remain in use.
+	used := make([]bool, len(l.physical))
+	for _, idxs := range l.terms {
+		for _, i := range idxs {
+			used[i] = true
+		}
+	}
+	var physical []Event
+	newIdx := make([]int, len(l.physical))
+	for i, p := range l.physical {
+		if used[i] {
+			newIdx[i] = len(physical)
+			physical = append(physical, p)
+		} else if err := current().removeEvent(es, p); err != nil {
+			return err
+		}
+	}
+	l.physical = physical
+	for _, idxs := range l.terms {
+		for j, i := range idxs {
+			idxs[j] = newIdx[i]
+		}
+	}
+	return nil
+}
+
+// Compute the values of an event set's events from the values of the
+// events PAPI counts.
+func (l *userLayout) compute(raw, values []int64) {
+	termVals := make([]int64, 0, 8)
+	for i, e := range l.events {
+		idxs := l.terms[i]
+		ue := lookupUserEvent(e)
+		if ue == nil {
+			values[i] = raw[idxs[0]]
+			continue
+		}
+		termVals = termVals[:0]
+		for _, idx := range idxs {
+			termVals = append(termVals, raw[idx])
+		}
+		values[i] = ue.formula.Eval(termVals)
+	}
+}
+
+// Read or stop an event set with a layout.
+func (l *userLayout) readOrStop(es EventSet, values []int64, stop bool) error {
+	if len(values) < len(l.events) {
+		return EBUF
+	}
+	raw := make([]int64, len(l.physical))
+	var err error
+	if stop {
+		err = current().stop(es, raw)
+	} else {
+		err = current().read(es, raw)
+	}
+	if err != nil {
+		return err
+	}
+	l.compute(raw, values)
+	return nil
+}
@@ -1,5 +1,5 @@
 // This file defines various PAPI datatypes and methods on those types
-// and initializes the PAPI library.
+// and initializes the PAPI library on first use.
 
 /*
 This package presents a Go interface to PAPI, the Performance API.
@@ -22,8 +22,15 @@ int initialize_papi_threading (void)
 }
 */
 import "C"
-import "unsafe"
-import "fmt"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// The error numbers, events, and event modifiers defined in papi.h are
+// converted to Go by a helper program.
+//go:generate go run ./internal/cmd/genconsts
 
 // An Errno is the PAPI error number.
 type Errno int32
@@ -32,8 +39,23 @@ type Errno int32
 // always convert this to nil when returning an error to the user.
 const papi_ok = C.PAPI_OK
 
-// Convert a PAPI error number to a string.
+// Convert a PAPI error number to a string.  PAPI describes errors
+// only once it is initialized, so while a Trace is being replayed by a
+// program that has not otherwise called PAPI, the error is described
+// by number alone.
 func (err Errno) String() (errMsg string) {
+	if !initialized.Load() {
+		if _, replaying := current().(*replayer); replaying {
+			return fmt.Sprintf("PAPI error %d", int32(err))
+		}
+		initialize()
+	}
+	return err.describe()
+}
+
+// Convert a PAPI error number to a string without first initializing
+// PAPI, as is necessary while initializing it.
+func (err Errno) describe() (errMsg string) {
 	if papiErrStr := C.PAPI_strerror(C.int(err)); papiErrStr == nil {
 		errMsg = "Unknown PAPI error"
 	} else {
@@ -52,28 +74,24 @@ func (err Errno) Error() (errMsg string) {
 // An Event is a PAPI event code, either preset or native.
 type Event int32
 
-// Convert a PAPI event code to a string.
+// Convert a PAPI event code to a string.  Preset and user-defined
+// events are converted without calling PAPI.
 func (ecode Event) String() (ename string) {
-	cstring := (*C.char)(C.malloc(C.PAPI_MAX_STR_LEN))
-	defer C.free(unsafe.Pointer(cstring))
-	if Errno(C.PAPI_event_code_to_name(C.int(ecode), cstring)) == papi_ok {
-		ename = C.GoString(cstring)
+	if p := lookupPreset(ecode); p != nil {
+		return p.symbol
+	}
+	if ue := lookupUserEvent(ecode); ue != nil {
+		return ue.Name
 	}
+	ename, _ = current().eventCodeToName(ecode)
 	return
 }
 
 // Convert a string to a PAPI event code.  This is particularly useful
 // for looking up the event code associated with a PAPI native event.
+// StringToEvent() is equivalent to ParseEvent().
 func StringToEvent(ename string) (ecode Event, err error) {
-	cstring := C.CString(ename)
-	defer C.free(unsafe.Pointer(cstring))
-	var c_ecode C.int
-	if errno := Errno(C.PAPI_event_name_to_code(cstring, &c_ecode)); errno == papi_ok {
-		ecode = Event(c_ecode)
-	} else {
-		err = errno
-	}
-	return
+	return ParseEvent(ename)
 }
 
 // An EventInfo textually describes a PAPI event.
@@ -310,6 +328,8 @@ type HardwareInfo struct {
 	CPUIDStepping int32         // CPUID stepping
 	MHz           float32       // CPU's current clock rate in megahertz
 	ClockMHz      int32         // CPUs cycle counter's current clock rate in megahertz
+	MaxMHz        int32         // CPU's maximum supported clock rate in megahertz
+	MinMHz        int32         // CPU's minimum supported clock rate in megahertz
 	MemHierarchy  []MHLevelInfo // Information about each level of the memory hierarchy
 }
 
@@ -340,9 +360,13 @@ type DynMemInfo struct {
 // describes a wealth of information about an individual component.
 type ComponentInfo struct {
 	Name                   string // Name of the substrate we're using, usually CVS RCS Id
+	ShortName              string // Short name of the component
+	Description            string // Description of the component
 	Version                string // Version of this substrate, usually CVS Revision
 	SupportVersion         string // Version of the support library
 	KernelVersion          string // Version of the kernel PMC support driver
+	Disabled               bool   // Component is disabled and provides no events
+	DisabledReason         string // Reason the component is disabled
 	CmpIdx                 int    // Index into the vector array for this component; set at init time
 	NumCntrs               int    // Number of hardware counters the substrate supports
 	NumMpxCntrs            int    // Number of hardware counters the substrate or PAPI can multiplex supports
@@ -395,6 +419,7 @@ const (
 
 // Set the PAPI library's debug level.
 func SetDebugLevel(level int) (err error) {
+	initialize()
 	if errno := Errno(C.PAPI_set_debug(C.int(level))); errno != papi_ok {
 		err = errno
 	}
@@ -403,8 +428,25 @@ func SetDebugLevel(level int) (err error) {
 
 // ----------------------------------------------------------------------
 
-// Before we do anything else we need to initialize the PAPI library.
-func init() {
+// The PAPI library is initialized by the first call into it rather
+// than when the package is loaded, so that a program that only replays
+// a Trace never calls PAPI.
+var (
+	initOnce    sync.Once
+	initialized atomic.Bool // true once PAPI has been initialized
+)
+
+// Initialize the PAPI library unless it has already been initialized.
+// Every call into the PAPI library must be preceded by a call to
+// initialize().  Because no PAPI function can succeed without it,
+// initialize() panics if the library cannot be initialized.
+func initialize() {
+	initOnce.Do(initLibrary)
+}
+
+// Initialize the PAPI library, its thread support, and the high-level
+// counter support.
+func initLibrary() {
 	// Initialize the library proper.
 	switch initval := C.PAPI_library_init(C.PAPI_VER_CURRENT); {
 	case initval == C.PAPI_VER_CURRENT:
@@ -414,21 +456,22 @@ func init() {
 		panic(fmt.Sprintf("PAPI library version mismatch: expected %d but saw %d",
 			C.PAPI_VER_CURRENT, initval))
 	case initval < 0:
-		panic(Errno(initval).String())
+		panic(Errno(initval).describe())
 	}
 
 	// Initialize the library's thread support.
 	threadval := C.initialize_papi_threading()
 	if threadval != C.PAPI_OK {
-		panic(Errno(threadval).String())
+		panic(Errno(threadval).describe())
 	}
 
 	// Initialize the high-level counter support.
-	if nc := C.PAPI_num_counters(); nc >= 0 {
-		NumCounters = int(nc)
+	if nc := numHLCounters(); nc >= 0 {
+		NumCounters = nc
 	} else {
-		panic(Errno(nc).String())
+		panic(Errno(nc).describe())
 	}
+	initialized.Store(true)
 }
 
 // Enable PAPI support for multiplexed event sets (event sets
@@ -436,6 +479,10 @@ func init() {
 // by timesharing counters) at the cost of periodic process
 // interruptions from an interval timer.  InitMultiplex() needs to be
 // called only once per application.
-func InitMultiplex() {
-	C.PAPI_multiplex_init()
+func InitMultiplex() (err error) {
+	initialize()
+	if errno := Errno(C.PAPI_multiplex_init()); errno != papi_ok {
+		err = errno
+	}
+	return
 }
@@ -0,0 +1,147 @@
+// This file tests the integration of PAPI counters with Go benchmarks.
+
+package papi
+
+import (
+	"runtime"
+	"testing"
+)
+
+// Ensure that benchmarks report counters as custom metrics.
+func TestBenchmarkMetrics(t *testing.T) {
+	var counts []int64
+	result := testing.Benchmark(func(b *testing.B) {
+		pb := Benchmark(b, TOT_INS, TOT_CYC)
+		for i := 0; i < pb.N; i++ {
+			pb.StopTimer()
+			performWork(10)
+			pb.StartTimer()
+			performWork(10000)
+		}
+		counts = pb.Counts()
+	})
+	if result.N == 0 {
+		t.Fatal("The benchmark did not run")
+	}
+	if len(counts) != 2 {
+		t.Fatalf("Expected 2 counts but saw %d", len(counts))
+	}
+	for _, name := range []string{"TOT_INS/op", "TOT_CYC/op", "IPC"} {
+		if _, ok := result.Extra[name]; !ok {
+			t.Fatalf("Expected a %s metric in %v", name, result.Extra)
+		}
+	}
+	if got, want := result.Extra["TOT_INS/op"], float64(counts[0])/float64(result.N); got < want {
+		t.Fatalf("Expected TOT_INS/op >= %g but saw %g", want, got)
+	}
+}
+
+// Demonstrate the use of Benchmark().
+func BenchmarkWork(b *testing.B) {
+	pb := Benchmark(b, TOT_INS, TOT_CYC)
+	for i := 0; i < pb.N; i++ {
+		performWork(1000)
+	}
+}
+
+// ----------------------------------------------------------------------
+
+// Return a started event set that counts TOT_INS, skipping the
+// benchmark if the event is unavailable.  The calling goroutine is
+// locked to the OS thread that the event set counts.  The event set is
+// stopped and destroyed and the goroutine unlocked when the benchmark
+// ends.
+func startedEventSet(b *testing.B) EventSet {
+	b.Helper()
+	runtime.LockOSThread()
+	b.Cleanup(runtime.UnlockOSThread)
+	es, err := CreateEventSet()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		es.CleanupEventSet()
+		es.DestroyEventSet()
+	})
+	if err = es.AddEvent(TOT_INS); err != nil {
+		b.Skipf("Unable to count %s: %v", TOT_INS, err)
+	}
+	if err = es.Start(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { es.Stop(make([]int64, 1)) })
+	return es
+}
+
+// Measure the cost of starting and stopping an event set.
+func BenchmarkStartStop(b *testing.B) {
+	es := startedEventSet(b)
+	values := make([]int64, 1)
+	if err := es.Stop(values); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := es.Start(); err != nil {
+			b.Fatal(err)
+		}
+		if err := es.Stop(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if err := es.Start(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// Measure the cost of reading a running event set.
+func BenchmarkRead(b *testing.B) {
+	es := startedEventSet(b)
+	values := make([]int64, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := es.Read(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Measure the cost of resetting a running event set.
+func BenchmarkReset(b *testing.B) {
+	es := startedEventSet(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := es.Reset(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Measure the cost of reading the real-time cycle counter.
+func BenchmarkGetRealCyc(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GetRealCyc()
+	}
+}
+
+// Measure the cost of reading the virtual-time clock.
+func BenchmarkGetVirtUsec(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GetVirtUsec()
+	}
+}
+
+// Measure the cost of converting an event to its name.
+func BenchmarkEventString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = TOT_INS.String()
+	}
+}
+
+// Measure the cost of converting a name to an event.
+func BenchmarkParseEvent(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseEvent("PAPI_TOT_INS")
+	}
+}
@@ -33,7 +33,7 @@ func testFlipFlopsHelper(t *testing.T, funcName string, hlFunc func() (float32,
 	}
 	if (other2 - other1) < flops {
 		t.Fatalf("%s() observed too few counts: %d >= %d",
-			other2-other1, flops)
+			funcName, other2-other1, flops)
 	}
 	if err := StopCounters(counterValues); err != nil {
 		t.Fatal(err)
@@ -50,7 +50,9 @@ func TestFlipFlops(t *testing.T) {
 
 // Ensure that the high-level counters actually count something.
 func TestHLCounters(t *testing.T) {
-	// Start counting a few events (but not more than NumCounters).
+	// Start counting a few events (but not more than NumCounters, which
+	// is set by the first call into PAPI).
+	initialize()
 	eventList := []Event{LD_INS, SR_INS, TOT_CYC, TOT_INS}
 	var usedEvents []Event
 	if len(eventList) <= NumCounters {
@@ -112,3 +114,64 @@ func TestHLCounters(t *testing.T) {
 		t.Fatalf("None of %v appear to count", usedEvents)
 	}
 }
+
+// Ensure that the rate functions introduced in PAPI 6 measure
+// intervals between calls.
+func TestRates(t *testing.T) {
+	const sleep_usecs = 10000
+	const flops = 100
+
+	// Test FlopsRate().
+	if _, _, _, _, err := FlopsRate(FP_OPS); err != nil {
+		t.Fatal(err)
+	}
+	performWork(flops)
+	time.Sleep(sleep_usecs * 1000)
+	rtime, _, flpops, _, err := FlopsRate(FP_OPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtime < sleep_usecs/1.0e6 {
+		t.Fatalf("FlopsRate() real time increases too slowly: %f vs. %f",
+			rtime, sleep_usecs/1.0e6)
+	}
+	if flpops < flops {
+		t.Fatalf("FlopsRate() observed too few counts: %d < %d", flpops, flops)
+	}
+	if err = RateStop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test Epc().
+	if _, _, _, _, _, _, err = Epc(0); err != nil {
+		t.Fatal(err)
+	}
+	performWork(flops)
+	_, _, _, core, evt, epc, err := Epc(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if core <= 0 || evt <= 0 || epc <= 0 {
+		t.Fatalf("Epc() returned non-positive values: core=%d, evt=%d, epc=%f",
+			core, evt, epc)
+	}
+	if err = RateStop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test IpcRate().
+	if _, _, _, _, err = IpcRate(); err != nil {
+		t.Fatal(err)
+	}
+	performWork(flops)
+	_, _, ins, ipc, err := IpcRate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ins <= 0 || ipc <= 0 {
+		t.Fatalf("IpcRate() returned non-positive values: ins=%d, ipc=%f", ins, ipc)
+	}
+	if err = RateStop(); err != nil {
+		t.Fatal(err)
+	}
+}
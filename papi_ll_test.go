@@ -82,6 +82,20 @@ func TestNumComponents(t *testing.T) {
 	}
 }
 
+// Ensure that the CPU component is enabled and has a name.
+func TestComponentInfo(t *testing.T) {
+	info, err := GetComponentInfo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name == "" {
+		t.Fatal("Component 0 has no name")
+	}
+	if info.Disabled {
+		t.Fatalf("Component 0 (%s) is disabled: %s", info.Name, info.DisabledReason)
+	}
+}
+
 // Ensure that selected pieces of hardware information are valid.
 func TestHardwareInfo(t *testing.T) {
 	hw := GetHardwareInfo()
@@ -154,8 +168,10 @@ func TestEventSet(t *testing.T) {
 
 // Test multiplexed event sets.
 func TestMultiplex(t *testing.T) {
-	InitMultiplex()
 	var err error
+	if err = InitMultiplex(); err != nil {
+		t.Fatal(err)
+	}
 	var events EventSet
 	if events, err = CreateEventSet(); err != nil {
 		t.Fatal(err)
@@ -188,6 +204,17 @@ func TestGetEventInfo(t *testing.T) {
 	}
 }
 
+// Ensure that preset events are provided by the CPU component.
+func TestGetEventComponent(t *testing.T) {
+	cidx, err := GetEventComponent(TOT_CYC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cidx != 0 {
+		t.Fatalf("Expected PAPI_TOT_CYC to be provided by component 0 but saw %d", cidx)
+	}
+}
+
 // Ensure that enumerating events gives us at least one event.
 func TestEnumEvents(t *testing.T) {
 	var eventList []Event
@@ -227,3 +254,67 @@ func TestEnumEvents(t *testing.T) {
 			info.NumNativeEvents, len(eventList))
 	}
 }
+
+// Ensure that enumerating a native event's unit masks succeeds and
+// yields only native events.
+func TestEnumUmasks(t *testing.T) {
+	natives, err := EnumEvents(NATIVE_MASK|ComponentMask(0), ENUM_EVENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(natives) == 0 {
+		t.Skip("No native events to enumerate")
+	}
+	umasks, err := EnumUmasks(natives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, um := range umasks {
+		if um&Event(NATIVE_MASK) == 0 {
+			t.Fatalf("Unit mask %#x of %s is not a native event", uint32(um), natives[0])
+		}
+	}
+}
+
+// Ensure that an inherited event set can count and that attaching to
+// an invalid thread ID is rejected.
+func TestInherit(t *testing.T) {
+	info, err := GetComponentInfo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Inherit {
+		t.Skip("Component 0 does not support inheritance")
+	}
+	events, err := CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.DestroyEventSet()
+	if err = events.Attach(0); err != EINVAL {
+		t.Fatalf("Expected EINVAL when attaching to thread 0 but saw %v", err)
+	}
+	if err = events.AssignComponent(0); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.SetInherit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.AddEvent(TOT_INS); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.Start(); err != nil {
+		t.Fatal(err)
+	}
+	performWork(1000)
+	values := make([]int64, 1)
+	if err = events.Stop(values); err != nil {
+		t.Fatal(err)
+	}
+	if values[0] <= 0 {
+		t.Fatalf("Expected a positive instruction count but saw %d", values[0])
+	}
+	if err = events.CleanupEventSet(); err != nil {
+		t.Fatal(err)
+	}
+}
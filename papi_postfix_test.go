@@ -0,0 +1,71 @@
+// This file tests the evaluation of postfix expressions.
+
+package papi
+
+import "testing"
+
+// Ensure that valid postfix expressions evaluate correctly.
+func TestPostfixEval(t *testing.T) {
+	terms := []int64{100, 30, 7}
+	for _, tc := range []struct {
+		expr     string
+		numTerms int
+		want     int64
+	}{
+		{"N0|", 1, 100},
+		{"N0|N1|-|", 2, 70},
+		{"|N0|N1|+|N2|+|", 3, 137},
+		{"N0|N1|N2|*|-|", 3, -110},
+		{"N0|2|/|", 1, 50},
+		{"N1|4|*|N0|/|", 2, 1},
+		{"N0|0.5|*|", 1, 50},
+		{"N0|N2|-|N2|N2|-|/|", 3, 0},
+	} {
+		p, err := ParsePostfix(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.NumTerms() != tc.numTerms {
+			t.Fatalf("%s: expected %d terms but saw %d", tc.expr, tc.numTerms, p.NumTerms())
+		}
+		if got := p.Eval(terms); got != tc.want {
+			t.Fatalf("%s: expected %d but saw %d", tc.expr, tc.want, got)
+		}
+	}
+}
+
+// Ensure that invalid postfix expressions are rejected.
+func TestPostfixErrors(t *testing.T) {
+	for _, expr := range []string{"", "N0|N1|", "N0|+|", "N0|N1|%|", "Nx|", "N-1|", "N+1|", "N|", "1.2.3|"} {
+		if _, err := ParsePostfix(expr); err == nil {
+			t.Fatalf("Expected %q to be rejected", expr)
+		} else if _, ok := err.(*PostfixError); !ok {
+			t.Fatalf("Expected a *PostfixError but saw %T", err)
+		}
+	}
+}
+
+// Ensure that derived types are converted to postfix expressions.
+func TestDerivedPostfix(t *testing.T) {
+	terms := []int64{10, 3, 2}
+	for _, tc := range []struct {
+		info EventInfo
+		want int64
+	}{
+		{EventInfo{Derived: "NOT_DERIVED", Name: []string{"a"}}, 10},
+		{EventInfo{Derived: "DERIVED_ADD", Name: []string{"a", "b", "c"}}, 15},
+		{EventInfo{Derived: "DERIVED_SUB", Name: []string{"a", "b", "c"}}, 5},
+		{EventInfo{Derived: "DERIVED_POSTFIX", Postfix: "N0|N1|*|", Name: []string{"a", "b"}}, 30},
+	} {
+		p, err := tc.info.DerivedPostfix()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Eval(terms); got != tc.want {
+			t.Fatalf("%s (%s): expected %d but saw %d", tc.info.Derived, p, tc.want, got)
+		}
+	}
+	if _, err := (&EventInfo{Derived: "DERIVED_BOGUS"}).DerivedPostfix(); err == nil {
+		t.Fatal("Expected an unknown derived type to be rejected")
+	}
+}
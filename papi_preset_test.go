@@ -0,0 +1,56 @@
+// This file tests the static descriptions of preset events.
+
+package papi
+
+import (
+	"strings"
+	"testing"
+)
+
+// Ensure that the static preset table agrees with PAPI.
+func TestPresetTable(t *testing.T) {
+	for i, p := range presets {
+		if p.symbol == "" {
+			continue
+		}
+		ecode := Event(PRESET_MASK) | Event(i)
+		if ename, err := current().eventCodeToName(ecode); err != nil {
+			t.Fatalf("PAPI failed to name event 0x%08x (%s): %s", uint32(ecode), p.symbol, err)
+		} else if ename != p.symbol {
+			t.Fatalf("Event 0x%08x is %s in the static table but %s in PAPI", uint32(ecode), p.symbol, ename)
+		}
+		if parsed, err := ParseEvent(p.symbol); err != nil || parsed != ecode {
+			t.Fatalf("ParseEvent(%q) returned 0x%08x, %v; expected 0x%08x", p.symbol, uint32(parsed), err, uint32(ecode))
+		}
+		if p.short == "" || p.long == "" {
+			t.Fatalf("%s lacks a description", p.symbol)
+		}
+		if p.categories == 0 {
+			t.Fatalf("%s lacks a category", p.symbol)
+		}
+	}
+	if !strings.Contains(L1_DCM.Description(), "data cache misses") {
+		t.Fatalf("Unexpected description of %s: %q", L1_DCM, L1_DCM.Description())
+	}
+	if L1_DCM.Categories()&PRESET_BIT_L1 == 0 {
+		t.Fatalf("%s is not in category %s", L1_DCM, PRESET_BIT_L1)
+	}
+}
+
+// Ensure that preset events are described without calling PAPI.
+func TestPresetWithoutPAPI(t *testing.T) {
+	StartReplay(&Trace{Version: traceVersion})
+	defer StopReplay()
+	if ename := TOT_CYC.String(); ename != "PAPI_TOT_CYC" {
+		t.Fatalf("Expected PAPI_TOT_CYC but saw %q", ename)
+	}
+	if ecode, err := ParseEvent("PAPI_TOT_CYC"); err != nil || ecode != TOT_CYC {
+		t.Fatalf("ParseEvent(\"PAPI_TOT_CYC\") returned %v, %v", ecode, err)
+	}
+	if TOT_CYC.ShortDescription() == "" {
+		t.Fatalf("%s lacks a short description", TOT_CYC)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = TOT_CYC.String() }); allocs != 0 {
+		t.Fatalf("Event.String() performed %.0f allocations", allocs)
+	}
+}
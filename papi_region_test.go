@@ -0,0 +1,134 @@
+// This file tests region-based measurement.
+
+package papi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Return the statistics for a named region.
+func findRegion(t *testing.T, name string) RegionStats {
+	for _, stats := range RegionReport() {
+		if stats.Name == name {
+			return stats
+		}
+	}
+	t.Fatalf("Region %q does not appear in the report", name)
+	return RegionStats{}
+}
+
+// Ensure that nested regions report consistent inclusive and
+// exclusive counts.
+func TestNestedRegions(t *testing.T) {
+	const iters = 3
+	for i := 0; i < iters; i++ {
+		outer := Begin("test-outer")
+		performWork(1000)
+		inner := Begin("test-inner")
+		performWork(1000)
+		if err := inner.End(); err != nil {
+			t.Fatal(err)
+		}
+		if err := outer.End(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outer := findRegion(t, "test-outer")
+	inner := findRegion(t, "test-inner")
+	if outer.Count != iters || inner.Count != iters {
+		t.Fatalf("Expected %d instances of each region but saw %d and %d",
+			iters, outer.Count, inner.Count)
+	}
+	for i, ev := range outer.Events {
+		if outer.Inclusive[i] < inner.Inclusive[i] {
+			t.Fatalf("%s: outer region counted less than inner region: %d < %d",
+				ev, outer.Inclusive[i], inner.Inclusive[i])
+		}
+		if outer.Exclusive[i] != outer.Inclusive[i]-inner.Inclusive[i] {
+			t.Fatalf("%s: exclusive count %d != %d - %d", ev,
+				outer.Exclusive[i], outer.Inclusive[i], inner.Inclusive[i])
+		}
+		if inner.Exclusive[i] != inner.Inclusive[i] {
+			t.Fatalf("%s: innermost region's exclusive count %d != inclusive count %d",
+				ev, inner.Exclusive[i], inner.Inclusive[i])
+		}
+	}
+
+	// Ensure that the report mentions both regions.
+	var buf bytes.Buffer
+	if err := WriteRegionReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "test-outer") || !strings.Contains(buf.String(), "test-inner") {
+		t.Fatalf("Region report is missing regions:\n%s", buf.String())
+	}
+}
+
+// Ensure that ending regions out of order is reported as an error.
+func TestRegionOrder(t *testing.T) {
+	outer := Begin("test-order-outer")
+	inner := Begin("test-order-inner")
+	if err := outer.End(); err == nil {
+		t.Fatal("Expected ending the outer region first to fail")
+	}
+	if err := inner.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.End(); err == nil {
+		t.Fatal("Expected ending a region twice to fail")
+	}
+}
+
+// Ensure that a region reports its own counts once it has ended.
+func TestRegionCounts(t *testing.T) {
+	r := Begin("test-counts")
+	if events, counts := r.Counts(); events != nil || counts != nil {
+		t.Fatal("Expected no counts from a region that has not ended")
+	}
+	performWork(1000)
+	if err := r.End(); err != nil {
+		t.Fatal(err)
+	}
+	events, counts := r.Counts()
+	if len(events) == 0 || len(counts) != len(events) {
+		t.Fatalf("Expected one count per event but saw %v and %v", events, counts)
+	}
+}
+
+// Ensure that only the requested number of region instances is
+// retained and that each records its nesting and timing.
+func TestRegionSpans(t *testing.T) {
+	KeepRegionSpans(2)
+	defer KeepRegionSpans(0)
+	for i := 0; i < 2; i++ {
+		outer := Begin("test-spans-outer")
+		inner := Begin("test-spans-inner")
+		performWork(1000)
+		if err := inner.End(); err != nil {
+			t.Fatal(err)
+		}
+		if err := outer.End(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spans := RegionSpans()
+	if len(spans) != 2 || spans[0].Name != "test-spans-inner" || spans[1].Name != "test-spans-outer" {
+		t.Fatalf("Expected the last inner and outer regions but saw %+v", spans)
+	}
+	inner, outer := spans[0], spans[1]
+	if inner.Depth != outer.Depth+1 || inner.Thread != outer.Thread {
+		t.Fatalf("Inner region %+v is not nested in outer region %+v", inner, outer)
+	}
+	if inner.StartUsec < outer.StartUsec || inner.EndUsec > outer.EndUsec || len(inner.Inclusive) != len(inner.Events) {
+		t.Fatalf("Inconsistent region instances %+v and %+v", inner, outer)
+	}
+	KeepRegionSpans(0)
+	if spans = RegionSpans(); len(spans) != 0 {
+		t.Fatalf("Expected no region instances but saw %+v", spans)
+	}
+}
@@ -0,0 +1,94 @@
+// This file tests periodic sampling of counters.
+
+package papi
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// Start sampling TOT_INS in the current process, skipping the test if
+// the process cannot be counted from another thread.
+func startSampler(t *testing.T, cfg SamplerConfig) *Sampler {
+	cfg.PID = os.Getpid()
+	s, err := NewSampler([]Event{TOT_INS}, &cfg)
+	if err != nil {
+		t.Skipf("Unable to sample process %d: %v", cfg.PID, err)
+	}
+	return s
+}
+
+// Ensure that a sampler requires something to count.
+func TestSamplerConfig(t *testing.T) {
+	if _, err := NewSampler([]Event{TOT_INS}, nil); err == nil {
+		t.Fatal("Expected an error when sampling neither CPUs nor a process")
+	}
+	if _, err := NewSampler(nil, &SamplerConfig{PID: os.Getpid()}); err == nil {
+		t.Fatal("Expected an error when sampling no events")
+	}
+}
+
+// Ensure that samples arrive on the channel in order with consistent
+// deltas and that the channel is closed by Stop().
+func TestSamplerChannel(t *testing.T) {
+	s := startSampler(t, SamplerConfig{Interval: 2 * time.Millisecond})
+	var smps []Sample
+	timeout := time.After(5 * time.Second)
+	for len(smps) < 3 {
+		select {
+		case smp := <-s.C:
+			smps = append(smps, smp)
+		case <-timeout:
+			t.Fatalf("Received only %d samples", len(smps))
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for smp := range s.C {
+		smps = append(smps, smp)
+	}
+	for i, smp := range smps {
+		if smp.Err != nil {
+			t.Fatal(smp.Err)
+		}
+		if smp.Seq != i+1 || smp.CPU != -1 || len(smp.Values) != 1 || len(smp.Deltas) != 1 || len(smp.Rates) != 1 {
+			t.Fatalf("Unexpected sample %d: %+v", i, smp)
+		}
+		if i > 0 {
+			prev := smps[i-1]
+			if smp.Deltas[0] != smp.Values[0]-prev.Values[0] {
+				t.Fatalf("Delta %d is inconsistent with values %d and %d", smp.Deltas[0], prev.Values[0], smp.Values[0])
+			}
+			if smp.Cyc < prev.Cyc || smp.Usec < prev.Usec {
+				t.Fatalf("Timestamps of sample %d precede those of sample %d", i, i-1)
+			}
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Expected a second Stop() to succeed but saw %v", err)
+	}
+}
+
+// Ensure that a ring buffer retains only the most recent samples.
+func TestSamplerRing(t *testing.T) {
+	s := startSampler(t, SamplerConfig{Interval: time.Millisecond, Capacity: 2, Ring: true})
+	if s.C != nil {
+		t.Fatal("Expected no channel when using a ring buffer")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Dropped() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	smps := s.Samples()
+	if len(smps) != 2 || smps[1].Seq != smps[0].Seq+1 || smps[0].Seq <= 1 {
+		t.Fatalf("Expected the last two of several samples but saw %+v", smps)
+	}
+	if s.Dropped() != smps[1].Seq-2 {
+		t.Fatalf("Expected %d samples to be overwritten but saw %d", smps[1].Seq-2, s.Dropped())
+	}
+}
@@ -0,0 +1,141 @@
+// This file tests recording and replaying PAPI calls.
+
+package papi
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// Perform a fixed sequence of PAPI calls and return the results.
+func traceWorkload(t *testing.T) (name string, hw HardwareInfo, values []int64) {
+	name = TOT_INS.String()
+	hw = GetHardwareInfo()
+	events, err := CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = events.AddEvents([]Event{TOT_INS, TOT_CYC}); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.Start(); err != nil {
+		t.Fatal(err)
+	}
+	performWork(1000)
+	values = make([]int64, 4)
+	if err = events.Read(values[0:2]); err != nil {
+		t.Fatal(err)
+	}
+	performWork(1000)
+	if _, err = events.ReadTS(values[2:4]); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.Stop(values[2:4]); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.CleanupEventSet(); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.DestroyEventSet(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Ensure that replaying a recorded trace reproduces the original
+// results exactly.
+func TestRecordReplay(t *testing.T) {
+	StartRecording()
+	name1, hw1, values1 := traceWorkload(t)
+	trace := StopRecording()
+	if trace == nil || len(trace.Calls) == 0 {
+		t.Fatal("Nothing was recorded")
+	}
+
+	// Round-trip the trace through JSON.
+	var buf bytes.Buffer
+	if err := trace.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	trace, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay the trace and compare.
+	StartReplay(trace)
+	defer StopReplay()
+	name2, hw2, values2 := traceWorkload(t)
+	if name1 != name2 {
+		t.Fatalf("Expected event name %q but saw %q", name1, name2)
+	}
+	if !reflect.DeepEqual(hw1, hw2) {
+		t.Fatalf("Expected hardware information %v but saw %v", hw1, hw2)
+	}
+	if !reflect.DeepEqual(values1, values2) {
+		t.Fatalf("Expected counter values %v but saw %v", values1, values2)
+	}
+}
+
+// Ensure that a replayed call that differs from the recorded call is
+// reported as an error.
+func TestReplayMismatch(t *testing.T) {
+	StartReplay(&Trace{
+		Version: traceVersion,
+		Calls: []Call{
+			{Op: "PAPI_create_eventset", Result: 3},
+			{Op: "PAPI_add_event", EventSet: 3, Event: TOT_INS},
+		},
+	})
+	defer StopReplay()
+	events, err := CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events != 3 {
+		t.Fatalf("Expected event set 3 but saw %d", events)
+	}
+	err = events.AddEvent(TOT_CYC)
+	if _, ok := err.(*ReplayError); !ok {
+		t.Fatalf("Expected a *ReplayError but saw %v", err)
+	}
+	if err = events.Start(); err == nil {
+		t.Fatal("Expected an unrecorded Start() to fail")
+	}
+}
+
+// Ensure that a program that only replays a trace never initializes
+// PAPI.  Because other tests initialize PAPI, the replay runs in a
+// child process.
+func TestReplayWithoutInit(t *testing.T) {
+	if os.Getenv("GO_PAPI_REPLAY_ONLY") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestReplayWithoutInit$")
+		cmd.Env = append(os.Environ(), "GO_PAPI_REPLAY_ONLY=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s\n%s", err, out)
+		}
+		return
+	}
+	StartReplay(&Trace{
+		Version: traceVersion,
+		Calls: []Call{
+			{Op: "PAPI_create_eventset", Result: 3},
+			{Op: "PAPI_add_event", EventSet: 3, Event: TOT_INS, Errno: ENOEVNT.(Errno)},
+		},
+	})
+	defer StopReplay()
+	events, err := CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = events.AddEvent(TOT_INS)
+	if err != ENOEVNT || err.Error() != "PAPI error -7" {
+		t.Fatalf("Expected PAPI error -7 but saw %v", err)
+	}
+	if initialized.Load() {
+		t.Fatal("Replaying a trace initialized PAPI")
+	}
+}
@@ -0,0 +1,126 @@
+// This file tests user-defined events.
+
+package papi
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Define a few user-defined events for testing.  Events can be
+// registered only once per process, and only after the package has
+// been initialized, so they are registered by the first test to need
+// them.
+var (
+	testUserEventsOnce sync.Once
+	testUserEventCodes []Event
+	testUserEventsErr  error
+)
+
+// Return the user-defined events for testing, registering them if
+// necessary.
+func testUserEvents(t *testing.T) []Event {
+	testUserEventsOnce.Do(func() {
+		testUserEventCodes, testUserEventsErr = LoadUserEvents(strings.NewReader(`
+# name, description, formula, terms...
+TEST_DOUBLE_CYC, Twice the number of cycles, N0|2|*|, PAPI_TOT_CYC
+TEST_CYC_PLUS_INS, Cycles plus instructions, N0|N1|+|, PAPI_TOT_CYC, PAPI_TOT_INS
+`))
+	})
+	if testUserEventsErr != nil {
+		t.Fatal(testUserEventsErr)
+	}
+	return testUserEventCodes
+}
+
+// Ensure that user-defined events are named and described like other
+// events.
+func TestUserEventNames(t *testing.T) {
+	double := testUserEvents(t)[0]
+	if double&Event(PRESET_MASK) != 0 || double&Event(NATIVE_MASK) != 0 {
+		t.Fatalf("User-defined event code %#x overlaps PAPI's preset, native, or user-defined event codes", uint32(double))
+	}
+	if double.IsPreset() || !TOT_CYC.IsPreset() {
+		t.Fatal("IsPreset() failed to distinguish a user-defined event from a preset event")
+	}
+	if ename := double.String(); ename != "TEST_DOUBLE_CYC" {
+		t.Fatalf("Expected TEST_DOUBLE_CYC but saw %q", ename)
+	}
+	if ecode, err := StringToEvent("TEST_DOUBLE_CYC"); err != nil || ecode != double {
+		t.Fatalf("StringToEvent(\"TEST_DOUBLE_CYC\") returned %v, %v", ecode, err)
+	}
+	if desc := double.Description(); desc != "Twice the number of cycles" {
+		t.Fatalf("Unexpected description %q", desc)
+	}
+	info, err := GetEventInfo(double)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Postfix != "N0|2|*|" || len(info.Name) != 1 || info.Name[0] != "PAPI_TOT_CYC" {
+		t.Fatalf("Unexpected event info %+v", info)
+	}
+
+	// Test error handling.
+	for _, def := range []UserEvent{
+		{Name: "TEST_DOUBLE_CYC", Formula: "N0|", Terms: []string{"PAPI_TOT_CYC"}},
+		{Name: "PAPI_TOT_CYC", Formula: "N0|", Terms: []string{"PAPI_TOT_CYC"}},
+		{Name: "TEST_BAD_FORMULA", Formula: "N0|+|", Terms: []string{"PAPI_TOT_CYC"}},
+		{Name: "TEST_TOO_FEW_TERMS", Formula: "N0|N1|+|", Terms: []string{"PAPI_TOT_CYC"}},
+		{Name: "TEST_NESTED", Formula: "N0|", Terms: []string{"TEST_DOUBLE_CYC"}},
+	} {
+		if _, err := DefineUserEvent(def); err == nil {
+			t.Fatalf("Expected %+v to be rejected", def)
+		}
+	}
+}
+
+// Ensure that user-defined events can be counted alongside other
+// events.
+func TestUserEventCounting(t *testing.T) {
+	events, err := CreateEventSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.DestroyEventSet()
+	ues := testUserEvents(t)
+	double, sum := ues[0], ues[1]
+	if err = events.AddEvent(TOT_CYC); err != nil {
+		t.Fatal(err)
+	}
+	if err = events.AddEvents([]Event{double, TOT_INS, sum}); err != nil {
+		t.Fatal(err)
+	}
+	ecodes, err := events.ListEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ecodes) != 4 || ecodes[0] != TOT_CYC || ecodes[1] != double || ecodes[2] != TOT_INS || ecodes[3] != sum {
+		t.Fatalf("Unexpected event list %v", ecodes)
+	}
+	if err = events.Start(); err != nil {
+		t.Fatal(err)
+	}
+	performWork(1000)
+	values := make([]int64, 4)
+	if err = events.Stop(values); err != nil {
+		t.Fatal(err)
+	}
+	if values[1] != 2*values[0] {
+		t.Fatalf("Expected %s = 2*%d but saw %d", double, values[0], values[1])
+	}
+	if values[3] != values[0]+values[2] {
+		t.Fatalf("Expected %s = %d+%d but saw %d", sum, values[0], values[2], values[3])
+	}
+
+	// Removing an event should leave the others intact.
+	if err = events.RemoveEvent(double); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := events.NumEvents(); err != nil || n != 3 {
+		t.Fatalf("Expected 3 events but saw %d (%v)", n, err)
+	}
+	if err = events.CleanupEventSet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Package prom exports PAPI counters to Prometheus.
+
+A Collector implements prometheus.Collector.  It owns an event set per
+component for each CPU it is attached to and for each thread that has
+joined one of its thread groups, and it reads those event sets,
+without stopping or resetting them, whenever it is scraped.  Every
+event is exported as a counter named after the event (e.g.,
+papi_tot_ins_total for PAPI_TOT_INS) whose HELP text is the event's
+description from papi.GetEventInfo() and whose labels are
+
+	event      the name of the event
+	component  the name of the component that provides the event
+	cpu        the CPU being counted, or "" for a thread group
+	group      the name of the thread group being counted, or "" for a CPU
+
+Derived metrics from the metrics package, such as metrics.Builtin("IPC"),
+are exported as gauges computed from the cumulative counts and labeled
+only with cpu and group.
+
+A typical use that counts every event on every CPU is
+
+	c, err := prom.New(&prom.Options{
+		Metrics: []*metrics.Metric{metrics.Builtin("IPC")},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = c.AttachCPUs(cpus...); err != nil {
+		log.Fatal(err)
+	}
+	prometheus.MustRegister(c)
+
+Counting a CPU typically requires elevated privileges.  Without them,
+goroutines that lock themselves to an OS thread can instead join a
+thread group, whose counts are the sums over every thread that has
+joined it:
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	leave, err := c.Group("workers").Join()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer leave()
+
+A thread's event sets are read from whichever goroutine performs the
+scrape.  PAPI's perf_event component supports this, but other
+components may not.
+*/
+package prom
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/internal/counting"
+	"github.com/lanl/go-papi/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options control New().  The zero value counts
+// papi.DefaultRegionEvents and exports no derived metrics.
+type Options struct {
+	Namespace string            // Prefix of every exported name (default "papi")
+	Events    []papi.Event      // Events to count (default papi.DefaultRegionEvents)
+	Metrics   []*metrics.Metric // Derived metrics to export; the events they require are counted as well
+}
+
+// A Collector exports PAPI counters and derived metrics to Prometheus.
+type Collector struct {
+	counters     *counting.Counters // Event sets being read
+	counterDescs []*prometheus.Desc // Description of each event's counter
+	metrics      []*metrics.Metric  // Derived metrics
+	metricDescs  []*prometheus.Desc // Description of each derived metric's gauge
+}
+
+// Create a collector.  New() determines the component that provides
+// each event and the descriptions of each event but does not count
+// anything until AttachCPUs() is called or a thread joins a Group.
+func New(opts *Options) (*Collector, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Namespace == "" {
+		o.Namespace = "papi"
+	}
+	if o.Events == nil {
+		o.Events = papi.DefaultRegionEvents
+	}
+	events := append(append([]papi.Event(nil), o.Events...), metrics.Events(o.Metrics...)...)
+	counters, err := counting.New(events)
+	if err != nil {
+		return nil, fmt.Errorf("prom: %w", err)
+	}
+	c := &Collector{counters: counters, metrics: o.Metrics}
+
+	// Describe each event.
+	names := make(map[string]string)
+	labels := []string{"event", "component", "cpu", "group"}
+	for _, ev := range counters.Events {
+		name := prometheus.BuildFQName(o.Namespace, "", counting.Sanitize(ev.String())+"_total")
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("prom: %s and %s would both be exported as %s", other, ev, name)
+		}
+		names[name] = ev.String()
+		c.counterDescs = append(c.counterDescs, prometheus.NewDesc(name, counting.Describe(ev), labels, nil))
+	}
+
+	// Describe each derived metric.
+	for _, m := range c.metrics {
+		name := prometheus.BuildFQName(o.Namespace, "", counting.Sanitize(m.Name))
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("prom: %s and %s would both be exported as %s", other, m.Name, name)
+		}
+		names[name] = m.Name
+		desc := m.Description
+		if desc == "" {
+			desc = m.Expr
+		}
+		c.metricDescs = append(c.metricDescs, prometheus.NewDesc(name, desc, []string{"cpu", "group"}, nil))
+	}
+	return c, nil
+}
+
+// Start counting every event on each of the given CPUs, including
+// every thread of every process that runs there.
+func (c *Collector) AttachCPUs(cpus ...int) error {
+	if err := c.counters.AttachCPUs(cpus...); err != nil {
+		return fmt.Errorf("prom: %w", err)
+	}
+	return nil
+}
+
+// Stop counting on every CPU to which the collector is attached.
+// Close() does not affect thread groups, whose threads must leave
+// their groups themselves.
+func (c *Collector) Close() error {
+	if _, err := c.counters.Close(); err != nil {
+		return fmt.Errorf("prom: %w", err)
+	}
+	return nil
+}
+
+// Describe sends the descriptions of every counter and derived
+// metric the collector exports.  It implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.counterDescs {
+		ch <- d
+	}
+	for _, d := range c.metricDescs {
+		ch <- d
+	}
+}
+
+// Collect reads every CPU's and every thread group's counters and
+// sends their values and the derived metrics computed from them.  It
+// implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, smp := range c.counters.Read() {
+		c.send(ch, smp)
+	}
+}
+
+// Send the counters and derived metrics of a single CPU or thread
+// group.
+func (c *Collector) send(ch chan<- prometheus.Metric, smp counting.Sample) {
+	if smp.Err != nil {
+		ch <- prometheus.NewInvalidMetric(c.counterDescs[0], smp.Err)
+		return
+	}
+	cpu := ""
+	if smp.CPU >= 0 {
+		cpu = strconv.Itoa(smp.CPU)
+	}
+	events := c.counters.Events
+	for i, ev := range events {
+		ch <- prometheus.MustNewConstMetric(c.counterDescs[i], prometheus.CounterValue,
+			float64(smp.Values[i]), ev.String(), c.counters.Components[i], cpu, smp.Group)
+	}
+	counts := metrics.Counts(events, smp.Values)
+	for i, m := range c.metrics {
+		v, err := m.Eval(counts)
+		if err != nil {
+			continue // Typically a division by zero before any counting
+		}
+		ch <- prometheus.MustNewConstMetric(c.metricDescs[i], prometheus.GaugeValue, v, cpu, smp.Group)
+	}
+}
+
+// ----------------------------------------------------------------------
+
+// A Group is a named set of threads whose counts are summed.
+type Group struct {
+	g *counting.Group
+}
+
+// Return the thread group with a given name, creating it if
+// necessary.  A group is exported once it has been created, even if
+// no thread has joined it yet.
+func (c *Collector) Group(name string) *Group {
+	return &Group{c.counters.Group(name)}
+}
+
+// Start counting every event in the calling thread and add the counts
+// to the group's.  The caller must be locked to its OS thread (see
+// runtime.LockOSThread()) until it calls the returned function, which
+// stops counting and must be called from the same thread.
+func (g *Group) Join() (leave func() error, err error) {
+	leave, err = g.g.Join()
+	if err != nil {
+		return nil, fmt.Errorf("prom: %w", err)
+	}
+	return leave, nil
+}
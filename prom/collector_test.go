@@ -0,0 +1,104 @@
+// This file tests the Prometheus collector.
+
+package prom
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/lanl/go-papi"
+	"github.com/lanl/go-papi/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Do a bit of work to give the counters something to count.
+func performWork() float64 {
+	x := 1.0
+	for i := 0; i < 1000000; i++ {
+		x = x*1.0000001 + 1e-9
+	}
+	return x
+}
+
+// Return the value of the sample of a named metric family whose labels
+// include the given label value, or -1 if there is no such sample.
+func sampleValue(t *testing.T, reg *prometheus.Registry, family, label, value string) float64 {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != family {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == label && lp.GetValue() == value {
+					if mf.GetType() == dto.MetricType_COUNTER {
+						return m.GetCounter().GetValue()
+					}
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// Ensure that a thread group's counters can be scraped repeatedly and
+// remain monotonic after its thread leaves.
+func TestGroup(t *testing.T) {
+	c, err := New(&Options{
+		Events:  []papi.Event{papi.TOT_INS},
+		Metrics: []*metrics.Metric{metrics.Builtin("IPC")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	leave, err := c.Group("test").Join()
+	if err != nil {
+		t.Fatal(err)
+	}
+	performWork()
+	first := sampleValue(t, reg, "papi_tot_ins_total", "group", "test")
+	if first <= 0 {
+		t.Fatalf("Expected a positive instruction count but saw %g", first)
+	}
+	if sampleValue(t, reg, "papi_tot_cyc_total", "group", "test") <= 0 {
+		t.Fatal("Expected IPC's events to be counted as well")
+	}
+	if ipc := sampleValue(t, reg, "papi_ipc", "group", "test"); ipc <= 0 {
+		t.Fatalf("Expected a positive IPC but saw %g", ipc)
+	}
+	performWork()
+	if err = leave(); err != nil {
+		t.Fatal(err)
+	}
+	if last := sampleValue(t, reg, "papi_tot_ins_total", "group", "test"); last < first {
+		t.Fatalf("Instruction count decreased from %g to %g", first, last)
+	}
+}
+
+// Ensure that a collector can count on a CPU.
+func TestAttachCPUs(t *testing.T) {
+	c, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AttachCPUs(0); err != nil {
+		t.Skipf("Unable to count on CPU 0: %v", err)
+	}
+	defer c.Close()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	performWork()
+	if v := sampleValue(t, reg, "papi_tot_cyc_total", "cpu", "0"); v < 0 {
+		t.Fatal("No cycle count was reported for CPU 0")
+	}
+}
@@ -0,0 +1,235 @@
+// This file measures a machine's roofline ceilings with
+// micro-benchmarks.  The benchmarks are written in plain Go, so the
+// ceilings they report are those attainable by Go code, which the Go
+// compiler does not vectorize, rather than the processor's
+// theoretical peaks.
+
+package roofline
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/lanl/go-papi"
+)
+
+// Ceilings are the performance limits that form a roofline.
+type Ceilings struct {
+	Threads    int         `json:"threads"`     // Number of goroutines that ran each benchmark
+	PeakGFlops float64     `json:"peak_gflops"` // Peak rate in billions of floating-point operations per second
+	Bandwidth  []Bandwidth `json:"bandwidth"`   // Bandwidth ceiling of each level of the memory hierarchy
+}
+
+// Bandwidth is the measured read bandwidth of a single level of the
+// memory hierarchy.
+type Bandwidth struct {
+	Level        Level   `json:"level"`          // Level of the memory hierarchy
+	BufferBytes  int64   `json:"buffer_bytes"`   // Per-goroutine buffer size used to exercise the level
+	GBytesPerSec float64 `json:"gbytes_per_sec"` // Bandwidth in billions of bytes per second
+}
+
+// Options control MeasureCeilings().  The zero value selects the
+// defaults.
+type Options struct {
+	Duration time.Duration // Minimum time to spend on each benchmark (default 100ms)
+	Threads  int           // Number of concurrent goroutines (default 1)
+}
+
+// minDRAMBuffer is the smallest buffer used to measure DRAM bandwidth.
+const minDRAMBuffer = 16 << 20
+
+// Return the size in bytes of the data or unified cache at a given
+// level of the memory hierarchy or 0 if there is no such cache.
+func cacheSize(hw papi.HardwareInfo, level Level) int64 {
+	if int(level) >= len(hw.MemHierarchy) {
+		return 0
+	}
+	for _, c := range hw.MemHierarchy[level].Cache {
+		switch c.CacheType() {
+		case papi.MH_TYPE_DATA, papi.MH_TYPE_UNIFIED:
+			if c.Size > 0 {
+				return int64(c.Size)
+			}
+		}
+	}
+	return 0
+}
+
+// Measure the peak floating-point rate and the bandwidth of each cache
+// level described by hw.MemHierarchy plus DRAM.  Each cache is
+// exercised with a buffer half its size; DRAM is exercised with a
+// buffer four times the size of the largest cache.  When Threads > 1,
+// every goroutine uses its own buffer, so the buffers for shared
+// caches should be considered when choosing the thread count.
+func MeasureCeilings(hw papi.HardwareInfo, opts *Options) *Ceilings {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Duration <= 0 {
+		o.Duration = 100 * time.Millisecond
+	}
+	if o.Threads <= 0 {
+		o.Threads = 1
+	}
+	c := &Ceilings{Threads: o.Threads}
+
+	// Measure the peak floating-point rate.
+	flops, secs := runParallel(o.Threads, func() (float64, float64) {
+		n, secs := calls(o.Duration, func() { sink(flopKernel()) })
+		return flopsPerCall * float64(n), secs
+	})
+	c.PeakGFlops = flops / secs / 1e9
+
+	// Measure the bandwidth of each level.
+	largest := int64(0)
+	for level := L1; level < DRAM; level++ {
+		size := cacheSize(hw, level)
+		if size == 0 {
+			continue
+		}
+		if size > largest {
+			largest = size
+		}
+		c.Bandwidth = append(c.Bandwidth, measureBandwidth(o, level, size/2))
+	}
+	dram := 4 * largest
+	if dram < minDRAMBuffer {
+		dram = minDRAMBuffer
+	}
+	c.Bandwidth = append(c.Bandwidth, measureBandwidth(o, DRAM, dram))
+	return c
+}
+
+// Return the bandwidth ceiling for a given level or 0 if the level
+// was not measured.
+func (c *Ceilings) BandwidthOf(level Level) float64 {
+	for _, b := range c.Bandwidth {
+		if b.Level == level {
+			return b.GBytesPerSec
+		}
+	}
+	return 0
+}
+
+// Return the attainable rate in GFLOP/s at a given arithmetic
+// intensity with respect to a given level: the lesser of the peak
+// floating-point rate and the level's bandwidth times the intensity.
+func (c *Ceilings) Attainable(level Level, intensity float64) float64 {
+	bw := c.BandwidthOf(level) * intensity
+	if bw < c.PeakGFlops {
+		return bw
+	}
+	return c.PeakGFlops
+}
+
+// Measure the read bandwidth of one level of the memory hierarchy.
+func measureBandwidth(o Options, level Level, size int64) Bandwidth {
+	n := int(size / 8)
+	if n < 1 {
+		n = 1
+	}
+	bytes, secs := runParallel(o.Threads, func() (float64, float64) {
+		buf := make([]float64, n)
+		for i := range buf {
+			buf[i] = float64(i)
+		}
+		sweeps, secs := calls(o.Duration, func() { sink(readKernel(buf)) })
+		return float64(sweeps) * float64(8*n), secs
+	})
+	return Bandwidth{Level: level, BufferBytes: int64(8 * n), GBytesPerSec: bytes / secs / 1e9}
+}
+
+// Run a benchmark concurrently in multiple goroutines, each locked to
+// its own OS thread.  The benchmark returns a quantity of work and the
+// time in seconds it took.  Return the sum of the work and the longest
+// time taken by any goroutine.
+func runParallel(threads int, bench func() (float64, float64)) (total, longest float64) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			result, secs := bench()
+			mu.Lock()
+			total += result
+			if secs > longest {
+				longest = secs
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+// Call a function repeatedly for at least a given duration.  Return
+// the number of calls and their total time in seconds.  The first
+// call, which warms caches, is not counted.
+func calls(d time.Duration, f func()) (n int64, secs float64) {
+	f()
+	start := time.Now()
+	for n == 0 || time.Since(start) < d {
+		f()
+		n++
+	}
+	return n, time.Since(start).Seconds()
+}
+
+// Prevent the compiler from discarding benchmark results.
+var sinkMu sync.Mutex
+var sinkValue float64
+
+func sink(x float64) {
+	sinkMu.Lock()
+	sinkValue += x
+	sinkMu.Unlock()
+}
+
+// flopIters is the number of iterations of flopKernel()'s inner loop.
+const flopIters = 1 << 14
+
+// flopsPerCall is the number of floating-point operations performed
+// by one call to flopKernel(): eight independent multiply-add chains
+// per iteration.
+const flopsPerCall = 16 * flopIters
+
+// Perform flopsPerCall floating-point operations.  Independent chains
+// keep the floating-point units busy despite operation latency.
+func flopKernel() float64 {
+	const m, a = 0.9999999, 1e-7
+	x0, x1, x2, x3 := 1.0, 2.0, 3.0, 4.0
+	x4, x5, x6, x7 := 5.0, 6.0, 7.0, 8.0
+	for i := 0; i < flopIters; i++ {
+		x0 = x0*m + a
+		x1 = x1*m + a
+		x2 = x2*m + a
+		x3 = x3*m + a
+		x4 = x4*m + a
+		x5 = x5*m + a
+		x6 = x6*m + a
+		x7 = x7*m + a
+	}
+	return x0 + x1 + x2 + x3 + x4 + x5 + x6 + x7
+}
+
+// Read every element of a buffer.  Independent accumulators keep
+// multiple loads in flight.
+func readKernel(buf []float64) float64 {
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+3 < len(buf); i += 4 {
+		s0 += buf[i]
+		s1 += buf[i+1]
+		s2 += buf[i+2]
+		s3 += buf[i+3]
+	}
+	for ; i < len(buf); i++ {
+		s0 += buf[i]
+	}
+	return s0 + s1 + s2 + s3
+}
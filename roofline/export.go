@@ -0,0 +1,63 @@
+// This file exports roofline data in formats suitable for plotting.
+
+package roofline
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// A Roofline combines a machine's ceilings with the kernels placed
+// beneath them.
+type Roofline struct {
+	Machine  string    `json:"machine,omitempty"`  // Description of the machine (e.g., its model name)
+	Ceilings *Ceilings `json:"ceilings,omitempty"` // Machine ceilings, if measured
+	Points   []*Point  `json:"points"`             // Kernels
+}
+
+// Write a roofline to a stream in JSON format.
+func (r *Roofline) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Read a roofline written by Roofline.WriteJSON().
+func ReadJSON(r io.Reader) (*Roofline, error) {
+	rl := new(Roofline)
+	if err := json.NewDecoder(r).Decode(rl); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// csvHeader names the columns written by Roofline.WriteCSV().
+var csvHeader = []string{"kind", "name", "level", "intensity", "gflops", "gbytes_per_sec"}
+
+// Write a roofline to a stream in CSV format.  Each row is either a
+// ceiling or a point.  The peak floating-point ceiling is a row of
+// kind "peak" with its rate in the gflops column.  Each bandwidth
+// ceiling is a row of kind "bandwidth" with its rate in the
+// gbytes_per_sec column.  Each point contributes one row of kind
+// "point" per level of memory traffic measured, with its arithmetic
+// intensity and achieved rate.  Columns that do not apply are empty.
+func (r *Roofline) WriteCSV(w io.Writer) error {
+	f := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	if c := r.Ceilings; c != nil {
+		cw.Write([]string{"peak", "peak", "", "", f(c.PeakGFlops), ""})
+		for _, b := range c.Bandwidth {
+			cw.Write([]string{"bandwidth", b.Level.String(), b.Level.String(), "", "", f(b.GBytesPerSec)})
+		}
+	}
+	for _, p := range r.Points {
+		for _, t := range p.Traffic {
+			cw.Write([]string{"point", p.Name, t.Level.String(), f(t.Intensity), f(p.GFlops), ""})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
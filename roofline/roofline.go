@@ -0,0 +1,287 @@
+/*
+Package roofline collects the data needed to place a kernel on a
+roofline plot: the kernel's achieved floating-point rate and its
+arithmetic intensity (floating-point operations per byte) with respect
+to each level of the memory hierarchy, along with the machine's peak
+floating-point rate and per-level bandwidth ceilings.
+
+Measure() runs a function and counts its floating-point operations and
+memory traffic.  Traffic at each level is estimated from preset
+events: loads and stores times the operand size for L1, and misses at
+the level above times that level's line size for L2, L3, and DRAM.
+Levels whose events are unavailable are omitted.  FromRegion() computes
+the same quantities from a region measured with papi.Begin() and
+papi.Region.End(), provided that the region counted RegionEvents().
+MeasureCeilings() runs micro-benchmarks sized by the caches described
+in papi.HardwareInfo.MemHierarchy.  A Roofline combines ceilings and
+points and can be written as JSON or CSV for plotting.
+*/
+package roofline
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/lanl/go-papi"
+)
+
+// A Level is a level of the memory hierarchy.
+type Level int
+
+const (
+	L1 Level = iota
+	L2
+	L3
+	DRAM
+	numLevels
+)
+
+// Names of each level
+var levelNames = [numLevels]string{"L1", "L2", "L3", "DRAM"}
+
+// Return the name of a memory-hierarchy level.
+func (l Level) String() string {
+	if l < 0 || l >= numLevels {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// Represent a level by its name in JSON.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Parse a level from its name.
+func (l *Level) UnmarshalText(text []byte) error {
+	for i, name := range levelNames {
+		if name == string(text) {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("roofline: unknown memory-hierarchy level %q", text)
+}
+
+// OperandSize is the number of bytes assumed to be moved by each load
+// or store instruction when estimating L1 traffic.
+var OperandSize = 8
+
+// defaultLineSize is the cache line size assumed when PAPI does not
+// report one.
+const defaultLineSize = 64
+
+// A Point is a single kernel's position on a roofline plot.
+type Point struct {
+	Name    string    `json:"name"`    // Name of the kernel
+	Flops   int64     `json:"flops"`   // Floating-point operations performed
+	Seconds float64   `json:"seconds"` // Elapsed time per execution
+	GFlops  float64   `json:"gflops"`  // Achieved rate in billions of floating-point operations per second
+	Traffic []Traffic `json:"traffic"` // Memory traffic at each level that could be measured
+}
+
+// Traffic describes the data moved to or from a single level of the
+// memory hierarchy.
+type Traffic struct {
+	Level     Level   `json:"level"`     // Level of the memory hierarchy
+	Bytes     int64   `json:"bytes"`     // Estimated bytes moved
+	Intensity float64 `json:"intensity"` // Floating-point operations per byte
+}
+
+// ErrNoFlops is returned when no floating-point event can be counted.
+var ErrNoFlops = errors.New("roofline: neither PAPI_FP_OPS nor PAPI_DP_OPS can be counted")
+
+// A quantity is something to be measured by the first countable event
+// in a list of candidates.
+type quantity struct {
+	level      Level        // Level whose traffic is measured (unused for flops)
+	candidates []papi.Event // Events to try, in order of preference
+}
+
+// The floating-point quantity and the traffic quantity for each level
+var (
+	flopQuantity      = quantity{candidates: []papi.Event{papi.FP_OPS, papi.DP_OPS}}
+	trafficQuantities = []quantity{
+		{L1, []papi.Event{papi.LST_INS, papi.L1_DCA}},
+		{L2, []papi.Event{papi.L1_DCM, papi.L1_TCM}},
+		{L3, []papi.Event{papi.L2_DCM, papi.L2_TCM}},
+		{DRAM, []papi.Event{papi.L3_TCM, papi.L3_DCM}},
+	}
+)
+
+// Return the events a region must count for FromRegion() to place it
+// on a roofline.  Pass these to papi.SetRegionEvents() before the
+// first call to papi.Begin().  Only the first candidate for each
+// quantity is returned; events the processor cannot count should be
+// removed by the caller.
+func RegionEvents() []papi.Event {
+	events := []papi.Event{flopQuantity.candidates[0]}
+	for _, q := range trafficQuantities {
+		events = append(events, q.candidates[0])
+	}
+	return events
+}
+
+// Return the line size in bytes of the data or unified cache at a
+// given level of the memory hierarchy.
+func lineSize(hw papi.HardwareInfo, level Level) int64 {
+	if int(level) < len(hw.MemHierarchy) {
+		for _, c := range hw.MemHierarchy[level].Cache {
+			switch c.CacheType() {
+			case papi.MH_TYPE_DATA, papi.MH_TYPE_UNIFIED:
+				if c.LineSize > 0 {
+					return int64(c.LineSize)
+				}
+			}
+		}
+	}
+	return defaultLineSize
+}
+
+// Convert a count of the event measuring a level's traffic to bytes.
+// Traffic into level N is the misses from level N-1 times level N-1's
+// line size.
+func bytesOf(hw papi.HardwareInfo, level Level, count int64) int64 {
+	if level == L1 {
+		return count * int64(OperandSize)
+	}
+	return count * lineSize(hw, level-1)
+}
+
+// Construct a point from event counts and elapsed time.
+func newPoint(name string, hw papi.HardwareInfo, counts map[papi.Event]int64, seconds float64) (*Point, error) {
+	p := &Point{Name: name, Seconds: seconds}
+	found := false
+	for _, ev := range flopQuantity.candidates {
+		if n, ok := counts[ev]; ok {
+			p.Flops, found = n, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNoFlops
+	}
+	if seconds > 0 {
+		p.GFlops = float64(p.Flops) / seconds / 1e9
+	}
+	for _, q := range trafficQuantities {
+		for _, ev := range q.candidates {
+			n, ok := counts[ev]
+			if !ok {
+				continue
+			}
+			t := Traffic{Level: q.level, Bytes: bytesOf(hw, q.level, n)}
+			if t.Bytes > 0 {
+				t.Intensity = float64(p.Flops) / float64(t.Bytes)
+			}
+			p.Traffic = append(p.Traffic, t)
+			break
+		}
+	}
+	return p, nil
+}
+
+// Place an aggregated region on a roofline.  The region must have
+// counted PAPI_FP_OPS or PAPI_DP_OPS; traffic is reported for each
+// level whose event the region counted.  Inclusive counts are used,
+// and the elapsed time is the mean time per region instance.
+func FromRegion(stats papi.RegionStats) (*Point, error) {
+	counts := make(map[papi.Event]int64, len(stats.Events))
+	for i, ev := range stats.Events {
+		counts[ev] = stats.Inclusive[i]
+	}
+	seconds := 0.0
+	if stats.Count > 0 {
+		seconds = float64(stats.InclusiveUsec) / 1e6 / float64(stats.Count)
+		for ev := range counts {
+			counts[ev] /= int64(stats.Count)
+		}
+	}
+	return newPoint(stats.Name, papi.GetHardwareInfo(), counts, seconds)
+}
+
+// Measure a kernel and place it on a roofline.  work may be called
+// more than once if the processor cannot count all of the required
+// events at once and should perform the same computation each time.
+// The reported time is the mean over all calls.  work runs on the
+// calling goroutine, which is locked to its OS thread until Measure()
+// returns because each pass counts only that thread.
+func Measure(name string, work func()) (*Point, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	pending := append([]quantity{flopQuantity}, trafficQuantities...)
+	counts := make(map[papi.Event]int64)
+	var elapsed time.Duration
+	passes := 0
+	for len(pending) > 0 {
+		var err error
+		var d time.Duration
+		pending, d, err = measurePass(pending, counts, work)
+		if err != nil {
+			return nil, fmt.Errorf("roofline: %s: %w", name, err)
+		}
+		if d < 0 {
+			break // Nothing more could be counted.
+		}
+		elapsed += d
+		passes++
+	}
+	seconds := 0.0
+	if passes > 0 {
+		seconds = elapsed.Seconds() / float64(passes)
+	}
+	return newPoint(name, papi.GetHardwareInfo(), counts, seconds)
+}
+
+// Count as many pending quantities as fit in a single event set while
+// running a workload once, storing the results in counts.  Return the
+// quantities that must wait for a later pass and the workload's
+// elapsed time.  A negative duration indicates that none of the
+// pending quantities could be counted and the workload was not run.
+func measurePass(pending []quantity, counts map[papi.Event]int64, work func()) (remaining []quantity, elapsed time.Duration, err error) {
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return
+	}
+	defer es.DestroyEventSet()
+	defer es.CleanupEventSet()
+	var events []papi.Event
+	for _, q := range pending {
+		added, deferred := false, false
+		for _, ev := range q.candidates {
+			switch aerr := es.AddEvent(ev); {
+			case aerr == nil:
+				added = true
+			case errors.Is(aerr, papi.ECNFLCT) || errors.Is(aerr, papi.ECOUNT):
+				deferred = true
+				continue
+			default:
+				continue
+			}
+			events = append(events, ev)
+			break
+		}
+		if !added && deferred {
+			remaining = append(remaining, q)
+		}
+	}
+	if len(events) == 0 {
+		return nil, -1, nil
+	}
+	values := make([]int64, len(events))
+	if err = es.Start(); err != nil {
+		return
+	}
+	start := time.Now()
+	work()
+	elapsed = time.Since(start)
+	if err = es.Stop(values); err != nil {
+		return
+	}
+	for i, ev := range events {
+		counts[ev] = values[i]
+	}
+	return remaining, elapsed, nil
+}
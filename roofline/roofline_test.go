@@ -0,0 +1,157 @@
+// This file tests roofline data collection and export.
+
+package roofline
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lanl/go-papi"
+)
+
+// A small machine description with 32 KiB L1 and 256 KiB L2 caches
+var testHardware = papi.HardwareInfo{
+	MemHierarchy: []papi.MHLevelInfo{
+		{Cache: []papi.CacheInfo{
+			{Type: papi.MH_TYPE_INST, Size: 32 << 10, LineSize: 32},
+			{Type: papi.MH_TYPE_DATA, Size: 32 << 10, LineSize: 64},
+		}},
+		{Cache: []papi.CacheInfo{
+			{Type: papi.MH_TYPE_UNIFIED, Size: 256 << 10, LineSize: 128},
+		}},
+	},
+}
+
+// Ensure that points are computed correctly from known counts.
+func TestNewPoint(t *testing.T) {
+	counts := map[papi.Event]int64{
+		papi.FP_OPS:  8000,
+		papi.LST_INS: 500,  // 4000 bytes at 8 bytes per operand
+		papi.L1_DCM:  100,  // 6400 bytes at 64 bytes per line
+		papi.L2_TCM:  10,   // 1280 bytes at 128 bytes per line
+		papi.L3_TCM:  1000, // 64000 bytes at the default line size
+	}
+	p, err := newPoint("test", testHardware, counts, 2e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Flops != 8000 || math.Abs(p.GFlops-4) > 1e-9 {
+		t.Fatalf("Expected 8000 FLOPs at 4 GFLOP/s but saw %d at %g", p.Flops, p.GFlops)
+	}
+	want := []Traffic{{L1, 4000, 2}, {L2, 6400, 1.25}, {L3, 1280, 6.25}, {DRAM, 64000, 0.125}}
+	if len(p.Traffic) != len(want) {
+		t.Fatalf("Expected %v but saw %v", want, p.Traffic)
+	}
+	for i, tr := range p.Traffic {
+		if tr.Level != want[i].Level || tr.Bytes != want[i].Bytes || math.Abs(tr.Intensity-want[i].Intensity) > 1e-9 {
+			t.Fatalf("Expected %v but saw %v", want[i], tr)
+		}
+	}
+
+	// Floating-point operations are required.
+	delete(counts, papi.FP_OPS)
+	if _, err = newPoint("test", testHardware, counts, 1); err != ErrNoFlops {
+		t.Fatalf("Expected ErrNoFlops but saw %v", err)
+	}
+}
+
+// Ensure that ceilings are measured for each cache level and DRAM.
+func TestMeasureCeilings(t *testing.T) {
+	c := MeasureCeilings(testHardware, &Options{Duration: time.Millisecond})
+	if c.PeakGFlops <= 0 {
+		t.Fatalf("Expected a positive peak but saw %g", c.PeakGFlops)
+	}
+	levels := []Level{L1, L2, DRAM}
+	if len(c.Bandwidth) != len(levels) {
+		t.Fatalf("Expected %d bandwidth ceilings but saw %d", len(levels), len(c.Bandwidth))
+	}
+	for i, b := range c.Bandwidth {
+		if b.Level != levels[i] || b.GBytesPerSec <= 0 {
+			t.Fatalf("Unexpected bandwidth ceiling %+v", b)
+		}
+	}
+	if c.Bandwidth[0].BufferBytes != 16<<10 || c.Bandwidth[2].BufferBytes != minDRAMBuffer {
+		t.Fatalf("Unexpected buffer sizes in %+v", c.Bandwidth)
+	}
+	if got := c.Attainable(L1, 1e-12); got >= c.PeakGFlops {
+		t.Fatalf("Expected a low intensity to be bandwidth bound but saw %g", got)
+	}
+	if got := c.Attainable(L1, 1e12); got != c.PeakGFlops {
+		t.Fatalf("Expected a high intensity to be compute bound but saw %g", got)
+	}
+}
+
+// Ensure that a function can be measured.
+func TestMeasure(t *testing.T) {
+	calls := 0
+	p, err := Measure("kernel", func() { calls++ })
+	if err != nil {
+		t.Skipf("Floating-point operations cannot be counted: %s", err)
+	}
+	if calls == 0 || p.Name != "kernel" {
+		t.Fatalf("Unexpected point %+v after %d calls", p, calls)
+	}
+}
+
+// Ensure that rooflines can be exported and reimported.
+func TestExport(t *testing.T) {
+	rl := &Roofline{
+		Machine: "test",
+		Ceilings: &Ceilings{
+			Threads:    1,
+			PeakGFlops: 10,
+			Bandwidth:  []Bandwidth{{L1, 16384, 100}, {DRAM, 1 << 24, 10}},
+		},
+		Points: []*Point{{
+			Name:    "kernel",
+			Flops:   100,
+			Seconds: 1e-6,
+			GFlops:  0.1,
+			Traffic: []Traffic{{L1, 800, 0.125}, {DRAM, 64, 1.5625}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := rl.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rl2, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j1, _ := json.Marshal(rl)
+	j2, _ := json.Marshal(rl2)
+	if !bytes.Equal(j1, j2) {
+		t.Fatalf("JSON round trip changed %s to %s", j1, j2)
+	}
+
+	buf.Reset()
+	if err = rl.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"peak", "peak", "", "", "10", ""},
+		{"bandwidth", "L1", "L1", "", "", "100"},
+		{"bandwidth", "DRAM", "DRAM", "", "", "10"},
+		{"point", "kernel", "L1", "0.125", "0.1", ""},
+		{"point", "kernel", "DRAM", "1.5625", "0.1", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d CSV rows but saw %d", len(want), len(rows))
+	}
+	for i := range rows {
+		for j := range rows[i] {
+			if rows[i][j] != want[i][j] {
+				t.Fatalf("Expected row %v but saw %v", want[i], rows[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// This file writes a timeline in Perfetto's protobuf trace format.  The
+// few messages required are encoded by hand to avoid depending on
+// generated protobuf code; field numbers are those of
+// protos/perfetto/trace/trace_packet.proto and its imports.
+
+package timeline
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/lanl/go-papi"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// Field numbers of the messages used
+const (
+	traceFieldPacket = 1 // Trace.packet
+
+	packetFieldTimestamp       = 8  // TracePacket.timestamp
+	packetFieldSequenceID      = 10 // TracePacket.trusted_packet_sequence_id
+	packetFieldTrackEvent      = 11 // TracePacket.track_event
+	packetFieldSequenceFlags   = 13 // TracePacket.sequence_flags
+	packetFieldTrackDescriptor = 60 // TracePacket.track_descriptor
+
+	trackFieldUUID       = 1 // TrackDescriptor.uuid
+	trackFieldName       = 2 // TrackDescriptor.name
+	trackFieldProcess    = 3 // TrackDescriptor.process
+	trackFieldThread     = 4 // TrackDescriptor.thread
+	trackFieldParentUUID = 5 // TrackDescriptor.parent_uuid
+	trackFieldCounter    = 8 // TrackDescriptor.counter
+
+	processFieldPID  = 1 // ProcessDescriptor.pid
+	processFieldName = 6 // ProcessDescriptor.process_name
+
+	threadFieldPID  = 1 // ThreadDescriptor.pid
+	threadFieldTID  = 2 // ThreadDescriptor.tid
+	threadFieldName = 5 // ThreadDescriptor.thread_name
+
+	eventFieldAnnotation  = 4  // TrackEvent.debug_annotations
+	eventFieldType        = 9  // TrackEvent.type
+	eventFieldTrackUUID   = 11 // TrackEvent.track_uuid
+	eventFieldCategory    = 22 // TrackEvent.categories
+	eventFieldName        = 23 // TrackEvent.name
+	eventFieldDoubleValue = 44 // TrackEvent.double_counter_value
+
+	annotationFieldInt  = 4  // DebugAnnotation.int_value
+	annotationFieldName = 10 // DebugAnnotation.name
+)
+
+// Enumeration values of the messages used
+const (
+	eventTypeSliceBegin   = 1 // TrackEvent.TYPE_SLICE_BEGIN
+	eventTypeSliceEnd     = 2 // TrackEvent.TYPE_SLICE_END
+	eventTypeCounter      = 4 // TrackEvent.TYPE_COUNTER
+	seqIncrementalCleared = 1 // TracePacket.SEQ_INCREMENTAL_STATE_CLEARED
+)
+
+// sequenceID is the trusted_packet_sequence_id of every packet.
+const sequenceID = 1
+
+// A message accumulates an encoded protobuf message.
+type message []byte
+
+// Append a field's tag.
+func (m message) tag(field, wire int) message {
+	return binary.AppendUvarint(m, uint64(field)<<3|uint64(wire))
+}
+
+// Append an integer field.
+func (m message) varint(field int, v uint64) message {
+	return binary.AppendUvarint(m.tag(field, wireVarint), v)
+}
+
+// Append a floating-point field.
+func (m message) double(field int, v float64) message {
+	return binary.LittleEndian.AppendUint64(m.tag(field, wireFixed64), math.Float64bits(v))
+}
+
+// Append a string or embedded-message field.
+func (m message) bytes(field int, b []byte) message {
+	m = binary.AppendUvarint(m.tag(field, wireBytes), uint64(len(b)))
+	return append(m, b...)
+}
+
+// Append a string field.
+func (m message) str(field int, s string) message {
+	return m.bytes(field, []byte(s))
+}
+
+// A packet is a TracePacket waiting to be written.
+type packet struct {
+	usec int64   // Timestamp in microseconds, or math.MinInt64 for a descriptor
+	body message // Encoded TracePacket, less its timestamp
+}
+
+// Write the timeline in Perfetto's protobuf trace format.  Each thread
+// that ran a region, each CPU that was sampled, and each counter gets
+// its own track beneath a track for the process.
+func (t *Timeline) WritePerfetto(w io.Writer) error {
+	var pkts []packet
+	nextUUID := uint64(0)
+	track := func(desc message) uint64 {
+		nextUUID++
+		desc = append(message(nil).varint(trackFieldUUID, nextUUID), desc...)
+		pkts = append(pkts, packet{usec: math.MinInt64, body: message(nil).bytes(packetFieldTrackDescriptor, desc)})
+		return nextUUID
+	}
+	event := func(usec int64, ev message) {
+		pkts = append(pkts, packet{usec: usec, body: message(nil).bytes(packetFieldTrackEvent, ev)})
+	}
+
+	// Describe the process and its threads.
+	proc := message(nil).varint(processFieldPID, uint64(t.PID)).str(processFieldName, t.processName())
+	procUUID := track(message(nil).bytes(trackFieldProcess, proc))
+	threadUUIDs := make(map[uint64]uint64, len(t.tids))
+	for i, id := range t.tids {
+		thr := message(nil).
+			varint(threadFieldPID, uint64(t.PID)).
+			varint(threadFieldTID, uint64(i+1)).
+			str(threadFieldName, threadName(id))
+		threadUUIDs[id] = track(message(nil).bytes(trackFieldThread, thr))
+	}
+
+	// Represent each region instance as a slice on its thread's track.
+	// Slices on a track must nest, so each thread's open slices are
+	// ended before beginning a slice at the same or a shallower depth.
+	open := make(map[uint64][]papi.RegionSpan)
+	end := func(id uint64, depth int) {
+		stack := open[id]
+		for len(stack) > 0 && stack[len(stack)-1].Depth >= depth {
+			sp := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			event(sp.EndUsec, message(nil).
+				varint(eventFieldType, eventTypeSliceEnd).
+				varint(eventFieldTrackUUID, threadUUIDs[id]))
+		}
+		open[id] = stack
+	}
+	for _, sp := range t.sortedSpans() {
+		end(sp.Thread, sp.Depth)
+		ev := message(nil).
+			varint(eventFieldType, eventTypeSliceBegin).
+			varint(eventFieldTrackUUID, threadUUIDs[sp.Thread]).
+			str(eventFieldCategory, "region").
+			str(eventFieldName, sp.Name)
+		for i, e := range sp.Events {
+			if i < len(sp.Inclusive) {
+				ann := message(nil).str(annotationFieldName, e.String()).varint(annotationFieldInt, uint64(sp.Inclusive[i]))
+				ev = ev.bytes(eventFieldAnnotation, ann)
+			}
+		}
+		event(sp.StartUsec, ev)
+		open[sp.Thread] = append(open[sp.Thread], sp)
+	}
+	for _, id := range t.tids {
+		end(id, 0)
+	}
+
+	// Represent each counter series as a counter track beneath either
+	// the process or a track for the CPU.
+	cpuUUIDs := make(map[int]uint64)
+	for _, key := range t.order {
+		parent := procUUID
+		if key.cpu >= 0 {
+			if _, ok := cpuUUIDs[key.cpu]; !ok {
+				cpuUUIDs[key.cpu] = track(message(nil).varint(trackFieldParentUUID, procUUID).str(trackFieldName, cpuName(key.cpu)))
+			}
+			parent = cpuUUIDs[key.cpu]
+		}
+		uuid := track(message(nil).
+			varint(trackFieldParentUUID, parent).
+			str(trackFieldName, counterName(key.name)).
+			bytes(trackFieldCounter, nil))
+		for _, p := range t.series[key].points {
+			event(p.usec, message(nil).
+				varint(eventFieldType, eventTypeCounter).
+				varint(eventFieldTrackUUID, uuid).
+				double(eventFieldDoubleValue, p.rate))
+		}
+	}
+
+	// Write the descriptors followed by the events in time order.  The
+	// sort is stable to preserve the order of each track's events.
+	sort.SliceStable(pkts, func(i, j int) bool { return pkts[i].usec < pkts[j].usec })
+	var trace message
+	for i, p := range pkts {
+		pkt := message(nil).varint(packetFieldSequenceID, sequenceID)
+		if i == 0 {
+			pkt = pkt.varint(packetFieldSequenceFlags, seqIncrementalCleared)
+		}
+		if p.usec != math.MinInt64 {
+			pkt = pkt.varint(packetFieldTimestamp, uint64(p.usec)*1000)
+		}
+		trace = trace.bytes(traceFieldPacket, append(pkt, p.body...))
+	}
+	_, err := w.Write(trace)
+	return err
+}
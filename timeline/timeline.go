@@ -0,0 +1,241 @@
+/*
+Package timeline exports region measurements and sampled counter time
+series as traces that can be viewed alongside each other in
+chrome://tracing or the Perfetto UI (https://ui.perfetto.dev).
+
+A Timeline collects the region instances retained by
+papi.KeepRegionSpans() and the samples produced by a papi.Sampler.
+Each region instance becomes a slice on the track of the thread that
+ran it, annotated with the region's counts, and each sampled event
+becomes a counter track of the event's rate per second, either for
+the process or beneath a track for each CPU.  The process track is
+labeled with the CPU model from papi.HardwareInfo.  Regions and
+samples are both timestamped with papi.GetRealUsec(), so they line up
+without adjustment.
+
+WriteChrome() writes the Chrome Trace Event format, in which regions
+are complete ("X") events and samples are counter ("C") events.
+WritePerfetto() writes Perfetto's native protobuf trace format, which
+supports per-CPU track groups and loads faster for long runs.
+*/
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/lanl/go-papi"
+)
+
+// A Timeline collects region instances and counter samples for export.
+type Timeline struct {
+	Hardware papi.HardwareInfo // Hardware whose description labels the process track
+	PID      int               // Process ID with which to label the trace
+
+	spans   []papi.RegionSpan     // Region instances, in order of addition
+	threads map[uint64]int        // Trace thread ID of each PAPI thread ID
+	tids    []uint64              // PAPI thread ID of each trace thread ID, less one
+	series  map[seriesKey]*series // Counter series by event and CPU
+	order   []seriesKey           // Counter series in order of first appearance
+}
+
+// A seriesKey identifies a counter series.
+type seriesKey struct {
+	name string // Name of the event
+	cpu  int    // CPU counted, or -1 for a process or thread
+}
+
+// A point is a single value of a counter series.
+type point struct {
+	usec int64   // Real time in microseconds at which the value took effect
+	rate float64 // Event count per second
+}
+
+// A series is the sequence of values of a single counter.
+type series struct {
+	points []point // Values in order of addition
+}
+
+// Create an empty timeline describing the current node and process.
+func New() *Timeline {
+	return &Timeline{Hardware: papi.GetHardwareInfo(), PID: os.Getpid()}
+}
+
+// Add region instances, such as those returned by papi.RegionSpans(),
+// to the timeline.
+func (t *Timeline) AddSpans(spans []papi.RegionSpan) {
+	for _, sp := range spans {
+		t.tid(sp.Thread)
+		t.spans = append(t.spans, sp)
+	}
+}
+
+// Add samples of the given events, such as those received from a
+// papi.Sampler, to the timeline.  Each sample contributes its rates
+// from the beginning of its interval.  Samples with errors are
+// skipped.
+func (t *Timeline) AddSamples(events []papi.Event, smps []papi.Sample) {
+	if t.series == nil {
+		t.series = make(map[seriesKey]*series)
+	}
+	for _, smp := range smps {
+		if smp.Err != nil {
+			continue
+		}
+		for i, ev := range events {
+			if i >= len(smp.Rates) {
+				break
+			}
+			key := seriesKey{name: ev.String(), cpu: smp.CPU}
+			s, ok := t.series[key]
+			if !ok {
+				s = new(series)
+				t.series[key] = s
+				t.order = append(t.order, key)
+			}
+			s.points = append(s.points, point{usec: smp.StartUsec, rate: smp.Rates[i]})
+		}
+	}
+}
+
+// Return the trace thread ID corresponding to a PAPI thread ID.  PAPI
+// thread IDs may be too large to represent as trace thread IDs, so
+// threads are numbered from 1 in order of appearance.
+func (t *Timeline) tid(id uint64) int {
+	if t.threads == nil {
+		t.threads = make(map[uint64]int)
+	}
+	if tid, ok := t.threads[id]; ok {
+		return tid
+	}
+	t.tids = append(t.tids, id)
+	t.threads[id] = len(t.tids)
+	return len(t.tids)
+}
+
+// Return the label of the process track.
+func (t *Timeline) processName() string {
+	if t.Hardware.ModelName == "" {
+		return fmt.Sprintf("process %d", t.PID)
+	}
+	return fmt.Sprintf("process %d on %s (%d CPUs)", t.PID, t.Hardware.ModelName, t.Hardware.TotalCPUs)
+}
+
+// Return the label of a thread track.
+func threadName(id uint64) string {
+	return fmt.Sprintf("PAPI thread %#x", id)
+}
+
+// Return the label of a CPU track.
+func cpuName(cpu int) string {
+	return fmt.Sprintf("CPU %d", cpu)
+}
+
+// Return the label of a counter track.
+func counterName(event string) string {
+	return event + "/s"
+}
+
+// Return the region instances in the order in which they should begin
+// on each thread's track: by start time, with enclosing regions before
+// those they enclose.
+func (t *Timeline) sortedSpans() []papi.RegionSpan {
+	spans := append([]papi.RegionSpan(nil), t.spans...)
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].StartUsec != spans[j].StartUsec {
+			return spans[i].StartUsec < spans[j].StartUsec
+		}
+		return spans[i].Depth < spans[j].Depth
+	})
+	return spans
+}
+
+// ----------------------------------------------------------------------
+
+// A chromeEvent is a single event in the Chrome Trace Event format.
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   int64                  `json:"ts"`
+	Dur  *int64                 `json:"dur,omitempty"`
+	PID  int                    `json:"pid"`
+	TID  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// A chromeTrace is a complete trace in the Chrome Trace Event format.
+type chromeTrace struct {
+	TraceEvents     []chromeEvent     `json:"traceEvents"`
+	DisplayTimeUnit string            `json:"displayTimeUnit"`
+	OtherData       map[string]string `json:"otherData,omitempty"`
+}
+
+// Write the timeline in the Chrome Trace Event JSON format.  The
+// format has no CPU tracks, so the name of each CPU's counter events
+// includes the CPU.
+func (t *Timeline) WriteChrome(w io.Writer) error {
+	tr := chromeTrace{
+		DisplayTimeUnit: "ms",
+		OtherData: map[string]string{
+			"vendor": t.Hardware.VendorName,
+			"model":  t.Hardware.ModelName,
+		},
+	}
+	meta := func(name string, tid int, label string) {
+		tr.TraceEvents = append(tr.TraceEvents, chromeEvent{
+			Name: name,
+			Ph:   "M",
+			PID:  t.PID,
+			TID:  tid,
+			Args: map[string]interface{}{"name": label},
+		})
+	}
+	meta("process_name", 0, t.processName())
+	for i, id := range t.tids {
+		meta("thread_name", i+1, threadName(id))
+	}
+
+	// Represent each region instance as a complete event.
+	for _, sp := range t.sortedSpans() {
+		dur := sp.EndUsec - sp.StartUsec
+		args := make(map[string]interface{}, len(sp.Events))
+		for i, ev := range sp.Events {
+			if i < len(sp.Inclusive) {
+				args[ev.String()] = sp.Inclusive[i]
+			}
+		}
+		tr.TraceEvents = append(tr.TraceEvents, chromeEvent{
+			Name: sp.Name,
+			Cat:  "region",
+			Ph:   "X",
+			Ts:   sp.StartUsec,
+			Dur:  &dur,
+			PID:  t.PID,
+			TID:  t.threads[sp.Thread],
+			Args: args,
+		})
+	}
+
+	// Represent each sample as a counter event.
+	for _, key := range t.order {
+		name := counterName(key.name)
+		if key.cpu >= 0 {
+			name = fmt.Sprintf("%s (%s)", name, cpuName(key.cpu))
+		}
+		for _, p := range t.series[key].points {
+			tr.TraceEvents = append(tr.TraceEvents, chromeEvent{
+				Name: name,
+				Cat:  "counter",
+				Ph:   "C",
+				Ts:   p.usec,
+				PID:  t.PID,
+				Args: map[string]interface{}{"value": p.rate},
+			})
+		}
+	}
+	return json.NewEncoder(w).Encode(tr)
+}
@@ -0,0 +1,203 @@
+// This file tests the export of timelines.
+
+package timeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/lanl/go-papi"
+)
+
+// Return a timeline with two nested regions on one thread, a region on
+// another, and two samples each of a process and a CPU.
+func testTimeline() *Timeline {
+	tl := &Timeline{Hardware: papi.HardwareInfo{ModelName: "Test CPU", TotalCPUs: 4}, PID: 42}
+	events := []papi.Event{papi.TOT_INS}
+	tl.AddSpans([]papi.RegionSpan{
+		{Name: "inner", Thread: 0xdead, Depth: 1, StartUsec: 100, EndUsec: 100, Events: events, Inclusive: []int64{5}},
+		{Name: "outer", Thread: 0xdead, Depth: 0, StartUsec: 100, EndUsec: 300, Events: events, Inclusive: []int64{50}},
+		{Name: "other", Thread: 0xbeef, Depth: 0, StartUsec: 150, EndUsec: 250, Events: events, Inclusive: []int64{20}},
+	})
+	tl.AddSamples(events, []papi.Sample{
+		{Seq: 1, CPU: -1, StartUsec: 0, Usec: 200, Rates: []float64{1e6}},
+		{Seq: 1, CPU: 3, StartUsec: 0, Usec: 200, Rates: []float64{2e6}},
+		{Seq: 2, CPU: -1, StartUsec: 200, Usec: 400, Rates: []float64{3e6}},
+		{Seq: 2, CPU: 3, Err: papi.ENOTRUN},
+	})
+	return tl
+}
+
+// Ensure that a timeline is written as Chrome Trace Event JSON with
+// labeled tracks, complete events for regions, and counter events for
+// samples.
+func TestWriteChrome(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testTimeline().WriteChrome(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var tr struct {
+		TraceEvents []struct {
+			Name string                 `json:"name"`
+			Ph   string                 `json:"ph"`
+			Ts   int64                  `json:"ts"`
+			Dur  int64                  `json:"dur"`
+			PID  int                    `json:"pid"`
+			TID  int                    `json:"tid"`
+			Args map[string]interface{} `json:"args"`
+		} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &tr); err != nil {
+		t.Fatal(err)
+	}
+	phases := make(map[string]int)
+	for _, ev := range tr.TraceEvents {
+		phases[ev.Ph]++
+		if ev.PID != 42 {
+			t.Fatalf("Expected process 42 but saw %+v", ev)
+		}
+		switch {
+		case ev.Name == "process_name" && ev.Args["name"] != "process 42 on Test CPU (4 CPUs)":
+			t.Fatalf("Unexpected process label %v", ev.Args["name"])
+		case ev.Name == "outer" && (ev.Dur != 200 || ev.TID != 1 || ev.Args["PAPI_TOT_INS"] != 50.0):
+			t.Fatalf("Unexpected outer region %+v", ev)
+		case ev.Name == "PAPI_TOT_INS/s (CPU 3)" && (ev.Ts != 0 || ev.Args["value"] != 2e6):
+			t.Fatalf("Unexpected CPU counter %+v", ev)
+		}
+	}
+	if phases["M"] != 3 || phases["X"] != 3 || phases["C"] != 3 {
+		t.Fatalf("Unexpected number of events of each phase: %v", phases)
+	}
+}
+
+// A field is a single decoded protobuf field.
+type field struct {
+	num   int    // Field number
+	value uint64 // Value of a varint or fixed64 field
+	bytes []byte // Value of a length-delimited field
+}
+
+// Decode the fields of a protobuf message.
+func decode(t *testing.T, b []byte) []field {
+	var fields []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("Invalid field key")
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+		case wireFixed64:
+			f.value, n = binary.LittleEndian.Uint64(b), 8
+		case wireBytes:
+			var size uint64
+			size, n = binary.Uvarint(b)
+			f.bytes = b[n : n+int(size)]
+			n += int(size)
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// Return the first field with a given number.
+func find(fields []field, num int) (field, bool) {
+	for _, f := range fields {
+		if f.num == num {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Ensure that a timeline is written as a Perfetto trace whose slices
+// nest on each thread's track and whose counters appear on tracks
+// beneath the process or a CPU.
+func TestWritePerfetto(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testTimeline().WritePerfetto(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tracks := make(map[uint64]string)
+	depth := make(map[uint64]int)
+	var lastTs uint64
+	counters := 0
+	for _, p := range decode(t, buf.Bytes()) {
+		if p.num != traceFieldPacket {
+			t.Fatalf("Unexpected trace field %d", p.num)
+		}
+		pkt := decode(t, p.bytes)
+		if seq, ok := find(pkt, packetFieldSequenceID); !ok || seq.value != sequenceID {
+			t.Fatal("Packet lacks a sequence ID")
+		}
+		if f, ok := find(pkt, packetFieldTrackDescriptor); ok {
+			desc := decode(t, f.bytes)
+			uuid, _ := find(desc, trackFieldUUID)
+			name, _ := find(desc, trackFieldName)
+			if thr, ok := find(desc, trackFieldThread); ok {
+				name, _ = find(decode(t, thr.bytes), threadFieldName)
+			}
+			if proc, ok := find(desc, trackFieldProcess); ok {
+				name, _ = find(decode(t, proc.bytes), processFieldName)
+			}
+			tracks[uuid.value] = string(name.bytes)
+			continue
+		}
+		f, ok := find(pkt, packetFieldTrackEvent)
+		if !ok {
+			t.Fatalf("Unexpected packet %v", pkt)
+		}
+		ts, _ := find(pkt, packetFieldTimestamp)
+		if ts.value < lastTs {
+			t.Fatalf("Timestamp %d precedes %d", ts.value, lastTs)
+		}
+		lastTs = ts.value
+		ev := decode(t, f.bytes)
+		typ, _ := find(ev, eventFieldType)
+		uuid, _ := find(ev, eventFieldTrackUUID)
+		if _, ok := tracks[uuid.value]; !ok {
+			t.Fatalf("Event refers to undescribed track %d", uuid.value)
+		}
+		switch typ.value {
+		case eventTypeSliceBegin:
+			depth[uuid.value]++
+		case eventTypeSliceEnd:
+			if depth[uuid.value]--; depth[uuid.value] < 0 {
+				t.Fatalf("Slice on track %q ended before it began", tracks[uuid.value])
+			}
+		case eventTypeCounter:
+			v, _ := find(ev, eventFieldDoubleValue)
+			if r := math.Float64frombits(v.value); r != 1e6 && r != 2e6 && r != 3e6 {
+				t.Fatalf("Unexpected counter value %g", r)
+			}
+			counters++
+		}
+	}
+	for uuid, d := range depth {
+		if d != 0 {
+			t.Fatalf("Track %q has %d unterminated slices", tracks[uuid], d)
+		}
+	}
+	want := map[string]bool{
+		"process 42 on Test CPU (4 CPUs)": true,
+		"PAPI thread 0xdead":              true,
+		"PAPI thread 0xbeef":              true,
+		"CPU 3":                           true,
+		"PAPI_TOT_INS/s":                  true,
+	}
+	for _, name := range tracks {
+		delete(want, name)
+	}
+	if len(want) != 0 || len(tracks) != 6 || counters != 3 {
+		t.Fatalf("Unexpected tracks %v or %d counter values", tracks, counters)
+	}
+}
@@ -0,0 +1,124 @@
+// This file defines the top-down recipes for each supported family of
+// processors.  Event names are those used by PAPI's perf_event
+// component (i.e., libpfm4 names).
+
+package topdown
+
+// A catalogEntry associates a recipe with the processors to which it
+// applies.  An empty list of models matches every model in the family.
+type catalogEntry struct {
+	vendor string // Vendor name as reported by PAPI
+	family int32  // CPUID family
+	models []int32
+	recipe *Recipe
+}
+
+// Intel processors based on the Skylake core and its derivatives
+// (Kaby Lake through Comet Lake, and Skylake through Cooper Lake
+// servers)
+var IntelSkylake = &Recipe{
+	Name:  "Intel Skylake",
+	Width: 4,
+	Events: map[Role]string{
+		Cycles:              "PAPI_TOT_CYC",
+		FrontendStallSlots:  "IDQ_UOPS_NOT_DELIVERED:CORE",
+		FrontendStallCycles: "IDQ_UOPS_NOT_DELIVERED:CYCLES_0_UOPS_DELIV_CORE",
+		IssuedOps:           "UOPS_ISSUED:ANY",
+		RetiredOps:          "UOPS_RETIRED:RETIRE_SLOTS",
+		RecoveryCycles:      "INT_MISC:RECOVERY_CYCLES",
+		BranchMispredicts:   "BR_MISP_RETIRED:ALL_BRANCHES",
+		MachineClears:       "MACHINE_CLEARS:COUNT",
+		MemoryStallCycles:   "CYCLE_ACTIVITY:STALLS_MEM_ANY",
+		TotalStallCycles:    "CYCLE_ACTIVITY:STALLS_TOTAL",
+		MicrocodeOps:        "IDQ:MS_UOPS",
+	},
+}
+
+// Intel processors based on the Sunny Cove and Willow Cove cores (Ice
+// Lake and Tiger Lake)
+var IntelIceLake = &Recipe{
+	Name:  "Intel Ice Lake",
+	Width: 5,
+	Events: map[Role]string{
+		Cycles:              "PAPI_TOT_CYC",
+		FrontendStallSlots:  "IDQ_UOPS_NOT_DELIVERED:CORE",
+		FrontendStallCycles: "IDQ_UOPS_NOT_DELIVERED:CYCLES_0_UOPS_DELIV_CORE",
+		IssuedOps:           "UOPS_ISSUED:ANY",
+		RetiredOps:          "UOPS_RETIRED:SLOTS",
+		RecoveryCycles:      "INT_MISC:RECOVERY_CYCLES",
+		BranchMispredicts:   "BR_MISP_RETIRED:ALL_BRANCHES",
+		MachineClears:       "MACHINE_CLEARS:COUNT",
+		MemoryStallCycles:   "CYCLE_ACTIVITY:STALLS_MEM_ANY",
+		TotalStallCycles:    "CYCLE_ACTIVITY:STALLS_TOTAL",
+		MicrocodeOps:        "IDQ:MS_UOPS",
+	},
+}
+
+// Intel processors based on the Golden Cove and later performance
+// cores (Alder Lake, Raptor Lake, Sapphire Rapids, and Emerald Rapids)
+var IntelGoldenCove = &Recipe{
+	Name:  "Intel Golden Cove",
+	Width: 6,
+	Events: map[Role]string{
+		Cycles:              "PAPI_TOT_CYC",
+		FrontendStallSlots:  "IDQ_BUBBLES:CORE",
+		FrontendStallCycles: "IDQ_BUBBLES:CYCLES_0_UOPS_DELIV_CORE",
+		IssuedOps:           "UOPS_ISSUED:ANY",
+		RetiredOps:          "UOPS_RETIRED:SLOTS",
+		RecoveryCycles:      "INT_MISC:RECOVERY_CYCLES",
+		BranchMispredicts:   "BR_MISP_RETIRED:ALL_BRANCHES",
+		MachineClears:       "MACHINE_CLEARS:COUNT",
+		MemoryStallCycles:   "CYCLE_ACTIVITY:STALLS_MEM_ANY",
+		TotalStallCycles:    "CYCLE_ACTIVITY:STALLS_TOTAL",
+		MicrocodeOps:        "UOPS_RETIRED:MS",
+	},
+}
+
+// AMD processors based on the Zen 4 and Zen 5 cores, which provide
+// per-slot dispatch-stall events.  Zen 4 does not distinguish fetch
+// latency from fetch bandwidth or count machine clears, so those
+// level-2 categories are reported as NaN.
+var AMDZen4 = &Recipe{
+	Name:  "AMD Zen 4",
+	Width: 6,
+	Events: map[Role]string{
+		Cycles:             "PAPI_TOT_CYC",
+		FrontendStallSlots: "DE_NO_DISPATCH_PER_SLOT:NO_OPS_FROM_FRONTEND",
+		IssuedOps:          "DE_SRC_OP_DISP:ALL",
+		RetiredOps:         "RETIRED_OPS",
+		BackendStallSlots:  "DE_NO_DISPATCH_PER_SLOT:BACKEND_STALLS",
+		BranchMispredicts:  "RETIRED_BRANCH_INSTRUCTIONS_MISPREDICTED",
+		MemoryStallCycles:  "EX_NO_RETIRE:LOAD_NOT_COMPLETE",
+		TotalStallCycles:   "EX_NO_RETIRE:NOT_COMPLETE",
+		MicrocodeOps:       "RETIRED_UCODE_OPS",
+	},
+}
+
+// All known recipes, searched in order by Detect()
+var catalog = []catalogEntry{
+	{"GenuineIntel", 6, []int32{
+		0x4e, 0x5e, // Skylake client
+		0x55,       // Skylake, Cascade Lake, and Cooper Lake server
+		0x8e, 0x9e, // Kaby Lake, Coffee Lake, and Whiskey Lake
+		0xa5, 0xa6, // Comet Lake
+		0x66, // Cannon Lake
+	}, IntelSkylake},
+	{"GenuineIntel", 6, []int32{
+		0x7d, 0x7e, // Ice Lake client
+		0x6a, 0x6c, // Ice Lake server
+		0x8c, 0x8d, // Tiger Lake
+		0xa7, // Rocket Lake
+	}, IntelIceLake},
+	{"GenuineIntel", 6, []int32{
+		0x97, 0x9a, // Alder Lake
+		0xb7, 0xba, 0xbf, // Raptor Lake
+		0x8f, // Sapphire Rapids
+		0xcf, // Emerald Rapids
+	}, IntelGoldenCove},
+	{"AuthenticAMD", 0x19, []int32{
+		0x10, 0x11, 0x18, // Genoa and Bergamo
+		0x60, 0x61, 0x70, 0x74, 0x75, 0x78, 0x7c, // Raphael, Phoenix, and relatives
+		0xa0, // Siena
+	}, AMDZen4},
+	{"AuthenticAMD", 0x1a, nil, AMDZen4},
+}
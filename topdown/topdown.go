@@ -0,0 +1,339 @@
+/*
+Package topdown measures a workload and reports its top-down
+microarchitecture analysis (TMA) breakdown: the fraction of pipeline
+slots that were frontend bound, lost to bad speculation, retiring
+useful work, or backend bound, plus a second level that splits each of
+those categories in two.
+
+The native events required differ from processor to processor.
+Detect() selects a Recipe based on the vendor, CPUID family, and CPUID
+model reported by papi.GetHardwareInfo().  If the processor cannot
+count all of a recipe's events at once, the workload is run multiple
+times, once per group of events that fit, and the counts from each
+run are normalized by its cycle count.
+*/
+package topdown
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"text/tabwriter"
+
+	"github.com/lanl/go-papi"
+)
+
+// A Role is the purpose an event serves in a top-down computation.
+type Role int
+
+const (
+	Cycles              Role = iota // Core clock cycles (required)
+	FrontendStallSlots              // Slots in which the frontend delivered no operation to a ready backend (required)
+	FrontendStallCycles             // Cycles in which the frontend delivered no operations at all
+	IssuedOps                       // Operations issued or dispatched (required)
+	RetiredOps                      // Operations (slots) retired (required)
+	RecoveryCycles                  // Cycles spent recovering from mispredictions and machine clears
+	BackendStallSlots               // Slots in which the backend could not accept an operation
+	BranchMispredicts               // Mispredicted branches retired
+	MachineClears                   // Machine clears (pipeline flushes other than mispredictions)
+	MemoryStallCycles               // Cycles stalled on outstanding memory accesses
+	TotalStallCycles                // Cycles stalled for any reason
+	MicrocodeOps                    // Operations delivered by the microcode sequencer
+	numRoles
+)
+
+// Names of each role, for error messages
+var roleNames = [numRoles]string{
+	"Cycles", "FrontendStallSlots", "FrontendStallCycles", "IssuedOps",
+	"RetiredOps", "RecoveryCycles", "BackendStallSlots", "BranchMispredicts",
+	"MachineClears", "MemoryStallCycles", "TotalStallCycles", "MicrocodeOps",
+}
+
+// Return the name of a role.
+func (r Role) String() string {
+	if r < 0 || r >= numRoles {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// A Recipe specifies the events with which to compute a top-down
+// breakdown on a particular family of processors.  Roles other than
+// those marked as required may be omitted, in which case the
+// level-2 categories that depend on them are reported as NaN.
+type Recipe struct {
+	Name   string          // Description of the processors to which the recipe applies
+	Width  float64         // Pipeline width in slots per cycle
+	Events map[Role]string // Name of the event that fills each role
+}
+
+// A Breakdown is the result of a top-down analysis.  Each category is
+// expressed as a fraction of the total pipeline slots.  The four
+// level-1 categories sum to 1, and each pair of level-2 categories
+// sums to its parent.
+type Breakdown struct {
+	Recipe string // Name of the recipe used
+	Passes int    // Number of times the workload was run
+
+	// Level 1
+	FrontendBound  float64 // Slots the frontend failed to fill
+	BadSpeculation float64 // Slots wasted on operations that did not retire
+	Retiring       float64 // Slots that retired useful operations
+	BackendBound   float64 // Slots the backend could not accept
+
+	// Level 2
+	FetchLatency       float64 // FrontendBound: frontend delivered nothing (e.g., instruction cache misses)
+	FetchBandwidth     float64 // FrontendBound: frontend delivered too few operations
+	BranchMispredicts  float64 // BadSpeculation: branch mispredictions
+	MachineClears      float64 // BadSpeculation: other pipeline flushes
+	MemoryBound        float64 // BackendBound: waiting on the memory hierarchy
+	CoreBound          float64 // BackendBound: waiting on execution resources
+	BaseOperations     float64 // Retiring: ordinary operations
+	MicrocodeSequencer float64 // Retiring: operations from microcode
+}
+
+// ErrUnsupported is returned by Detect() when no recipe is known for
+// the processor.
+var ErrUnsupported = errors.New("topdown: no recipe for this processor")
+
+// Select the recipe that applies to a given processor.
+func Detect(hw papi.HardwareInfo) (*Recipe, error) {
+	for _, c := range catalog {
+		if c.vendor != hw.VendorName || c.family != hw.CPUIDFamily {
+			continue
+		}
+		if len(c.models) == 0 {
+			return c.recipe, nil
+		}
+		for _, m := range c.models {
+			if m == hw.CPUIDModel {
+				return c.recipe, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w (%s family 0x%x model 0x%x)", ErrUnsupported, hw.VendorName, hw.CPUIDFamily, hw.CPUIDModel)
+}
+
+// Measure a workload using the recipe for the current processor.
+// work may be called more than once and should perform the same
+// computation each time.
+func Measure(work func()) (*Breakdown, error) {
+	r, err := Detect(papi.GetHardwareInfo())
+	if err != nil {
+		return nil, err
+	}
+	return MeasureWith(r, work)
+}
+
+// Measure a workload using a given recipe.  work may be called more
+// than once and should perform the same computation each time.
+func MeasureWith(r *Recipe, work func()) (*Breakdown, error) {
+	// Resolve each event name.
+	var roles []Role
+	codes := make(map[Role]papi.Event)
+	for role := Role(0); role < numRoles; role++ {
+		name, ok := r.Events[role]
+		if !ok {
+			if role.required() {
+				return nil, fmt.Errorf("topdown: %s: no event for required role %s", r.Name, role)
+			}
+			continue
+		}
+		ecode, err := papi.ParseEvent(name)
+		if err != nil {
+			return nil, fmt.Errorf("topdown: %s: %s: %w", r.Name, name, err)
+		}
+		codes[role] = ecode
+		if role != Cycles {
+			roles = append(roles, role)
+		}
+	}
+
+	// Count as many events as fit in each pass, normalizing each
+	// pass's counts by its cycle count.
+	counts := make([]float64, numRoles)
+	for i := range counts {
+		counts[i] = math.NaN()
+	}
+	passes := 0
+	for len(roles) > 0 {
+		counted, values, err := measurePass(codes, roles, work)
+		if err != nil {
+			return nil, fmt.Errorf("topdown: %s: %w", r.Name, err)
+		}
+		passes++
+		if passes == 1 {
+			counts[Cycles] = float64(values[0])
+		}
+		scale := 1.0
+		if values[0] > 0 {
+			scale = counts[Cycles] / float64(values[0])
+		}
+		var remaining []Role
+		for _, role := range roles {
+			if i, ok := counted[role]; ok {
+				counts[role] = float64(values[i]) * scale
+			} else {
+				remaining = append(remaining, role)
+			}
+		}
+		roles = remaining
+	}
+	b := r.compute(counts)
+	b.Passes = passes
+	return b, nil
+}
+
+// Return true if a role must be filled by every recipe.
+func (role Role) required() bool {
+	switch role {
+	case Cycles, FrontendStallSlots, IssuedOps, RetiredOps:
+		return true
+	}
+	return false
+}
+
+// Count cycles plus as many of the given roles' events as the
+// hardware can count at once while running a workload.  Return the
+// index into the counter values of each role counted.  Cycles are
+// always at index 0.  The workload runs on the calling goroutine, which
+// is locked to its OS thread for the pass because the event set counts
+// only the thread that created it.
+func measurePass(codes map[Role]papi.Event, roles []Role, work func()) (counted map[Role]int, values []int64, err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	es, err := papi.CreateEventSet()
+	if err != nil {
+		return
+	}
+	defer es.DestroyEventSet()
+	defer es.CleanupEventSet()
+	if err = es.AddEvent(codes[Cycles]); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", codes[Cycles], err)
+	}
+	counted = make(map[Role]int)
+	numEvents := 1
+	for _, role := range roles {
+		if es.AddEvent(codes[role]) == nil {
+			counted[role] = numEvents
+			numEvents++
+		}
+	}
+	if len(counted) == 0 {
+		// Not even one event fits alongside cycles.
+		err = es.AddEvent(codes[roles[0]])
+		return nil, nil, fmt.Errorf("%s cannot be counted: %w", codes[roles[0]], err)
+	}
+	values = make([]int64, numEvents)
+	if err = es.Start(); err != nil {
+		return
+	}
+	work()
+	err = es.Stop(values)
+	return
+}
+
+// Compute a ratio, returning NaN if either operand is unavailable and
+// 0 if the denominator is zero.
+func ratio(num, denom float64) float64 {
+	switch {
+	case math.IsNaN(num) || math.IsNaN(denom):
+		return math.NaN()
+	case denom == 0:
+		return 0
+	}
+	return num / denom
+}
+
+// Clamp a value to the range [0, max].  NaNs are preserved.
+func clamp(v, max float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > max:
+		return max
+	}
+	return v
+}
+
+// Split a parent category in two given the fraction attributable to
+// the first child.
+func split(parent, frac float64) (float64, float64) {
+	if math.IsNaN(frac) {
+		return math.NaN(), math.NaN()
+	}
+	first := parent * clamp(frac, 1)
+	return first, parent - first
+}
+
+// Compute a top-down breakdown from event counts indexed by role.
+// Missing counts are NaN.
+func (r *Recipe) compute(c []float64) *Breakdown {
+	b := &Breakdown{Recipe: r.Name}
+	slots := r.Width * c[Cycles]
+
+	// Level 1.  Backend bound is measured directly if possible and
+	// otherwise taken to be the slots not otherwise accounted for.
+	// The categories are then scaled to sum to exactly 1.
+	b.FrontendBound = clamp(ratio(c[FrontendStallSlots], slots), 1)
+	wasted := c[IssuedOps] - c[RetiredOps]
+	if !math.IsNaN(c[RecoveryCycles]) {
+		wasted += r.Width * c[RecoveryCycles]
+	}
+	b.BadSpeculation = clamp(ratio(wasted, slots), 1)
+	b.Retiring = clamp(ratio(c[RetiredOps], slots), 1)
+	if math.IsNaN(c[BackendStallSlots]) {
+		b.BackendBound = clamp(1-b.FrontendBound-b.BadSpeculation-b.Retiring, 1)
+	} else {
+		b.BackendBound = clamp(ratio(c[BackendStallSlots], slots), 1)
+	}
+	if total := b.FrontendBound + b.BadSpeculation + b.Retiring + b.BackendBound; total > 0 {
+		b.FrontendBound /= total
+		b.BadSpeculation /= total
+		b.Retiring /= total
+		b.BackendBound /= total
+	}
+
+	// Level 2
+	b.FetchLatency, b.FetchBandwidth = split(b.FrontendBound,
+		ratio(r.Width*c[FrontendStallCycles], c[FrontendStallSlots]))
+	b.BranchMispredicts, b.MachineClears = split(b.BadSpeculation,
+		ratio(c[BranchMispredicts], c[BranchMispredicts]+c[MachineClears]))
+	b.MemoryBound, b.CoreBound = split(b.BackendBound,
+		ratio(c[MemoryStallCycles], c[TotalStallCycles]))
+	b.MicrocodeSequencer, b.BaseOperations = split(b.Retiring,
+		ratio(c[MicrocodeOps], c[IssuedOps]))
+	return b
+}
+
+// Write a breakdown as a table of percentages.
+func (b *Breakdown) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Top-down breakdown (%s, %d pass(es))\n", b.Recipe, b.Passes)
+	for _, row := range []struct {
+		name  string
+		value float64
+	}{
+		{"Frontend bound", b.FrontendBound},
+		{"  Fetch latency", b.FetchLatency},
+		{"  Fetch bandwidth", b.FetchBandwidth},
+		{"Bad speculation", b.BadSpeculation},
+		{"  Branch mispredicts", b.BranchMispredicts},
+		{"  Machine clears", b.MachineClears},
+		{"Retiring", b.Retiring},
+		{"  Base operations", b.BaseOperations},
+		{"  Microcode sequencer", b.MicrocodeSequencer},
+		{"Backend bound", b.BackendBound},
+		{"  Memory bound", b.MemoryBound},
+		{"  Core bound", b.CoreBound},
+	} {
+		if math.IsNaN(row.value) {
+			fmt.Fprintf(tw, "%s\t%6s\n", row.name, "n/a")
+		} else {
+			fmt.Fprintf(tw, "%s\t%5.1f%%\n", row.name, 100*row.value)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,133 @@
+// This file tests top-down analysis.
+
+package topdown
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lanl/go-papi"
+)
+
+// Ensure that processors map to the expected recipes.
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		vendor string
+		family int32
+		model  int32
+		want   *Recipe
+	}{
+		{"GenuineIntel", 6, 0x55, IntelSkylake},
+		{"GenuineIntel", 6, 0x6a, IntelIceLake},
+		{"GenuineIntel", 6, 0x8f, IntelGoldenCove},
+		{"AuthenticAMD", 0x19, 0x11, AMDZen4},
+		{"AuthenticAMD", 0x1a, 0x02, AMDZen4},
+	} {
+		hw := papi.HardwareInfo{VendorName: tc.vendor, CPUIDFamily: tc.family, CPUIDModel: tc.model}
+		r, err := Detect(hw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != tc.want {
+			t.Fatalf("%s 0x%x/0x%x: expected %s but saw %s", tc.vendor, tc.family, tc.model, tc.want.Name, r.Name)
+		}
+	}
+	hw := papi.HardwareInfo{VendorName: "AuthenticAMD", CPUIDFamily: 0x17, CPUIDModel: 0x31}
+	if _, err := Detect(hw); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported but saw %v", err)
+	}
+}
+
+// Return true if two floating-point numbers are approximately equal.
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// Ensure that breakdowns are computed correctly from known counts.
+func TestCompute(t *testing.T) {
+	r := &Recipe{Name: "test", Width: 4}
+	c := make([]float64, numRoles)
+	for i := range c {
+		c[i] = math.NaN()
+	}
+	c[Cycles] = 1000
+	c[FrontendStallSlots] = 800 // 20% of 4000 slots
+	c[FrontendStallCycles] = 50 // 200 slots
+	c[IssuedOps] = 2200         // 200 wasted
+	c[RetiredOps] = 2000        // 50% retiring
+	c[RecoveryCycles] = 50      // another 200 wasted
+	c[BranchMispredicts] = 30   // 3/4 of bad speculation
+	c[MachineClears] = 10       // 1/4 of bad speculation
+	c[MemoryStallCycles] = 100  // 1/4 of backend bound
+	c[TotalStallCycles] = 400
+	b := r.compute(c)
+	for _, tc := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"FrontendBound", b.FrontendBound, 0.2},
+		{"BadSpeculation", b.BadSpeculation, 0.1},
+		{"Retiring", b.Retiring, 0.5},
+		{"BackendBound", b.BackendBound, 0.2},
+		{"FetchLatency", b.FetchLatency, 0.05},
+		{"FetchBandwidth", b.FetchBandwidth, 0.15},
+		{"BranchMispredicts", b.BranchMispredicts, 0.075},
+		{"MachineClears", b.MachineClears, 0.025},
+		{"MemoryBound", b.MemoryBound, 0.05},
+		{"CoreBound", b.CoreBound, 0.15},
+	} {
+		if !approx(tc.got, tc.want) {
+			t.Fatalf("Expected %s = %g but saw %g", tc.name, tc.want, tc.got)
+		}
+	}
+	if !math.IsNaN(b.MicrocodeSequencer) || !math.IsNaN(b.BaseOperations) {
+		t.Fatalf("Expected the retiring split to be unavailable but saw %g/%g", b.MicrocodeSequencer, b.BaseOperations)
+	}
+
+	// A directly measured backend-bound count is normalized with the
+	// other level-1 categories.
+	c[BackendStallSlots] = 1200
+	b = r.compute(c)
+	sum := b.FrontendBound + b.BadSpeculation + b.Retiring + b.BackendBound
+	if !approx(sum, 1) || !approx(b.BackendBound, 0.3/1.1) {
+		t.Fatalf("Unexpected level-1 breakdown %+v", b)
+	}
+}
+
+// Ensure that a workload can be measured using a recipe composed of
+// preset events.
+func TestMeasureWith(t *testing.T) {
+	r := &Recipe{
+		Name:  "presets",
+		Width: 4,
+		Events: map[Role]string{
+			Cycles:             "PAPI_TOT_CYC",
+			FrontendStallSlots: "PAPI_STL_ICY",
+			IssuedOps:          "PAPI_TOT_IIS",
+			RetiredOps:         "PAPI_TOT_INS",
+		},
+	}
+	calls := 0
+	b, err := MeasureWith(r, func() { calls++ })
+	if err != nil {
+		t.Skipf("Presets are unavailable: %s", err)
+	}
+	if b.Passes < 1 || calls != b.Passes {
+		t.Fatalf("Expected the workload to run once per pass but saw %d calls in %d passes", calls, b.Passes)
+	}
+	var sb strings.Builder
+	if err = b.Write(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "Frontend bound") || !strings.Contains(sb.String(), "n/a") {
+		t.Fatalf("Unexpected output %q", sb.String())
+	}
+
+	// Missing required roles are rejected.
+	delete(r.Events, IssuedOps)
+	if _, err = MeasureWith(r, func() {}); err == nil {
+		t.Fatal("Expected a recipe lacking IssuedOps to be rejected")
+	}
+}